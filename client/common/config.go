@@ -50,22 +50,88 @@ var (
 			Title       string
 			PayCmdName  string
 			ServerMode  bool
+			TLS         bool   // serve over HTTPS
+			TLSCert     string // certificate file (if empty, a self-signed one is created in the data folder)
+			TLSKey      string
+			ClientCA    string // clients with a certificate signed by this CA are allowed from any IP
+			REST        bool   // serve the read-only REST interface at /rest/ (for AllowedIP, without authentication)
+			Metrics     bool   // serve the node's metrics in Prometheus text format at /metrics (for AllowedIP)
 		}
 		RPC struct {
-			Enabled  bool
-			Username string
-			Password string
-			TCPPort  uint32
+			Enabled    bool
+			Interface  string // IP to listen on
+			Username   string
+			Password   string
+			TCPPort    uint32
+			Threads    uint32   // how many requests can be executed at the same time
+			TimeoutSec uint32   // default time limit for a single request
+			Cookie     bool     // write a random password to the .cookie file in the data folder
+			Auth       []string // additional users, "user:salt$hmac" (use "rpcauth" command to make one)
+			Whitelist  []string // methods a user can call, "user:method1,method2,..." (no entry - all allowed)
+			TLS        bool     // serve over HTTPS
+			TLSCert    string   // certificate file (if empty, a self-signed one is created in the data folder)
+			TLSKey     string
+			ClientCA   string // if set, only clients with a certificate signed by this CA can connect
+			// getblocktemplate with longpollid returns on a new tip, or if the fees rose by LongPollFeeGainPerc
+			// (checked every LongPollSec)
+			LongPollSec         uint32
+			LongPollFeeGainPerc float64
+			RefNode             struct {
+				URL      string // JSON-RPC endpoint of a node to compare our results with (empty to disable)
+				Username string
+				Password string
+				Methods  []string // which methods to compare
+			}
+		}
+		GRPC struct {
+			Enabled   bool
+			Interface string // IP to listen on
+			Username  string
+			Password  string // the server does not start until it is set
+			TCPPort   uint32
+			TLS       bool
+			TLSCert   string // certificate file (if empty, a self-signed one is created in the data folder)
+			TLSKey    string
+			ClientCA  string // if set, only clients with a certificate signed by this CA can connect
+		}
+		Stratum struct {
+			Enabled         bool
+			Interface       string  // "IP:port" to listen on
+			PayoutAddress   string  // where the mined coins go (if empty, the Mining section's payouts are used)
+			CoinbaseTag     string  // put into the coinbase's input script
+			Password        string  // if not empty, miners must give it in mining.authorize
+			Difficulty      float64 // of the shares
+			Extranonce2Size uint32  // 2 to 8 bytes
+			RefreshSec      uint32  // how often to check the memory pool for a better block
+			MinFeeGainPerc  float64 // send new work if the block's fees increased at least this much
+		}
+		Mining struct {
+			PayoutAddress  string   // where the coins of the blocks built by the node go
+			Payouts        []string // "address:percent" - parts of the block reward that go to other addresses
+			CoinbaseTag    string   // put into the coinbase's input script (add it to miners.json to recognize our blocks)
+			ExtranonceSize uint32   // bytes left for the extranonce in the coinbase given by getblocktemplate (at most 32)
+		}
+		Electrum struct {
+			Enabled      bool
+			Interface    string // "IP:port" for plain TCP connections (empty to disable them)
+			SSLInterface string // "IP:port" for SSL connections (empty to disable them)
+			TLSCert      string // certificate file (if empty, a self-signed one is created in the data folder)
+			TLSKey       string
+			MaxClients   uint32
+			Banner       string // returned by server.banner
 		}
 		Net struct {
-			ListenTCP      bool
-			TCPPort        uint16
-			MaxOutCons     uint32
-			MaxInCons      uint32
-			MaxUpKBps      uint
-			MaxDownKBps    uint
-			MaxBlockAtOnce uint32
-			MinSegwitCons  uint32
+			ListenTCP          bool
+			TCPPort            uint16
+			MaxOutCons         uint32
+			MaxInCons          uint32
+			BlockRelayOnlyCons uint32 // outgoing connections that do not relay txs nor addrs (not counted in MaxOutCons)
+			MaxUpKBps          uint
+			MaxDownKBps        uint
+			MaxBlockAtOnce     uint32
+			MinSegwitCons      uint32
+			Whitelist          []string // "[perm1,perm2@]<IP[/bits] or auth pubkey>" (IPs get only noban,mempool by default)
+			MinChainWork       float64  // sum of difficulties - chains with less work get pre-synced before storing
 		}
 		TXPool struct {
 			Enabled        bool // Global on/off swicth
@@ -103,6 +169,7 @@ var (
 			MiningHrs   uint
 			FeesBlks    uint
 			BSizeBlks   uint
+			MinersDays  uint // how long to keep the miners of blocks in the index (also the longest window of the mining stats)
 		}
 		DropPeers struct {
 			DropEachMinutes uint // zero for never
@@ -127,6 +194,7 @@ func InitConfig() {
 	CFG.Net.ListenTCP = true
 	CFG.Net.MaxOutCons = 9
 	CFG.Net.MaxInCons = 10
+	CFG.Net.BlockRelayOnlyCons = 2
 	CFG.Net.MaxBlockAtOnce = 3
 	CFG.Net.MinSegwitCons = 4
 
@@ -139,8 +207,31 @@ func InitConfig() {
 	CFG.WebUI.Title = "Duod"
 	CFG.WebUI.PayCmdName = "payCmd.txt"
 
+	CFG.RPC.Interface = "127.0.0.1"
 	CFG.RPC.Username = "Duodrpc"
 	CFG.RPC.Password = "Duodpwd"
+	CFG.RPC.Threads = 8
+	CFG.RPC.TimeoutSec = 30
+	CFG.RPC.Cookie = true
+	CFG.RPC.LongPollSec = 60
+	CFG.RPC.LongPollFeeGainPerc = 5
+	CFG.RPC.RefNode.Methods = []string{"getblocktemplate", "getblockheader", "getrawmempool"}
+
+	CFG.GRPC.Interface = "127.0.0.1"
+
+	CFG.Stratum.Interface = "127.0.0.1:3333"
+	CFG.Stratum.CoinbaseTag = "/Duod/"
+	CFG.Stratum.Difficulty = 1
+	CFG.Stratum.Extranonce2Size = 4
+	CFG.Stratum.RefreshSec = 30
+	CFG.Stratum.MinFeeGainPerc = 5
+
+	CFG.Mining.CoinbaseTag = "/Duod/"
+	CFG.Mining.ExtranonceSize = 8
+
+	CFG.Electrum.Interface = "127.0.0.1:50001"
+	CFG.Electrum.MaxClients = 100
+	CFG.Electrum.Banner = "Welcome to Duod Electrum server"
 
 	CFG.TXPool.Enabled = true
 	CFG.TXPool.AllowMemInputs = true
@@ -162,6 +253,7 @@ func InitConfig() {
 
 	CFG.Stat.HashrateHrs = 12
 	CFG.Stat.MiningHrs = 24
+	CFG.Stat.MinersDays = 30
 	CFG.Stat.FeesBlks = 4 * 6   /*last 4 hours*/
 	CFG.Stat.BSizeBlks = 12 * 6 /*half a day*/
 
@@ -283,6 +375,8 @@ func Reset() {
 	}
 	ListenTCP = CFG.Net.ListenTCP
 
+	applyWhitelist()
+
 	if CFG.UTXOSaveSec != 0 {
 		utxo.UXTOWritingTimeTarget = time.Second * time.Duration(CFG.UTXOSaveSec)
 	}
@@ -297,6 +391,15 @@ func Reset() {
 		CFG.Memory.MaxDataFileMB = 8
 	}
 
+	if CFG.Stratum.Extranonce2Size < 2 {
+		CFG.Stratum.Extranonce2Size = 2
+	} else if CFG.Stratum.Extranonce2Size > 8 {
+		CFG.Stratum.Extranonce2Size = 8
+	}
+	if CFG.Mining.ExtranonceSize > 32 {
+		CFG.Mining.ExtranonceSize = 32
+	}
+
 	MkTempBlocksDir()
 
 	ReloadMiners()
@@ -329,6 +432,31 @@ func RPCPort() (res uint32) {
 	return
 }
 
+// GRPCPort -
+func GRPCPort() (res uint32) {
+	mutexCfg.Lock()
+	defer mutexCfg.Unlock()
+
+	if CFG.GRPC.TCPPort != 0 {
+		res = CFG.GRPC.TCPPort
+		return
+	}
+	if CFG.Testnet {
+		res = 21050
+	} else {
+		res = 11050
+	}
+	return
+}
+
+// MinChainWork -
+func MinChainWork() (res float64) {
+	mutexCfg.Lock()
+	res = CFG.Net.MinChainWork
+	mutexCfg.Unlock()
+	return
+}
+
 // DefaultTCPport -
 func DefaultTCPport() (res uint16) {
 	mutexCfg.Lock()
@@ -367,6 +495,19 @@ func str2oaa(ip string) (res *oneAllowedAddr) {
 	return
 }
 
+// Converts addr/mask back to an IP range
+func oaa2str(oaa *oneAllowedAddr) string {
+	var x uint32
+	for m := oaa.Mask; m != 0; m <<= 1 {
+		x++
+	}
+	s := fmt.Sprintf("%d.%d.%d.%d", byte(oaa.Addr>>24), byte(oaa.Addr>>16), byte(oaa.Addr>>8), byte(oaa.Addr))
+	if x != 32 {
+		s += fmt.Sprint("/", x)
+	}
+	return s
+}
+
 // LockCfg -
 func LockCfg() {
 	mutexCfg.Lock()
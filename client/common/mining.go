@@ -4,26 +4,47 @@ package common
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"hash/crc32"
 	"io/ioutil"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/ParallelCoinTeam/duod/lib/btc"
 	"github.com/ParallelCoinTeam/duod/lib/L"
 )
 
+// MinersFileName - the list of miners' tags: [["name", "coinbase tag", "or payout address"], ...]
+const MinersFileName = "miners.json"
+
 type oneMinerID struct {
 	Name string
 	Tag  []byte
 }
 
-// MinerIDs -
-var MinerIDs []oneMinerID
+var (
+	// MinerIDs -
+	MinerIDs []oneMinerID
+	minersListID uint32
+	minersMutex  sync.Mutex
+)
+
+// MinersListID - changes when a different miners.json is loaded (so the miners index can be refreshed)
+func MinersListID() uint32 {
+	minersMutex.Lock()
+	defer minersMutex.Unlock()
+	return minersListID
+}
 
 // TxMiner -
 // return miner ID of the given coinbase transaction
 func TxMiner(cbtx *btc.Tx) (string, int) {
+	minersMutex.Lock()
+	ids := MinerIDs
+	minersMutex.Unlock()
 	txdat := cbtx.Serialize()
-	for i, m := range MinerIDs {
+	for i, m := range ids {
 		if bytes.Equal(m.Tag, []byte("_p2pool_")) { // P2Pool
 			if len(cbtx.TxOut) > 10 &&
 				bytes.Equal(cbtx.TxOut[len(cbtx.TxOut)-1].PkScript[:2], []byte{0x6A, 0x28}) {
@@ -44,17 +65,88 @@ func TxMiner(cbtx *btc.Tx) (string, int) {
 	return "", -1
 }
 
-// ReloadMiners -
+// PayoutScript - returns the output script of the address, making sure it is for our network
+func PayoutScript(addr string) ([]byte, error) {
+	aa, er := btc.NewAddrFromString(addr)
+	if er != nil {
+		return nil, er
+	}
+	if aa.SegwitProg != nil && aa.SegwitProg.HRP != btc.GetSegwitHRP(Testnet) || aa.SegwitProg == nil &&
+		aa.Version != btc.AddrVerPubkey(Testnet) && aa.Version != btc.AddrVerScript(Testnet) {
+		return nil, errors.New("address is for a different network")
+	}
+	return aa.OutScript(), nil
+}
+
+// Payout - an output of the coinbase
+type Payout struct {
+	Script []byte
+	Perc   float64 // of the block reward (zero for the main payout, which gets the rest)
+}
+
+// ParsePayouts - returns the main payout to addr, followed by the splits ("address:percent")
+func ParsePayouts(addr string, splits []string) ([]Payout, error) {
+	if addr == "" {
+		return nil, errors.New("no payout address")
+	}
+	script, er := PayoutScript(addr)
+	if er != nil {
+		return nil, er
+	}
+	res := []Payout{{Script: script}}
+	var tot float64
+	for _, s := range splits {
+		ss := strings.Split(s, ":")
+		if len(ss) != 2 {
+			return nil, errors.New("payout split must be \"address:percent\" - " + s)
+		}
+		perc, er := strconv.ParseFloat(ss[1], 64)
+		if er != nil || perc <= 0 {
+			return nil, errors.New("bad percent in payout split " + s)
+		}
+		if script, er = PayoutScript(ss[0]); er != nil {
+			return nil, er
+		}
+		tot += perc
+		res = append(res, Payout{Script: script, Perc: perc})
+	}
+	if tot >= 100 {
+		return nil, errors.New("payout splits take 100% or more")
+	}
+	return res, nil
+}
+
+// MiningPayouts - returns the payouts set in the Mining section of the config
+func MiningPayouts() ([]Payout, error) {
+	LockCfg()
+	addr := CFG.Mining.PayoutAddress
+	splits := CFG.Mining.Payouts
+	UnlockCfg()
+	return ParsePayouts(addr, splits)
+}
+
+// ReloadMiners - loads miners.json from the data folder (if there is one) or from the current folder
+// The list is only parsed again if the file has changed.
 func ReloadMiners() {
-	d, _ := ioutil.ReadFile("miners.json")
+	d, _ := ioutil.ReadFile(DuodHomeDir + MinersFileName)
+	if d == nil {
+		d, _ = ioutil.ReadFile(MinersFileName)
+	}
 	if d != nil {
+		id := crc32.ChecksumIEEE(d)
+		minersMutex.Lock()
+		same := id == minersListID && MinerIDs != nil
+		minersMutex.Unlock()
+		if same {
+			return
+		}
 		var MinerIDfile [][3]string
 		e := json.Unmarshal(d, &MinerIDfile)
 		if e != nil {
 			L.Error("miners.json", e.Error())
 			return
 		}
-		MinerIDs = nil
+		var ids []oneMinerID
 		for _, r := range MinerIDfile {
 			var rec oneMinerID
 			rec.Name = r[0]
@@ -68,8 +160,12 @@ func ReloadMiners() {
 					continue
 				}
 			}
-			MinerIDs = append(MinerIDs, rec)
+			ids = append(ids, rec)
 		}
+		minersMutex.Lock()
+		MinerIDs = ids
+		minersListID = id
+		minersMutex.Unlock()
 	}
 }
 
@@ -0,0 +1,40 @@
+package common
+
+import (
+	"crypto/tls"
+	"net"
+	"os"
+
+	"github.com/ParallelCoinTeam/duod/lib/L"
+	"github.com/ParallelCoinTeam/duod/lib/others/tlscert"
+)
+
+// TLSConfig - returns TLS config for a listener on the given interface ("host:port" or "host")
+// If certFile and keyFile are empty, duod.crt and duod.key from the data folder are used.
+// They are created (self-signed) if they do not exist.
+func TLSConfig(iface, certFile, keyFile, clientCA string, requireClientCert bool) (*tls.Config, error) {
+	if certFile == "" && keyFile == "" {
+		certFile = DuodHomeDir + "duod.crt"
+		keyFile = DuodHomeDir + "duod.key"
+	}
+
+	hosts := []string{"localhost", "127.0.0.1"}
+	if h, _, er := net.SplitHostPort(iface); er == nil {
+		iface = h
+	}
+	if iface != "" && iface != "0.0.0.0" && iface != "127.0.0.1" {
+		hosts = append(hosts, iface)
+	}
+	if h, er := os.Hostname(); er == nil {
+		hosts = append(hosts, h)
+	}
+
+	cert, created, er := tlscert.LoadOrCreate(certFile, keyFile, hosts)
+	if er != nil {
+		return nil, er
+	}
+	if created {
+		L.Info("Self-signed TLS certificate created in ", certFile)
+	}
+	return tlscert.ServerConfig(cert, clientCA, requireClientCert)
+}
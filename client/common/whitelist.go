@@ -0,0 +1,201 @@
+package common
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync/atomic"
+
+	"github.com/ParallelCoinTeam/duod/lib/btc"
+	"github.com/ParallelCoinTeam/duod/lib/L"
+)
+
+// PeerPerms - permission flags granted to a peer by the whitelist rules
+type PeerPerms uint32
+
+const (
+	// PermNoBan - misbehaving peer gets disconnected, but never banned nor dropped as the worst one
+	PermNoBan PeerPerms = 1 << iota
+	// PermRelay - transactions from the peer are trusted (no script verification nor RBF limits)
+	PermRelay
+	// PermMempool - peer may exchange the memory pool with us ("getmp" message)
+	PermMempool
+	// PermDownload - blocks downloaded from the peer are trusted (no script verification)
+	PermDownload
+	// PermBloomFilter - peer is allowed to ask us not to relay txs to it (SPV client)
+	PermBloomFilter
+	// PermForceRelay - implies relay, but the transactions are still routed to other peers
+	PermForceRelay
+
+	// PermDefault - used when an auth pubkey rule does not specify any permissions
+	PermDefault = PermNoBan | PermRelay | PermMempool | PermDownload
+	// PermDefaultIP - used when an IP/subnet rule does not specify any permissions
+	// Source addresses can be spoofed, so relay and download must be given explicitly.
+	PermDefaultIP = PermNoBan | PermMempool
+)
+
+var permNames = []struct {
+	name string
+	perm PeerPerms
+}{
+	{"noban", PermNoBan},
+	{"relay", PermRelay},
+	{"mempool", PermMempool},
+	{"download", PermDownload},
+	{"bloomfilter", PermBloomFilter},
+	{"forcerelay", PermForceRelay},
+}
+
+// Has - returns true if any of the given flags is set
+func (p PeerPerms) Has(flags PeerPerms) bool {
+	return (p & flags) != 0
+}
+
+// String - comma separated list of permission names
+func (p PeerPerms) String() string {
+	var names []string
+	for _, pn := range permNames {
+		if p.Has(pn.perm) {
+			names = append(names, pn.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// ParsePermissions - converts comma separated names into permission flags
+func ParsePermissions(s string) (res PeerPerms, e error) {
+	for _, n := range strings.Split(s, ",") {
+		n = strings.ToLower(strings.TrimSpace(n))
+		if n == "" {
+			continue
+		}
+		var found bool
+		for _, pn := range permNames {
+			if pn.name == n {
+				res |= pn.perm
+				found = true
+				break
+			}
+		}
+		if !found {
+			e = errors.New("Unknown permission " + n)
+			return
+		}
+	}
+	if res.Has(PermForceRelay) {
+		res |= PermRelay
+	}
+	return
+}
+
+// WhitelistRule -
+type WhitelistRule struct {
+	Perms  PeerPerms
+	Addr   *oneAllowedAddr // IPv4 or subnet
+	Pubkey []byte          // or the auth public key
+}
+
+// String - in the same format as used in the config
+func (r *WhitelistRule) String() string {
+	if r.Addr != nil {
+		return r.Perms.String() + "@" + oaa2str(r.Addr)
+	}
+	return r.Perms.String() + "@" + btc.EncodeBase58(r.Pubkey)
+}
+
+var (
+	whitelist    []*WhitelistRule
+	whitelistGen uint32
+)
+
+// ParseWhitelistRule - format is "[perm1,perm2,...@]<IPv4[/bits] | base58 auth pubkey>"
+func ParseWhitelistRule(s string) (r *WhitelistRule, e error) {
+	r = new(WhitelistRule)
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndex(s, "@"); i != -1 {
+		if r.Perms, e = ParsePermissions(s[:i]); e != nil {
+			return
+		}
+		s = s[i+1:]
+	}
+	if r.Addr = str2oaa(s); r.Addr != nil {
+		if r.Perms == 0 {
+			r.Perms = PermDefaultIP
+		}
+		return
+	}
+	if pk := btc.DecodeBase58(s); len(pk) == 33 {
+		if r.Perms == 0 {
+			r.Perms = PermDefault
+		}
+		r.Pubkey = pk
+		return
+	}
+	e = errors.New("Neither IP/subnet nor auth pubkey: " + s)
+	return
+}
+
+// applyWhitelist - make sure to call it with locked mutexCfg
+func applyWhitelist() {
+	whitelist = nil
+	for _, s := range CFG.Net.Whitelist {
+		r, e := ParseWhitelistRule(s)
+		if e != nil {
+			L.Error("ERROR: Incorrect Whitelist rule:", e.Error())
+			continue
+		}
+		whitelist = append(whitelist, r)
+	}
+	atomic.AddUint32(&whitelistGen, 1)
+}
+
+// WhitelistGen - changes each time the rules get reloaded
+func WhitelistGen() uint32 {
+	return atomic.LoadUint32(&whitelistGen)
+}
+
+// WhitelistRules - returns a copy of the current rules
+func WhitelistRules() (res []*WhitelistRule) {
+	mutexCfg.Lock()
+	res = make([]*WhitelistRule, len(whitelist))
+	copy(res, whitelist)
+	mutexCfg.Unlock()
+	return
+}
+
+// WhitelistIP - permissions granted to the given IPv4 address
+func WhitelistIP(ip [4]byte) (res PeerPerms) {
+	a := uint32(ip[0])<<24 | uint32(ip[1])<<16 | uint32(ip[2])<<8 | uint32(ip[3])
+	mutexCfg.Lock()
+	for _, r := range whitelist {
+		if r.Addr != nil && (a&r.Addr.Mask) == r.Addr.Addr {
+			res |= r.Perms
+		}
+	}
+	mutexCfg.Unlock()
+	return
+}
+
+// WhitelistPubkeys - all the auth public keys that have any rule
+func WhitelistPubkeys() (res [][]byte) {
+	mutexCfg.Lock()
+	for _, r := range whitelist {
+		if r.Pubkey != nil {
+			res = append(res, r.Pubkey)
+		}
+	}
+	mutexCfg.Unlock()
+	return
+}
+
+// WhitelistPubkey - permissions granted to a peer authorized with the given public key
+func WhitelistPubkey(pk []byte) (res PeerPerms) {
+	mutexCfg.Lock()
+	for _, r := range whitelist {
+		if r.Pubkey != nil && bytes.Equal(r.Pubkey, pk) {
+			res |= r.Perms
+		}
+	}
+	mutexCfg.Unlock()
+	return
+}
@@ -0,0 +1,344 @@
+// Package electrum - Electrum protocol server, with its own index of the chain by script hashes
+package electrum
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/gob"
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/ParallelCoinTeam/duod/client/common"
+	"github.com/ParallelCoinTeam/duod/client/usif"
+	"github.com/ParallelCoinTeam/duod/lib/btc"
+	"github.com/ParallelCoinTeam/duod/lib/chain"
+	"github.com/ParallelCoinTeam/duod/lib/L"
+)
+
+const (
+	// IndexFileName -
+	IndexFileName = "electrum.gob"
+	// UndoDepth - how many blocks can be disconnected without rebuilding the index
+	UndoDepth = 144
+)
+
+// HistEntry - a transaction that spent or received coins of the script
+type HistEntry struct {
+	TxID   [32]byte
+	Height uint32
+}
+
+// OutRec - an unspent output
+type OutRec struct {
+	Script [32]byte // hash of its script
+	Value  uint64
+	Height uint32
+}
+
+// SpentRec - an output spent in a block (to restore it if the block gets disconnected)
+type SpentRec struct {
+	Out btc.TxPrevOut
+	OutRec
+}
+
+// ScriptRec - what we know about one script hash
+type ScriptRec struct {
+	Hist []HistEntry     // in the order of the chain
+	Unsp []btc.TxPrevOut // unspent outputs
+}
+
+// Index - the main chain indexed by script hashes
+type Index struct {
+	Height  uint32
+	Hash    [32]byte // of the last indexed block
+	Scripts map[[32]byte]*ScriptRec
+	Outs    map[btc.TxPrevOut]*OutRec
+	Txs     map[[32]byte]uint32 // height of the block with the transaction
+	Undo    map[uint32][]SpentRec
+}
+
+var (
+	idx      *Index
+	idxMutex sync.RWMutex
+	syncChan = make(chan bool, 1)
+)
+
+// ScriptHash - as used by Electrum protocol (to show it, reverse the bytes)
+func ScriptHash(pkscr []byte) [32]byte {
+	return sha256.Sum256(pkscr)
+}
+
+// resetIndex - starts indexing from the root of the chain
+func resetIndex() {
+	root := common.BlockChain.BlockTreeRoot
+	idxMutex.Lock()
+	idx = &Index{Height: root.Height, Hash: root.BlockHash.Hash, Scripts: make(map[[32]byte]*ScriptRec),
+		Outs: make(map[btc.TxPrevOut]*OutRec), Txs: make(map[[32]byte]uint32), Undo: make(map[uint32][]SpentRec)}
+	idxMutex.Unlock()
+}
+
+func (ix *Index) script(sh [32]byte) *ScriptRec {
+	sr := ix.Scripts[sh]
+	if sr == nil {
+		sr = new(ScriptRec)
+		ix.Scripts[sh] = sr
+	}
+	return sr
+}
+
+func (sr *ScriptRec) delUnsp(po btc.TxPrevOut) {
+	for i := range sr.Unsp {
+		if sr.Unsp[i] == po {
+			sr.Unsp[i] = sr.Unsp[len(sr.Unsp)-1]
+			sr.Unsp = sr.Unsp[:len(sr.Unsp)-1]
+			return
+		}
+	}
+}
+
+// apply - adds the block's transactions to the index (lock idxMutex)
+func (ix *Index) apply(bl *btc.Block, height uint32) {
+	var undo []SpentRec
+	for _, tx := range bl.Txs {
+		touched := make(map[[32]byte]bool)
+		if !tx.IsCoinBase() {
+			for _, in := range tx.TxIn {
+				o := ix.Outs[in.Input]
+				if o == nil {
+					continue // unspendable output (not indexed)
+				}
+				undo = append(undo, SpentRec{Out: in.Input, OutRec: *o})
+				delete(ix.Outs, in.Input)
+				ix.script(o.Script).delUnsp(in.Input)
+				touched[o.Script] = true
+			}
+		}
+		for vout, out := range tx.TxOut {
+			if len(out.PkScript) > 0 && out.PkScript[0] == 0x6a {
+				continue // OP_RETURN
+			}
+			sh := ScriptHash(out.PkScript)
+			po := btc.TxPrevOut{Hash: tx.Hash.Hash, Vout: uint32(vout)}
+			ix.Outs[po] = &OutRec{Script: sh, Value: out.Value, Height: height}
+			sr := ix.script(sh)
+			sr.Unsp = append(sr.Unsp, po)
+			touched[sh] = true
+		}
+		for sh := range touched {
+			sr := ix.Scripts[sh]
+			sr.Hist = append(sr.Hist, HistEntry{TxID: tx.Hash.Hash, Height: height})
+		}
+		ix.Txs[tx.Hash.Hash] = height
+	}
+	ix.Undo[height] = undo
+	delete(ix.Undo, height-UndoDepth)
+	ix.Height, ix.Hash = height, bl.Hash.Hash
+}
+
+// undo - removes the last block from the index (lock idxMutex)
+func (ix *Index) undo(bl *btc.Block, parent *btc.Uint256) error {
+	spent, ok := ix.Undo[ix.Height]
+	if !ok {
+		return errors.New("no undo data")
+	}
+	touched := make(map[[32]byte]bool)
+	for i := len(bl.Txs) - 1; i >= 0; i-- {
+		tx := bl.Txs[i]
+		for vout := range tx.TxOut {
+			po := btc.TxPrevOut{Hash: tx.Hash.Hash, Vout: uint32(vout)}
+			if o := ix.Outs[po]; o != nil {
+				delete(ix.Outs, po)
+				ix.script(o.Script).delUnsp(po)
+				touched[o.Script] = true
+			}
+		}
+		delete(ix.Txs, tx.Hash.Hash)
+	}
+	for i := range spent {
+		o := spent[i].OutRec
+		if o.Height == ix.Height {
+			touched[o.Script] = true // created and spent in this very block
+			continue
+		}
+		ix.Outs[spent[i].Out] = &o
+		sr := ix.script(o.Script)
+		sr.Unsp = append(sr.Unsp, spent[i].Out)
+		touched[o.Script] = true
+	}
+	for sh := range touched {
+		sr := ix.Scripts[sh]
+		for len(sr.Hist) > 0 && sr.Hist[len(sr.Hist)-1].Height == ix.Height {
+			sr.Hist = sr.Hist[:len(sr.Hist)-1]
+		}
+		if len(sr.Hist) == 0 && len(sr.Unsp) == 0 {
+			delete(ix.Scripts, sh)
+		}
+	}
+	delete(ix.Undo, ix.Height)
+	ix.Height--
+	ix.Hash = parent.Hash
+	return nil
+}
+
+// readBlock - returns the block with the transactions list built
+func readBlock(hash *btc.Uint256) (*btc.Block, error) {
+	raw, _, er := common.BlockChain.Blocks.BlockGet(hash)
+	if er != nil {
+		return nil, er
+	}
+	bl, er := btc.NewBlock(raw)
+	if er != nil {
+		return nil, er
+	}
+	if er = bl.BuildTxList(); er != nil {
+		return nil, er
+	}
+	return bl, nil
+}
+
+// undoTip - disconnects the index's last block, which is not on the main chain any more
+func undoTip() error {
+	idxMutex.RLock()
+	hash := btc.NewUint256(idx.Hash[:])
+	idxMutex.RUnlock()
+
+	common.BlockChain.BlockIndexAccess.Lock()
+	n := common.BlockChain.BlockIndex[hash.BIdx()]
+	common.BlockChain.BlockIndexAccess.Unlock()
+	if n == nil || n.Parent == nil {
+		return errors.New("block not in the tree")
+	}
+	bl, er := readBlock(hash)
+	if er != nil {
+		return er
+	}
+	idxMutex.Lock()
+	defer idxMutex.Unlock()
+	return idx.undo(bl, n.Parent.BlockHash)
+}
+
+// syncIndex - brings the index to the chain's tip
+func syncIndex() {
+	var cnt int
+	var changed bool
+out:
+	for !usif.ExitNow.Get() {
+		idxMutex.RLock()
+		hash := btc.NewUint256(idx.Hash[:])
+		idxMutex.RUnlock()
+
+		var path []*chain.BlockTreeNode
+		common.BlockChain.BlockIndexAccess.Lock()
+		n := common.BlockChain.BlockIndex[hash.BIdx()]
+		onMain := n != nil && common.BlockChain.OnActiveBranch(n)
+		if onMain {
+			for end := common.BlockChain.LastBlock(); end != n; end = end.Parent {
+				path = append(path, end)
+			}
+		}
+		common.BlockChain.BlockIndexAccess.Unlock()
+
+		if !onMain {
+			if er := undoTip(); er != nil {
+				L.Info("Electrum: cannot undo block ", hash.String(), " (", er.Error(), ") - rebuilding the index")
+				resetIndex()
+			}
+			changed = true
+			continue
+		}
+		if len(path) == 0 {
+			break
+		}
+		for i := len(path) - 1; i >= 0 && !usif.ExitNow.Get(); i-- {
+			bl, er := readBlock(path[i].BlockHash)
+			if er != nil {
+				L.Error("Electrum: block ", path[i].Height, ": ", er.Error())
+				break out
+			}
+			idxMutex.Lock()
+			if idx.Hash != path[i].Parent.BlockHash.Hash {
+				idxMutex.Unlock()
+				break // should not happen
+			}
+			idx.apply(bl, path[i].Height)
+			idxMutex.Unlock()
+			changed = true
+			if cnt++; cnt%10000 == 0 {
+				L.Info("Electrum: indexed up to block ", path[i].Height)
+			}
+		}
+	}
+	if changed {
+		mempoolChanged()
+	}
+}
+
+// NewTip - call it when the chain's tip changes (it does not block)
+func NewTip() {
+	select {
+	case syncChan <- true:
+	default:
+	}
+}
+
+// indexer - keeps the index in sync with the chain
+func indexer() {
+	for range syncChan {
+		syncIndex()
+	}
+}
+
+// SaveIndex - writes the index to disk (if it is used)
+func SaveIndex() {
+	idxMutex.RLock()
+	defer idxMutex.RUnlock()
+	if idx == nil {
+		return
+	}
+	f, er := os.Create(common.DuodHomeDir + IndexFileName)
+	if er != nil {
+		L.Error("Electrum SaveIndex: ", er.Error())
+		return
+	}
+	buf := bufio.NewWriter(f)
+	er = gob.NewEncoder(buf).Encode(idx)
+	if er != nil {
+		L.Error("Electrum SaveIndex: ", er.Error())
+	}
+	buf.Flush()
+	f.Close()
+}
+
+// loadIndex - reads the index from disk or starts a new one
+func loadIndex() {
+	f, er := os.Open(common.DuodHomeDir + IndexFileName)
+	if er != nil {
+		resetIndex()
+		return
+	}
+	ix := new(Index)
+	er = gob.NewDecoder(bufio.NewReader(f)).Decode(ix)
+	f.Close()
+	if er != nil {
+		L.Error("Electrum: index file corrupt - rebuilding it")
+		resetIndex()
+		return
+	}
+	// gob does not keep empty maps
+	if ix.Scripts == nil {
+		ix.Scripts = make(map[[32]byte]*ScriptRec)
+	}
+	if ix.Outs == nil {
+		ix.Outs = make(map[btc.TxPrevOut]*OutRec)
+	}
+	if ix.Txs == nil {
+		ix.Txs = make(map[[32]byte]uint32)
+	}
+	if ix.Undo == nil {
+		ix.Undo = make(map[uint32][]SpentRec)
+	}
+	idxMutex.Lock()
+	idx = ix
+	idxMutex.Unlock()
+}
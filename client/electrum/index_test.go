@@ -0,0 +1,92 @@
+package electrum
+
+import (
+	"testing"
+
+	"github.com/ParallelCoinTeam/duod/lib/btc"
+)
+
+func testTx(ins []btc.TxPrevOut, outs ...[]byte) *btc.Tx {
+	tx := &btc.Tx{Version: 1}
+	if ins == nil {
+		ins = []btc.TxPrevOut{{Vout: 0xffffffff}} // coinbase
+	}
+	for _, in := range ins {
+		tx.TxIn = append(tx.TxIn, &btc.TxIn{Input: in, Sequence: 0xffffffff, ScriptSig: []byte{byte(len(outs))}})
+	}
+	for i, pk := range outs {
+		tx.TxOut = append(tx.TxOut, &btc.TxOut{Value: uint64(1000 * (i + 1)), PkScript: pk})
+	}
+	tx.SetHash(tx.Serialize())
+	return tx
+}
+
+func testBlock(id byte, txs ...*btc.Tx) *btc.Block {
+	return &btc.Block{Hash: btc.NewUint256(append(make([]byte, 31), id)), Txs: txs}
+}
+
+func TestApplyUndo(t *testing.T) {
+	pkA, pkB, pkC, pkD := []byte{0x51, 1}, []byte{0x51, 2}, []byte{0x51, 3}, []byte{0x51, 4}
+	shA, shC := ScriptHash(pkA), ScriptHash(pkC)
+
+	ix := &Index{Scripts: make(map[[32]byte]*ScriptRec), Outs: make(map[btc.TxPrevOut]*OutRec),
+		Txs: make(map[[32]byte]uint32), Undo: make(map[uint32][]SpentRec)}
+	root := btc.NewUint256(make([]byte, 32))
+
+	cb1 := testTx(nil, pkA)
+	bl1 := testBlock(1, cb1)
+	ix.apply(bl1, 1)
+	outA := btc.TxPrevOut{Hash: cb1.Hash.Hash}
+
+	// the second block spends A and an output created within the same block
+	cb2 := testTx(nil, pkB)
+	t1 := testTx([]btc.TxPrevOut{outA}, pkC, pkD)
+	outC := btc.TxPrevOut{Hash: t1.Hash.Hash}
+	t2 := testTx([]btc.TxPrevOut{outC}, pkA, []byte{0x6a, 0})
+	ix.apply(testBlock(2, cb2, t1, t2), 2)
+
+	if ix.Height != 2 || len(ix.Txs) != 4 {
+		t.Fatal("apply: height", ix.Height, "txs", len(ix.Txs))
+	}
+	if ix.Outs[outA] != nil || ix.Outs[outC] != nil {
+		t.Error("apply: spent outputs still there")
+	}
+	if len(ix.Outs) != 3 { // B, D and the new A (OP_RETURN is not indexed)
+		t.Error("apply: outputs", len(ix.Outs))
+	}
+	if sr := ix.Scripts[shA]; len(sr.Hist) != 3 || len(sr.Unsp) != 1 {
+		t.Error("apply: script A", sr.Hist, sr.Unsp)
+	}
+	if sr := ix.Scripts[shC]; len(sr.Hist) != 2 || len(sr.Unsp) != 0 {
+		t.Error("apply: script C", sr.Hist, sr.Unsp)
+	}
+
+	if er := ix.undo(testBlock(2, cb2, t1, t2), bl1.Hash); er != nil {
+		t.Fatal(er)
+	}
+	if ix.Height != 1 || ix.Hash != bl1.Hash.Hash || len(ix.Txs) != 1 {
+		t.Fatal("undo: height", ix.Height, "txs", len(ix.Txs))
+	}
+	if len(ix.Outs) != 1 || ix.Outs[outA] == nil || ix.Outs[outA].Height != 1 {
+		t.Error("undo: outputs", len(ix.Outs))
+	}
+	if ix.Outs[outC] != nil {
+		t.Error("undo: phantom output of a disconnected tx")
+	}
+	if len(ix.Scripts) != 1 {
+		t.Error("undo: scripts", len(ix.Scripts))
+	}
+	if sr := ix.Scripts[shA]; len(sr.Hist) != 1 || len(sr.Unsp) != 1 || sr.Unsp[0] != outA {
+		t.Error("undo: script A", sr.Hist, sr.Unsp)
+	}
+
+	if er := ix.undo(bl1, root); er != nil {
+		t.Fatal(er)
+	}
+	if len(ix.Outs) != 0 || len(ix.Scripts) != 0 || len(ix.Txs) != 0 || len(ix.Undo) != 0 {
+		t.Error("undo: index not empty")
+	}
+	if er := ix.undo(bl1, root); er == nil {
+		t.Error("undo: no error without undo data")
+	}
+}
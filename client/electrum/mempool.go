@@ -0,0 +1,199 @@
+package electrum
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ParallelCoinTeam/duod/client/network"
+	"github.com/ParallelCoinTeam/duod/lib/btc"
+	"github.com/ParallelCoinTeam/duod/lib/others/sys"
+)
+
+// memEntry - a memory pool transaction that spent or received coins of a script
+type memEntry struct {
+	TxID   [32]byte
+	Height int // 0, or -1 if it spends unconfirmed outputs
+	Fee    uint64
+	Delta  int64 // how it changes the script's balance
+}
+
+// memUtxo - an unspent output of a memory pool transaction
+type memUtxo struct {
+	Out   btc.TxPrevOut
+	Value uint64
+}
+
+var (
+	memValid sys.SyncBool // cleared without locking anything, as TxAcceptedCB runs with TxMutex locked
+
+	memMutex sync.Mutex // protects the maps below (lock it before network.TxMutex)
+	memHist  map[[32]byte][]*memEntry
+	memUnsp  map[[32]byte][]memUtxo
+	memSpent map[btc.TxPrevOut]bool // indexed outputs spent by the memory pool
+)
+
+// mempoolChanged - call it when the memory pool changes (it does not block)
+func mempoolChanged(shs ...[32]byte) {
+	memValid.Clr()
+	touchScripts(shs...)
+	notifyClients()
+}
+
+// MempoolChanged - for TxAcceptedCB and TxRemovedCB (with TxMutex locked)
+func MempoolChanged(t2s *network.OneTxToSend) {
+	clientsMutex.Lock()
+	cnt := len(clients)
+	clientsMutex.Unlock()
+	if cnt == 0 {
+		memValid.Clr()
+		return
+	}
+	mempoolChanged(txScripts(t2s)...)
+}
+
+// txScripts - returns the script hashes whose coins the memory pool tx spends or receives (lock TxMutex)
+func txScripts(t2s *network.OneTxToSend) (res [][32]byte) {
+	for _, out := range t2s.TxOut {
+		if len(out.PkScript) == 0 || out.PkScript[0] != 0x6a { // not OP_RETURN
+			res = append(res, ScriptHash(out.PkScript))
+		}
+	}
+	idxMutex.RLock()
+	for _, in := range t2s.TxIn {
+		if o := idx.Outs[in.Input]; o != nil {
+			res = append(res, o.Script)
+		} else if par, ok := network.TransactionsToSend[btc.BIdx(in.Input.Hash[:])]; ok && int(in.Input.Vout) < len(par.TxOut) {
+			res = append(res, ScriptHash(par.TxOut[in.Input.Vout].PkScript))
+		}
+	}
+	idxMutex.RUnlock()
+	return
+}
+
+// rebuildMempool - indexes the memory pool by script hashes (lock memMutex)
+func rebuildMempool() {
+	memValid.Set() // before reading the pool, so any later change clears it again
+	memHist = make(map[[32]byte][]*memEntry)
+	memUnsp = make(map[[32]byte][]memUtxo)
+	memSpent = make(map[btc.TxPrevOut]bool)
+	entries := make(map[[64]byte]*memEntry)
+
+	entry := func(sh [32]byte, t2s *network.OneTxToSend) *memEntry {
+		var k [64]byte
+		copy(k[:32], sh[:])
+		copy(k[32:], t2s.Hash.Hash[:])
+		e := entries[k]
+		if e == nil {
+			e = &memEntry{TxID: t2s.Hash.Hash, Fee: t2s.Fee}
+			if t2s.MemInputCnt > 0 {
+				e.Height = -1
+			}
+			entries[k] = e
+			memHist[sh] = append(memHist[sh], e)
+		}
+		return e
+	}
+
+	network.TxMutex.Lock()
+	idxMutex.RLock()
+	memOuts := make(map[btc.TxPrevOut]*OutRec)
+	for _, t2s := range network.TransactionsToSend {
+		for vout, out := range t2s.TxOut {
+			if len(out.PkScript) > 0 && out.PkScript[0] == 0x6a {
+				continue // OP_RETURN
+			}
+			sh := ScriptHash(out.PkScript)
+			po := btc.TxPrevOut{Hash: t2s.Hash.Hash, Vout: uint32(vout)}
+			memOuts[po] = &OutRec{Script: sh, Value: out.Value}
+			entry(sh, t2s).Delta += int64(out.Value)
+			if _, spent := network.SpentOutputs[po.UIdx()]; !spent {
+				memUnsp[sh] = append(memUnsp[sh], memUtxo{Out: po, Value: out.Value})
+			}
+		}
+	}
+	for _, t2s := range network.TransactionsToSend {
+		for _, in := range t2s.TxIn {
+			o := idx.Outs[in.Input]
+			if o != nil {
+				memSpent[in.Input] = true
+			} else if o = memOuts[in.Input]; o == nil {
+				continue
+			}
+			entry(o.Script, t2s).Delta -= int64(o.Value)
+		}
+	}
+	idxMutex.RUnlock()
+	network.TxMutex.Unlock()
+
+	for _, h := range memHist {
+		sort.Slice(h, func(i, j int) bool {
+			if h[i].Height != h[j].Height {
+				return h[i].Height > h[j].Height
+			}
+			return bytes.Compare(h[i].TxID[:], h[j].TxID[:]) < 0
+		})
+	}
+}
+
+// mempoolFor - returns the memory pool's transactions and unspent outputs of the script
+// and, for each of the given indexed outputs, whether the memory pool spends it.
+func mempoolFor(sh [32]byte, outs []btc.TxPrevOut) (hist []*memEntry, unsp []memUtxo, spent []bool) {
+	memMutex.Lock()
+	if !memValid.Get() {
+		rebuildMempool()
+	}
+	hist = memHist[sh]
+	unsp = memUnsp[sh]
+	spent = make([]bool, len(outs))
+	for i := range outs {
+		spent[i] = memSpent[outs[i]]
+	}
+	memMutex.Unlock()
+	return
+}
+
+// histItem - an element of blockchain.scripthash.get_history result
+type histItem struct {
+	TxHash string `json:"tx_hash"`
+	Height int    `json:"height"`
+	Fee    uint64 `json:"fee,omitempty"`
+}
+
+func memItems(hist []*memEntry) (res []histItem) {
+	for _, e := range hist {
+		res = append(res, histItem{TxHash: btc.NewUint256(e.TxID[:]).String(), Height: e.Height, Fee: e.Fee})
+	}
+	return
+}
+
+// getHistory - confirmed transactions of the script followed by the memory pool ones
+func getHistory(sh [32]byte) (res []histItem) {
+	idxMutex.RLock()
+	if sr := idx.Scripts[sh]; sr != nil {
+		res = make([]histItem, 0, len(sr.Hist))
+		for _, h := range sr.Hist {
+			res = append(res, histItem{TxHash: btc.NewUint256(h.TxID[:]).String(), Height: int(h.Height)})
+		}
+	}
+	idxMutex.RUnlock()
+	hist, _, _ := mempoolFor(sh, nil)
+	return append(res, memItems(hist)...)
+}
+
+// status - the script hash status as defined by Electrum protocol ("" if there is no history)
+func status(sh [32]byte) string {
+	items := getHistory(sh)
+	if len(items) == 0 {
+		return ""
+	}
+	var buf bytes.Buffer
+	for _, it := range items {
+		fmt.Fprintf(&buf, "%s:%d:", it.TxHash, it.Height)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,624 @@
+package electrum
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+
+	"github.com/ParallelCoinTeam/duod"
+	"github.com/ParallelCoinTeam/duod/client/common"
+	"github.com/ParallelCoinTeam/duod/client/network"
+	"github.com/ParallelCoinTeam/duod/client/rpcapi"
+	"github.com/ParallelCoinTeam/duod/lib/btc"
+	"github.com/ParallelCoinTeam/duod/lib/chain"
+)
+
+// MaxHeaders - most headers returned by blockchain.block.headers
+const MaxHeaders = 2016
+
+type method func(c *client, par []interface{}) (interface{}, *rpcError)
+
+var methods = map[string]method{
+	"server.version":          serverVersion,
+	"server.banner":           serverBanner,
+	"server.donation_address": serverDonationAddress,
+	"server.features":         serverFeatures,
+	"server.peers.subscribe":  serverPeersSubscribe,
+	"server.ping":             serverPing,
+
+	"blockchain.headers.subscribe": headersSubscribe,
+	"blockchain.block.header":      blockHeader,
+	"blockchain.block.headers":     blockHeaders,
+	"blockchain.estimatefee":       estimateFee,
+	"blockchain.relayfee":          relayFee,
+
+	"blockchain.scripthash.get_balance": scriptGetBalance,
+	"blockchain.scripthash.get_history": scriptGetHistory,
+	"blockchain.scripthash.get_mempool": scriptGetMempool,
+	"blockchain.scripthash.listunspent": scriptListUnspent,
+	"blockchain.scripthash.subscribe":   scriptSubscribe,
+	"blockchain.scripthash.unsubscribe": scriptUnsubscribe,
+
+	"blockchain.transaction.broadcast":   txBroadcast,
+	"blockchain.transaction.get":         txGet,
+	"blockchain.transaction.get_merkle":  txGetMerkle,
+	"blockchain.transaction.id_from_pos": txIDFromPos,
+
+	"mempool.get_fee_histogram": feeHistogram,
+}
+
+func invalidParams(msg string) *rpcError {
+	return &rpcError{Code: ErrInvalidParams, Message: msg}
+}
+
+func badRequest(msg string) *rpcError {
+	return &rpcError{Code: ErrBadRequest, Message: msg}
+}
+
+func paramString(par []interface{}, i int) (string, bool) {
+	if i >= len(par) {
+		return "", false
+	}
+	s, ok := par[i].(string)
+	return s, ok
+}
+
+// paramInt - returns def if the param is missing (ok is false if it is not a non-negative integer)
+func paramInt(par []interface{}, i int, def int64) (int64, bool) {
+	if i >= len(par) || par[i] == nil {
+		return def, true
+	}
+	n, ok := par[i].(json.Number)
+	if !ok {
+		return 0, false
+	}
+	v, er := n.Int64()
+	return v, er == nil && v >= 0
+}
+
+func paramBool(par []interface{}, i int) bool {
+	if i < len(par) {
+		b, _ := par[i].(bool)
+		return b
+	}
+	return false
+}
+
+func paramHash(par []interface{}, i int) (h [32]byte, er *rpcError) {
+	s, _ := paramString(par, i)
+	u := btc.NewUint256FromString(s)
+	if u == nil {
+		er = invalidParams(fmt.Sprint("param ", i, " must be a 32 bytes hex hash"))
+		return
+	}
+	h = u.Hash
+	return
+}
+
+func hashString(h [32]byte) string {
+	return btc.NewUint256(h[:]).String()
+}
+
+func hashStrings(hs [][32]byte) []string {
+	res := make([]string, len(hs))
+	for i := range hs {
+		res[i] = hashString(hs[i])
+	}
+	return res
+}
+
+// nodesAt - returns count nodes of the indexed chain, starting from the given height
+func nodesAt(height, count uint32) (res []*chain.BlockTreeNode) {
+	idxMutex.RLock()
+	hash := btc.NewUint256(idx.Hash[:])
+	idxMutex.RUnlock()
+	common.BlockChain.BlockIndexAccess.Lock()
+	defer common.BlockChain.BlockIndexAccess.Unlock()
+	n := common.BlockChain.BlockIndex[hash.BIdx()]
+	if n == nil || count == 0 || height > n.Height || height < common.BlockChain.BlockTreeRoot.Height {
+		return
+	}
+	if uint64(n.Height) >= uint64(height)+uint64(count) {
+		if common.BlockChain.NodeAtHeight(n.Height) == n {
+			n = common.BlockChain.NodeAtHeight(height + count - 1) // the index is on the active branch
+		} else {
+			for uint64(n.Height) >= uint64(height)+uint64(count) {
+				n = n.Parent
+			}
+		}
+	}
+	res = make([]*chain.BlockTreeNode, int(n.Height-height)+1)
+	for i := len(res) - 1; i >= 0; i-- {
+		res[i] = n
+		n = n.Parent
+	}
+	return
+}
+
+// blockAt - reads the block of the indexed chain at the given height
+func blockAt(height uint32) (*btc.Block, *rpcError) {
+	ns := nodesAt(height, 1)
+	if len(ns) == 0 {
+		return nil, badRequest(fmt.Sprint("height ", height, " out of range"))
+	}
+	bl, er := readBlock(ns[0].BlockHash)
+	if er != nil {
+		return nil, &rpcError{Code: ErrDaemon, Message: er.Error()}
+	}
+	return bl, nil
+}
+
+// cpTree - the headers' merkle tree of the last checkpoint asked for
+var (
+	cpMutex sync.Mutex
+	cpHash  [32]byte // of the header at the checkpoint
+	cpTree  [][][32]byte
+)
+
+// headerProof - merkle branch and root of the header at the given height, within headers up to cp
+func headerProof(height, cp uint32) (map[string]interface{}, *rpcError) {
+	if common.BlockChain.BlockTreeRoot.Height != 0 {
+		return nil, badRequest("checkpoint proofs not available")
+	}
+	ns := nodesAt(cp, 1)
+	if len(ns) == 0 {
+		return nil, badRequest(fmt.Sprint("cp_height ", cp, " above the chain's tip"))
+	}
+	cpMutex.Lock()
+	defer cpMutex.Unlock()
+	if cpTree == nil || cpHash != ns[0].BlockHash.Hash {
+		ns = nodesAt(0, cp+1)
+		if len(ns) == 0 || ns[len(ns)-1].Height != cp {
+			return nil, badRequest(fmt.Sprint("cp_height ", cp, " above the chain's tip"))
+		}
+		hs := make([][32]byte, len(ns))
+		for i := range ns {
+			hs[i] = ns[i].BlockHash.Hash
+		}
+		cpHash, cpTree = hs[cp], btc.MerkleTree(hs)
+	}
+	branch := btc.MerkleTreeBranch(cpTree, int(height))
+	return map[string]interface{}{"branch": hashStrings(branch), "root": hashString(cpTree[len(cpTree)-1][0])}, nil
+}
+
+func serverVersion(c *client, par []interface{}) (interface{}, *rpcError) {
+	return []string{"Duod " + Duod.Version, ProtocolVersion}, nil
+}
+
+func serverBanner(c *client, par []interface{}) (interface{}, *rpcError) {
+	common.LockCfg()
+	defer common.UnlockCfg()
+	return common.CFG.Electrum.Banner, nil
+}
+
+func serverDonationAddress(c *client, par []interface{}) (interface{}, *rpcError) {
+	return "", nil
+}
+
+func serverFeatures(c *client, par []interface{}) (interface{}, *rpcError) {
+	common.LockCfg()
+	iface, sslIface := common.CFG.Electrum.Interface, common.CFG.Electrum.SSLInterface
+	common.UnlockCfg()
+	ports := make(map[string]interface{})
+	if _, p, er := net.SplitHostPort(iface); er == nil {
+		ports["tcp_port"] = p
+	}
+	if _, p, er := net.SplitHostPort(sslIface); er == nil {
+		ports["ssl_port"] = p
+	}
+	return map[string]interface{}{
+		"genesis_hash":   common.GenesisBlock.String(),
+		"hosts":          map[string]interface{}{"": ports},
+		"protocol_max":   ProtocolVersion,
+		"protocol_min":   ProtocolVersion,
+		"pruning":        nil,
+		"server_version": "Duod " + Duod.Version,
+		"hash_function":  "sha256",
+	}, nil
+}
+
+func serverPeersSubscribe(c *client, par []interface{}) (interface{}, *rpcError) {
+	return []interface{}{}, nil
+}
+
+func serverPing(c *client, par []interface{}) (interface{}, *rpcError) {
+	return nil, nil
+}
+
+func headersSubscribe(c *client, par []interface{}) (interface{}, *rpcError) {
+	tip, res := tipInfo()
+	c.mutex.Lock()
+	c.headers, c.tip = true, tip
+	c.mutex.Unlock()
+	return res, nil
+}
+
+// blockHeader - [height, cp_height]
+func blockHeader(c *client, par []interface{}) (interface{}, *rpcError) {
+	height, ok1 := paramInt(par, 0, -1)
+	cp, ok2 := paramInt(par, 1, 0)
+	if !ok1 || !ok2 || height < 0 || height > 0xffffffff || cp > 0xffffffff {
+		return nil, invalidParams("bad height or cp_height")
+	}
+	if cp != 0 && height > cp {
+		return nil, badRequest("height above cp_height")
+	}
+	ns := nodesAt(uint32(height), 1)
+	if len(ns) == 0 {
+		return nil, badRequest(fmt.Sprint("height ", height, " out of range"))
+	}
+	hdr := hex.EncodeToString(ns[0].BlockHeader[:])
+	if cp == 0 {
+		return hdr, nil
+	}
+	res, er := headerProof(uint32(height), uint32(cp))
+	if er != nil {
+		return nil, er
+	}
+	res["header"] = hdr
+	return res, nil
+}
+
+// blockHeaders - [start_height, count, cp_height]
+func blockHeaders(c *client, par []interface{}) (interface{}, *rpcError) {
+	start, ok1 := paramInt(par, 0, -1)
+	count, ok2 := paramInt(par, 1, -1)
+	cp, ok3 := paramInt(par, 2, 0)
+	if !ok1 || !ok2 || !ok3 || start < 0 || count < 0 || start > 0xffffffff || cp > 0xffffffff {
+		return nil, invalidParams("bad start_height, count or cp_height")
+	}
+	if count > MaxHeaders {
+		count = MaxHeaders
+	}
+	if cp != 0 && start+count-1 > cp {
+		return nil, badRequest("headers above cp_height")
+	}
+	var ns []*chain.BlockTreeNode
+	if count > 0 {
+		ns = nodesAt(uint32(start), uint32(count))
+	}
+	hdrs := make([]byte, 0, 80*len(ns))
+	for _, n := range ns {
+		hdrs = append(hdrs, n.BlockHeader[:]...)
+	}
+	res := map[string]interface{}{"count": len(ns), "hex": hex.EncodeToString(hdrs), "max": MaxHeaders}
+	if cp != 0 && len(ns) > 0 {
+		proof, er := headerProof(uint32(start)+uint32(len(ns))-1, uint32(cp))
+		if er != nil {
+			return nil, er
+		}
+		res["branch"], res["root"] = proof["branch"], proof["root"]
+	}
+	return res, nil
+}
+
+// estimateFee - [number] - fee in BTC/kB to get into one of the next blocks (-1 if unknown)
+func estimateFee(c *client, par []interface{}) (interface{}, *rpcError) {
+	blocks, ok := paramInt(par, 0, -1)
+	if !ok || blocks < 1 {
+		return nil, invalidParams("number of blocks expected")
+	}
+	if blocks > 1008 {
+		blocks = 1008
+	}
+	maxweight := uint64(blocks) * btc.MaxBlockWeight
+	network.TxMutex.Lock()
+	fees := network.GetMempoolFees(maxweight)
+	network.TxMutex.Unlock()
+	var weight uint64
+	for _, f := range fees {
+		weight += f[0]
+	}
+	spkb := common.MinFeePerKB()
+	if weight >= maxweight && len(fees) > 0 {
+		// the mempool fills the blocks - we need to beat the last package
+		last := fees[len(fees)-1]
+		if fpkb := 4000 * last[1] / last[0]; fpkb > spkb {
+			spkb = fpkb
+		}
+	}
+	return float64(spkb) / 1e8, nil
+}
+
+func relayFee(c *client, par []interface{}) (interface{}, *rpcError) {
+	return float64(common.MinFeePerKB()) / 1e8, nil
+}
+
+func scriptGetBalance(c *client, par []interface{}) (interface{}, *rpcError) {
+	sh, er := paramHash(par, 0)
+	if er != nil {
+		return nil, er
+	}
+	var confirmed uint64
+	idxMutex.RLock()
+	if sr := idx.Scripts[sh]; sr != nil {
+		for i := range sr.Unsp {
+			confirmed += idx.Outs[sr.Unsp[i]].Value
+		}
+	}
+	idxMutex.RUnlock()
+	hist, _, _ := mempoolFor(sh, nil)
+	var unconfirmed int64
+	for _, e := range hist {
+		unconfirmed += e.Delta
+	}
+	return map[string]interface{}{"confirmed": confirmed, "unconfirmed": unconfirmed}, nil
+}
+
+func scriptGetHistory(c *client, par []interface{}) (interface{}, *rpcError) {
+	sh, er := paramHash(par, 0)
+	if er != nil {
+		return nil, er
+	}
+	res := getHistory(sh)
+	if res == nil {
+		res = []histItem{}
+	}
+	return res, nil
+}
+
+func scriptGetMempool(c *client, par []interface{}) (interface{}, *rpcError) {
+	sh, er := paramHash(par, 0)
+	if er != nil {
+		return nil, er
+	}
+	hist, _, _ := mempoolFor(sh, nil)
+	res := memItems(hist)
+	if res == nil {
+		res = []histItem{}
+	}
+	return res, nil
+}
+
+// utxoItem - an element of blockchain.scripthash.listunspent result
+type utxoItem struct {
+	TxHash string `json:"tx_hash"`
+	TxPos  uint32 `json:"tx_pos"`
+	Height uint32 `json:"height"`
+	Value  uint64 `json:"value"`
+}
+
+func scriptListUnspent(c *client, par []interface{}) (interface{}, *rpcError) {
+	sh, er := paramHash(par, 0)
+	if er != nil {
+		return nil, er
+	}
+	var outs []btc.TxPrevOut
+	var recs []OutRec
+	idxMutex.RLock()
+	if sr := idx.Scripts[sh]; sr != nil {
+		outs = append(outs, sr.Unsp...)
+		for i := range outs {
+			recs = append(recs, *idx.Outs[outs[i]])
+		}
+	}
+	idxMutex.RUnlock()
+	_, unsp, spent := mempoolFor(sh, outs)
+
+	res := []utxoItem{}
+	for i := range outs {
+		if !spent[i] {
+			res = append(res, utxoItem{TxHash: hashString(outs[i].Hash), TxPos: outs[i].Vout,
+				Height: recs[i].Height, Value: recs[i].Value})
+		}
+	}
+	for _, u := range unsp {
+		res = append(res, utxoItem{TxHash: hashString(u.Out.Hash), TxPos: u.Out.Vout, Value: u.Value})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		a, b := res[i], res[j]
+		if (a.Height == 0) != (b.Height == 0) {
+			return b.Height == 0
+		}
+		if a.Height != b.Height {
+			return a.Height < b.Height
+		}
+		if a.TxHash != b.TxHash {
+			return a.TxHash < b.TxHash
+		}
+		return a.TxPos < b.TxPos
+	})
+	return res, nil
+}
+
+func scriptSubscribe(c *client, par []interface{}) (interface{}, *rpcError) {
+	sh, er := paramHash(par, 0)
+	if er != nil {
+		return nil, er
+	}
+	st := status(sh)
+	c.mutex.Lock()
+	_, ok := c.subs[sh]
+	if !ok && len(c.subs) >= MaxSubs {
+		c.mutex.Unlock()
+		return nil, badRequest("too many subscriptions")
+	}
+	c.subs[sh] = st
+	c.mutex.Unlock()
+	if st == "" {
+		return nil, nil
+	}
+	return st, nil
+}
+
+func scriptUnsubscribe(c *client, par []interface{}) (interface{}, *rpcError) {
+	sh, er := paramHash(par, 0)
+	if er != nil {
+		return nil, er
+	}
+	c.mutex.Lock()
+	_, ok := c.subs[sh]
+	delete(c.subs, sh)
+	c.mutex.Unlock()
+	return ok, nil
+}
+
+// txBroadcast - [raw_tx]
+func txBroadcast(c *client, par []interface{}) (interface{}, *rpcError) {
+	raw, ok := paramString(par, 0)
+	if !ok {
+		return nil, invalidParams("raw transaction expected")
+	}
+	resp := new(rpcapi.RPCResponse)
+	// as untrusted as if it came from a peer (scripts get checked)
+	rpcapi.SendRawTransaction(&rpcapi.RPCCommand{Params: []interface{}{raw}}, resp)
+	if e, ok := resp.Error.(rpcapi.RPCError); ok {
+		return nil, badRequest("the transaction was rejected by network rules.\n\n" + e.Message + "\n[" + raw + "]")
+	}
+	return resp.Result, nil
+}
+
+// findTx - looks for the transaction in the memory pool and in the index (height is 0 for the memory pool)
+func findTx(txid [32]byte) (tx *btc.Tx, height uint32, er *rpcError) {
+	network.TxMutex.Lock()
+	if t2s, ok := network.TransactionsToSend[btc.NewUint256(txid[:]).BIdx()]; ok {
+		tx = t2s.Tx
+	}
+	network.TxMutex.Unlock()
+	if tx != nil {
+		return
+	}
+	idxMutex.RLock()
+	height, ok := idx.Txs[txid]
+	idxMutex.RUnlock()
+	if !ok {
+		er = badRequest("no such mempool or blockchain transaction")
+		return
+	}
+	var bl *btc.Block
+	if bl, er = blockAt(height); er != nil {
+		return
+	}
+	for _, t := range bl.Txs {
+		if t.Hash.Hash == txid {
+			tx = t
+			return
+		}
+	}
+	er = &rpcError{Code: ErrDaemon, Message: "transaction not found in its block"}
+	return
+}
+
+// txGet - [tx_hash, verbose]
+func txGet(c *client, par []interface{}) (interface{}, *rpcError) {
+	txid, er := paramHash(par, 0)
+	if er != nil {
+		return nil, er
+	}
+	tx, height, er := findTx(txid)
+	if er != nil {
+		return nil, er
+	}
+	if !paramBool(par, 1) {
+		return hex.EncodeToString(tx.Raw), nil
+	}
+	res := rpcapi.TxToJSON(tx)
+	if height != 0 {
+		if ns := nodesAt(height, 1); len(ns) > 0 {
+			idxMutex.RLock()
+			res["confirmations"] = idx.Height - height + 1
+			idxMutex.RUnlock()
+			res["blockhash"] = ns[0].BlockHash.String()
+			res["blocktime"] = ns[0].Timestamp()
+		}
+	}
+	return res, nil
+}
+
+// txHashes - hashes of the block's transactions
+func txHashes(bl *btc.Block) [][32]byte {
+	hs := make([][32]byte, len(bl.Txs))
+	for i, tx := range bl.Txs {
+		hs[i] = tx.Hash.Hash
+	}
+	return hs
+}
+
+// txGetMerkle - [tx_hash, height]
+func txGetMerkle(c *client, par []interface{}) (interface{}, *rpcError) {
+	txid, er := paramHash(par, 0)
+	if er != nil {
+		return nil, er
+	}
+	height, ok := paramInt(par, 1, -1)
+	if !ok || height > 0xffffffff {
+		return nil, invalidParams("bad height")
+	}
+	if height < 0 {
+		idxMutex.RLock()
+		h, ok := idx.Txs[txid]
+		idxMutex.RUnlock()
+		if !ok {
+			return nil, badRequest("transaction not in the block chain")
+		}
+		height = int64(h)
+	}
+	bl, er := blockAt(uint32(height))
+	if er != nil {
+		return nil, er
+	}
+	hs := txHashes(bl)
+	for pos := range hs {
+		if hs[pos] == txid {
+			return map[string]interface{}{"block_height": height, "pos": pos,
+				"merkle": hashStrings(btc.MerkleBranch(hs, pos))}, nil
+		}
+	}
+	return nil, badRequest(fmt.Sprint("transaction not in block ", height))
+}
+
+// txIDFromPos - [height, tx_pos, merkle]
+func txIDFromPos(c *client, par []interface{}) (interface{}, *rpcError) {
+	height, ok1 := paramInt(par, 0, -1)
+	pos, ok2 := paramInt(par, 1, -1)
+	if !ok1 || !ok2 || height < 0 || pos < 0 || height > 0xffffffff {
+		return nil, invalidParams("bad height or tx_pos")
+	}
+	bl, er := blockAt(uint32(height))
+	if er != nil {
+		return nil, er
+	}
+	if pos >= int64(len(bl.Txs)) {
+		return nil, badRequest(fmt.Sprint("no tx at position ", pos, " in block ", height))
+	}
+	txid := bl.Txs[pos].Hash.String()
+	if !paramBool(par, 2) {
+		return txid, nil
+	}
+	return map[string]interface{}{"tx_hash": txid,
+		"merkle": hashStrings(btc.MerkleBranch(txHashes(bl), int(pos)))}, nil
+}
+
+// feeHistogram - [[fee_rate, vsize], ...] with fee rates in sat/vB, from the highest
+func feeHistogram(c *client, par []interface{}) (interface{}, *rpcError) {
+	type rate struct {
+		fee, vsize uint64
+	}
+	var rates []rate
+	network.TxMutex.Lock()
+	for _, t2s := range network.TransactionsToSend {
+		if vs := uint64(t2s.VSize()); vs > 0 {
+			rates = append(rates, rate{fee: t2s.Fee, vsize: vs})
+		}
+	}
+	network.TxMutex.Unlock()
+	sort.Slice(rates, func(i, j int) bool {
+		return rates[i].fee*rates[j].vsize > rates[j].fee*rates[i].vsize
+	})
+
+	res := [][2]float64{}
+	binSize := 100000.0
+	var size float64
+	for i, r := range rates {
+		size += float64(r.vsize)
+		if size > binSize || i == len(rates)-1 {
+			res = append(res, [2]float64{float64(r.fee) / float64(r.vsize), size})
+			size = 0
+			binSize *= 1.1
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,313 @@
+package electrum
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ParallelCoinTeam/duod/client/common"
+	"github.com/ParallelCoinTeam/duod/lib/btc"
+	"github.com/ParallelCoinTeam/duod/lib/L"
+)
+
+const (
+	// MaxLineLength - longest request that a client can send
+	MaxLineLength = 1 << 20
+	// ProtocolVersion - version of Electrum protocol that we implement
+	ProtocolVersion = "1.4"
+	// MaxSubs - how many script hashes one client can subscribe to
+	MaxSubs = 10000
+)
+
+// JSON-RPC error codes
+const (
+	ErrBadRequest     = 1
+	ErrDaemon         = 2
+	ErrMethodNotFound = -32601
+	ErrInvalidParams  = -32602
+)
+
+var (
+	clientsMutex sync.Mutex
+	clients      = make(map[*client]bool)
+
+	notifyChan = make(chan bool, 1)
+
+	touchMutex sync.Mutex
+	touched    map[[32]byte]bool // script hashes whose status may have changed (nil for all of them)
+)
+
+type request struct {
+	ID     interface{}   `json:"id"`
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type client struct {
+	conn    net.Conn
+	wrMutex sync.Mutex
+
+	mutex   sync.Mutex          // protects the fields below
+	subs    map[[32]byte]string // subscribed script hashes with the last sent status
+	headers bool                // subscribed to headers
+	tip     [32]byte            // the last tip sent to the client
+}
+
+// send - writes one JSON message followed by a new line
+func (c *client) send(v interface{}) error {
+	b, er := json.Marshal(v)
+	if er != nil {
+		return er
+	}
+	c.wrMutex.Lock()
+	defer c.wrMutex.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, er = c.conn.Write(append(b, '\n'))
+	return er
+}
+
+func (c *client) notify(method string, params []interface{}) error {
+	return c.send(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+// handle - executes one request and returns the response
+func (c *client) handle(req *request) map[string]interface{} {
+	res := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+	var result interface{}
+	var er *rpcError
+	if f := methods[req.Method]; f != nil {
+		result, er = f(c, req.Params)
+	} else {
+		er = &rpcError{Code: ErrMethodNotFound, Message: "unknown method " + req.Method}
+	}
+	if er != nil {
+		res["error"] = er
+	} else {
+		res["result"] = result
+	}
+	return res
+}
+
+// handleLine - handles a single request or a batch of them
+func (c *client) handleLine(line []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	if line[0] == '[' {
+		var reqs []request
+		if er := dec.Decode(&reqs); er != nil {
+			return er
+		}
+		res := make([]interface{}, len(reqs))
+		for i := range reqs {
+			res[i] = c.handle(&reqs[i])
+		}
+		return c.send(res)
+	}
+	var req request
+	if er := dec.Decode(&req); er != nil {
+		return er
+	}
+	return c.send(c.handle(&req))
+}
+
+// tipInfo - the last indexed block as returned by blockchain.headers.subscribe
+func tipInfo() (hash [32]byte, res map[string]interface{}) {
+	idxMutex.RLock()
+	hash, height := idx.Hash, idx.Height
+	idxMutex.RUnlock()
+	common.BlockChain.BlockIndexAccess.Lock()
+	n := common.BlockChain.BlockIndex[btc.NewUint256(hash[:]).BIdx()]
+	var hdr []byte
+	if n != nil {
+		hdr = n.BlockHeader[:]
+	}
+	common.BlockChain.BlockIndexAccess.Unlock()
+	res = map[string]interface{}{"hex": hex.EncodeToString(hdr), "height": height}
+	return
+}
+
+// update - sends notifications about the new tip and changed script hashes (only the touched ones, if not nil)
+func (c *client) update(tip [32]byte, tipRes map[string]interface{}, touched map[[32]byte]bool, cache map[[32]byte]string) {
+	var ntfs [][]interface{}
+	c.mutex.Lock()
+	if c.headers && c.tip != tip {
+		c.tip = tip
+		ntfs = append(ntfs, []interface{}{"blockchain.headers.subscribe", tipRes})
+	}
+	for sh, last := range c.subs {
+		if touched != nil && !touched[sh] {
+			continue
+		}
+		st, ok := cache[sh]
+		if !ok {
+			st = status(sh)
+			cache[sh] = st
+		}
+		if st != last {
+			c.subs[sh] = st
+			var par interface{}
+			if st != "" {
+				par = st
+			}
+			ntfs = append(ntfs, []interface{}{"blockchain.scripthash.subscribe", btc.NewUint256(sh[:]).String(), par})
+		}
+	}
+	c.mutex.Unlock()
+	for _, n := range ntfs {
+		if c.notify(n[0].(string), n[1:]) != nil {
+			return
+		}
+	}
+}
+
+// touchScripts - marks the script hashes whose status may have changed (all of them, if none given)
+func touchScripts(shs ...[32]byte) {
+	touchMutex.Lock()
+	if len(shs) == 0 {
+		touched = nil
+	} else if touched != nil {
+		for _, sh := range shs {
+			touched[sh] = true
+		}
+	}
+	touchMutex.Unlock()
+}
+
+// notifyClients - call it when the index or the memory pool changes (it does not block)
+func notifyClients() {
+	select {
+	case notifyChan <- true:
+	default:
+	}
+}
+
+// notifier - sends notifications to the clients, no more often than once a second
+func notifier() {
+	for range notifyChan {
+		time.Sleep(time.Second)
+		select {
+		case <-notifyChan:
+		default:
+		}
+		touchMutex.Lock()
+		ts := touched
+		touched = make(map[[32]byte]bool)
+		touchMutex.Unlock()
+
+		var list []*client
+		clientsMutex.Lock()
+		for c := range clients {
+			list = append(list, c)
+		}
+		clientsMutex.Unlock()
+		if len(list) == 0 {
+			continue
+		}
+		tip, tipRes := tipInfo()
+		cache := make(map[[32]byte]string)
+		for _, c := range list {
+			c.update(tip, tipRes, ts, cache)
+		}
+	}
+}
+
+func serve(conn net.Conn, maxClients int) {
+	c := &client{conn: conn, subs: make(map[[32]byte]string)}
+	clientsMutex.Lock()
+	if len(clients) >= maxClients {
+		clientsMutex.Unlock()
+		common.CountSafe("ElectrumTooManyClients")
+		conn.Close()
+		return
+	}
+	clients[c] = true
+	clientsMutex.Unlock()
+	common.CountSafe("ElectrumClients")
+
+	sc := bufio.NewScanner(conn)
+	sc.Buffer(make([]byte, 4096), MaxLineLength)
+	for {
+		conn.SetReadDeadline(time.Now().Add(10 * time.Minute))
+		if !sc.Scan() {
+			break
+		}
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if er := c.handleLine(line); er != nil {
+			c.send(map[string]interface{}{"jsonrpc": "2.0", "id": nil,
+				"error": &rpcError{Code: ErrBadRequest, Message: er.Error()}})
+			break
+		}
+	}
+
+	clientsMutex.Lock()
+	delete(clients, c)
+	clientsMutex.Unlock()
+	conn.Close()
+}
+
+func accept(lis net.Listener, maxClients int) {
+	for {
+		conn, er := lis.Accept()
+		if er != nil {
+			L.Error("Electrum server: ", er.Error())
+			return
+		}
+		go serve(conn, maxClients)
+	}
+}
+
+// StartServer - builds the index and runs the Electrum server
+func StartServer() {
+	common.LockCfg()
+	iface := common.CFG.Electrum.Interface
+	sslIface := common.CFG.Electrum.SSLInterface
+	cert, key := common.CFG.Electrum.TLSCert, common.CFG.Electrum.TLSKey
+	maxClients := int(common.CFG.Electrum.MaxClients)
+	common.UnlockCfg()
+
+	loadIndex()
+	idxMutex.RLock()
+	L.Info("Electrum: index at block ", idx.Height, " - syncing it with the chain")
+	idxMutex.RUnlock()
+	syncIndex()
+	go indexer()
+	go notifier()
+	NewTip() // in case the chain moved while we were syncing
+
+	if iface != "" {
+		lis, er := net.Listen("tcp", iface)
+		if er != nil {
+			L.Error("Electrum server: ", er.Error())
+		} else {
+			L.Debug("Starting Electrum server at ", iface)
+			go accept(lis, maxClients)
+		}
+	}
+	if sslIface != "" {
+		cfg, er := common.TLSConfig(sslIface, cert, key, "", false)
+		if er != nil {
+			L.Error("Electrum SSL server: ", er.Error())
+			return
+		}
+		lis, er := tls.Listen("tcp", sslIface, cfg)
+		if er != nil {
+			L.Error("Electrum SSL server: ", er.Error())
+			return
+		}
+		L.Debug("Starting Electrum SSL server at ", sslIface)
+		go accept(lis, maxClients)
+	}
+}
@@ -0,0 +1,106 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/binary"
+
+	"github.com/ParallelCoinTeam/duod/client/common"
+	"github.com/ParallelCoinTeam/duod/client/grpcapi/pb"
+	"github.com/ParallelCoinTeam/duod/lib/btc"
+	"github.com/ParallelCoinTeam/duod/lib/chain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// nodeHeader - call it with BlockIndexAccess locked
+func nodeHeader(n *chain.BlockTreeNode) *pb.BlockHeader {
+	res := &pb.BlockHeader{
+		Hash:       n.BlockHash.String(),
+		Height:     n.Height,
+		Version:    n.BlockVersion(),
+		MerkleRoot: btc.NewUint256(n.BlockHeader[36:68]).String(),
+		Time:       n.Timestamp(),
+		Bits:       n.Bits(),
+		Nonce:      binary.LittleEndian.Uint32(n.BlockHeader[76:80]),
+		Difficulty: btc.GetDifficulty(n.Bits()),
+		TxCount:    n.TxCount,
+	}
+	if n.Parent != nil {
+		res.PreviousHash = n.Parent.BlockHash.String()
+	}
+	if common.BlockChain.OnActiveBranch(n) {
+		res.Confirmations = int32(common.BlockChain.LastBlock().Height-n.Height) + 1
+	} else {
+		res.Confirmations = -1
+	}
+	return res
+}
+
+// findNode - by hash or by the height on the main chain (lock BlockIndexAccess)
+func findNode(req *pb.BlockRequest) (n *chain.BlockTreeNode, er error) {
+	if req.Hash != "" {
+		h := btc.NewUint256FromString(req.Hash)
+		if h == nil {
+			return nil, status.Error(codes.InvalidArgument, "Bad block hash")
+		}
+		if n = common.BlockChain.BlockIndex[h.BIdx()]; n == nil {
+			return nil, status.Error(codes.NotFound, "Block not found")
+		}
+		return
+	}
+	for n = common.BlockChain.LastBlock(); n != nil && n.Height > req.Height; n = n.Parent {
+	}
+	if n == nil || n.Height != req.Height {
+		return nil, status.Error(codes.OutOfRange, "Block height out of range")
+	}
+	return
+}
+
+// GetTip -
+func (s *server) GetTip(ctx context.Context, req *pb.TipRequest) (*pb.BlockHeader, error) {
+	common.BlockChain.BlockIndexAccess.Lock()
+	defer common.BlockChain.BlockIndexAccess.Unlock()
+	return nodeHeader(common.BlockChain.LastBlock()), nil
+}
+
+// GetBlockHeader -
+func (s *server) GetBlockHeader(ctx context.Context, req *pb.BlockRequest) (*pb.BlockHeader, error) {
+	common.BlockChain.BlockIndexAccess.Lock()
+	defer common.BlockChain.BlockIndexAccess.Unlock()
+	n, er := findNode(req)
+	if er != nil {
+		return nil, er
+	}
+	return nodeHeader(n), nil
+}
+
+// GetBlock -
+func (s *server) GetBlock(ctx context.Context, req *pb.BlockRequest) (*pb.Block, error) {
+	common.BlockChain.BlockIndexAccess.Lock()
+	n, er := findNode(req)
+	var hdr *pb.BlockHeader
+	if er == nil {
+		hdr = nodeHeader(n)
+	}
+	common.BlockChain.BlockIndexAccess.Unlock()
+	if er != nil {
+		return nil, er
+	}
+
+	raw, _, er := common.BlockChain.Blocks.BlockGet(n.BlockHash)
+	if er != nil {
+		return nil, status.Error(codes.Unavailable, "Block not available")
+	}
+	bl, er := btc.NewBlock(raw)
+	if er == nil {
+		er = bl.BuildTxList()
+	}
+	if er != nil {
+		return nil, status.Error(codes.Internal, er.Error())
+	}
+	res := &pb.Block{Header: hdr, Raw: raw, Txids: make([]string, len(bl.Txs))}
+	for i, tx := range bl.Txs {
+		res.Txids[i] = tx.Hash.String()
+	}
+	return res, nil
+}
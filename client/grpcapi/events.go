@@ -0,0 +1,129 @@
+package grpcapi
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"github.com/ParallelCoinTeam/duod/client/common"
+	"github.com/ParallelCoinTeam/duod/client/grpcapi/pb"
+	"github.com/ParallelCoinTeam/duod/client/network"
+	"github.com/ParallelCoinTeam/duod/lib/btc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SubscriberQueue - how many events can wait for a subscriber before it gets disconnected
+const SubscriberQueue = 1000
+
+var (
+	subsMutex sync.Mutex
+	blockSubs = make(map[chan *pb.BlockHeader]bool)
+	txSubs    = make(map[chan *pb.MempoolTx]bool)
+)
+
+var errTooSlow = status.Error(codes.ResourceExhausted, "Subscriber too slow")
+
+// BlockConnected - call it for each block connected to the main chain (from the chain's thread)
+func BlockConnected(bl *btc.Block) {
+	subsMutex.Lock()
+	defer subsMutex.Unlock()
+	if len(blockSubs) == 0 {
+		return
+	}
+	hdr := &pb.BlockHeader{
+		Hash:          bl.Hash.String(),
+		Height:        bl.Height,
+		Version:       bl.Version(),
+		PreviousHash:  btc.NewUint256(bl.ParentHash()).String(),
+		MerkleRoot:    btc.NewUint256(bl.MerkleRoot()).String(),
+		Time:          bl.BlockTime(),
+		Bits:          bl.Bits(),
+		Nonce:         binary.LittleEndian.Uint32(bl.Raw[76:80]),
+		Difficulty:    btc.GetDifficulty(bl.Bits()),
+		Confirmations: 1,
+		TxCount:       uint32(bl.TxCount),
+	}
+	for ch := range blockSubs {
+		select {
+		case ch <- hdr:
+		default:
+			delete(blockSubs, ch)
+			close(ch)
+			common.CountSafe("GrpcSubsTooSlow")
+		}
+	}
+}
+
+// TxAccepted - call it for each transaction accepted to the memory pool
+func TxAccepted(t2s *network.OneTxToSend) {
+	subsMutex.Lock()
+	defer subsMutex.Unlock()
+	if len(txSubs) == 0 {
+		return
+	}
+	mtx := mempoolTx(t2s)
+	mtx.Raw = t2s.Raw
+	for ch := range txSubs {
+		select {
+		case ch <- mtx:
+		default:
+			delete(txSubs, ch)
+			close(ch)
+			common.CountSafe("GrpcSubsTooSlow")
+		}
+	}
+}
+
+// SubscribeBlocks -
+func (s *server) SubscribeBlocks(req *pb.SubscribeRequest, stream pb.Events_SubscribeBlocksServer) error {
+	ch := make(chan *pb.BlockHeader, SubscriberQueue)
+	subsMutex.Lock()
+	blockSubs[ch] = true
+	subsMutex.Unlock()
+	defer func() {
+		subsMutex.Lock()
+		delete(blockSubs, ch)
+		subsMutex.Unlock()
+	}()
+
+	for {
+		select {
+		case hdr, ok := <-ch:
+			if !ok {
+				return errTooSlow
+			}
+			if er := stream.Send(hdr); er != nil {
+				return er
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+// SubscribeTxs -
+func (s *server) SubscribeTxs(req *pb.SubscribeRequest, stream pb.Events_SubscribeTxsServer) error {
+	ch := make(chan *pb.MempoolTx, SubscriberQueue)
+	subsMutex.Lock()
+	txSubs[ch] = true
+	subsMutex.Unlock()
+	defer func() {
+		subsMutex.Lock()
+		delete(txSubs, ch)
+		subsMutex.Unlock()
+	}()
+
+	for {
+		select {
+		case tx, ok := <-ch:
+			if !ok {
+				return errTooSlow
+			}
+			if er := stream.Send(tx); er != nil {
+				return er
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,642 @@
+// gRPC interface of the Duod node
+//
+// To regenerate the Go code (in this folder):
+//   protoc --go_out=. --go_opt=paths=source_relative \
+//     --go-grpc_out=. --go-grpc_opt=paths=source_relative duod.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v3.21.12
+// source: duod.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Chain_GetTip_FullMethodName         = "/duod.Chain/GetTip"
+	Chain_GetBlockHeader_FullMethodName = "/duod.Chain/GetBlockHeader"
+	Chain_GetBlock_FullMethodName       = "/duod.Chain/GetBlock"
+)
+
+// ChainClient is the client API for Chain service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ChainClient interface {
+	// GetTip - returns the header of the last block of the main chain
+	GetTip(ctx context.Context, in *TipRequest, opts ...grpc.CallOption) (*BlockHeader, error)
+	// GetBlockHeader - by hash or by height (on the main chain)
+	GetBlockHeader(ctx context.Context, in *BlockRequest, opts ...grpc.CallOption) (*BlockHeader, error)
+	// GetBlock - by hash or by height (on the main chain)
+	GetBlock(ctx context.Context, in *BlockRequest, opts ...grpc.CallOption) (*Block, error)
+}
+
+type chainClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewChainClient(cc grpc.ClientConnInterface) ChainClient {
+	return &chainClient{cc}
+}
+
+func (c *chainClient) GetTip(ctx context.Context, in *TipRequest, opts ...grpc.CallOption) (*BlockHeader, error) {
+	out := new(BlockHeader)
+	err := c.cc.Invoke(ctx, Chain_GetTip_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chainClient) GetBlockHeader(ctx context.Context, in *BlockRequest, opts ...grpc.CallOption) (*BlockHeader, error) {
+	out := new(BlockHeader)
+	err := c.cc.Invoke(ctx, Chain_GetBlockHeader_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chainClient) GetBlock(ctx context.Context, in *BlockRequest, opts ...grpc.CallOption) (*Block, error) {
+	out := new(Block)
+	err := c.cc.Invoke(ctx, Chain_GetBlock_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ChainServer is the server API for Chain service.
+// All implementations must embed UnimplementedChainServer
+// for forward compatibility
+type ChainServer interface {
+	// GetTip - returns the header of the last block of the main chain
+	GetTip(context.Context, *TipRequest) (*BlockHeader, error)
+	// GetBlockHeader - by hash or by height (on the main chain)
+	GetBlockHeader(context.Context, *BlockRequest) (*BlockHeader, error)
+	// GetBlock - by hash or by height (on the main chain)
+	GetBlock(context.Context, *BlockRequest) (*Block, error)
+	mustEmbedUnimplementedChainServer()
+}
+
+// UnimplementedChainServer must be embedded to have forward compatible implementations.
+type UnimplementedChainServer struct {
+}
+
+func (UnimplementedChainServer) GetTip(context.Context, *TipRequest) (*BlockHeader, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTip not implemented")
+}
+func (UnimplementedChainServer) GetBlockHeader(context.Context, *BlockRequest) (*BlockHeader, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBlockHeader not implemented")
+}
+func (UnimplementedChainServer) GetBlock(context.Context, *BlockRequest) (*Block, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBlock not implemented")
+}
+func (UnimplementedChainServer) mustEmbedUnimplementedChainServer() {}
+
+// UnsafeChainServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ChainServer will
+// result in compilation errors.
+type UnsafeChainServer interface {
+	mustEmbedUnimplementedChainServer()
+}
+
+func RegisterChainServer(s grpc.ServiceRegistrar, srv ChainServer) {
+	s.RegisterService(&Chain_ServiceDesc, srv)
+}
+
+func _Chain_GetTip_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TipRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChainServer).GetTip(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chain_GetTip_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChainServer).GetTip(ctx, req.(*TipRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chain_GetBlockHeader_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BlockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChainServer).GetBlockHeader(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chain_GetBlockHeader_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChainServer).GetBlockHeader(ctx, req.(*BlockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chain_GetBlock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BlockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChainServer).GetBlock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chain_GetBlock_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChainServer).GetBlock(ctx, req.(*BlockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Chain_ServiceDesc is the grpc.ServiceDesc for Chain service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Chain_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "duod.Chain",
+	HandlerType: (*ChainServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetTip",
+			Handler:    _Chain_GetTip_Handler,
+		},
+		{
+			MethodName: "GetBlockHeader",
+			Handler:    _Chain_GetBlockHeader_Handler,
+		},
+		{
+			MethodName: "GetBlock",
+			Handler:    _Chain_GetBlock_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "duod.proto",
+}
+
+const (
+	Transactions_GetTransaction_FullMethodName  = "/duod.Transactions/GetTransaction"
+	Transactions_SendTransaction_FullMethodName = "/duod.Transactions/SendTransaction"
+	Transactions_GetMempool_FullMethodName      = "/duod.Transactions/GetMempool"
+)
+
+// TransactionsClient is the client API for Transactions service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type TransactionsClient interface {
+	// GetTransaction - from the memory pool or, if block_hash is given, from the block
+	GetTransaction(ctx context.Context, in *TxRequest, opts ...grpc.CallOption) (*Transaction, error)
+	// SendTransaction - puts the transaction into the memory pool and broadcasts it
+	SendTransaction(ctx context.Context, in *SendTxRequest, opts ...grpc.CallOption) (*SendTxResponse, error)
+	// GetMempool - lists the transactions in the memory pool
+	GetMempool(ctx context.Context, in *MempoolRequest, opts ...grpc.CallOption) (*MempoolResponse, error)
+}
+
+type transactionsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTransactionsClient(cc grpc.ClientConnInterface) TransactionsClient {
+	return &transactionsClient{cc}
+}
+
+func (c *transactionsClient) GetTransaction(ctx context.Context, in *TxRequest, opts ...grpc.CallOption) (*Transaction, error) {
+	out := new(Transaction)
+	err := c.cc.Invoke(ctx, Transactions_GetTransaction_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionsClient) SendTransaction(ctx context.Context, in *SendTxRequest, opts ...grpc.CallOption) (*SendTxResponse, error) {
+	out := new(SendTxResponse)
+	err := c.cc.Invoke(ctx, Transactions_SendTransaction_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionsClient) GetMempool(ctx context.Context, in *MempoolRequest, opts ...grpc.CallOption) (*MempoolResponse, error) {
+	out := new(MempoolResponse)
+	err := c.cc.Invoke(ctx, Transactions_GetMempool_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// TransactionsServer is the server API for Transactions service.
+// All implementations must embed UnimplementedTransactionsServer
+// for forward compatibility
+type TransactionsServer interface {
+	// GetTransaction - from the memory pool or, if block_hash is given, from the block
+	GetTransaction(context.Context, *TxRequest) (*Transaction, error)
+	// SendTransaction - puts the transaction into the memory pool and broadcasts it
+	SendTransaction(context.Context, *SendTxRequest) (*SendTxResponse, error)
+	// GetMempool - lists the transactions in the memory pool
+	GetMempool(context.Context, *MempoolRequest) (*MempoolResponse, error)
+	mustEmbedUnimplementedTransactionsServer()
+}
+
+// UnimplementedTransactionsServer must be embedded to have forward compatible implementations.
+type UnimplementedTransactionsServer struct {
+}
+
+func (UnimplementedTransactionsServer) GetTransaction(context.Context, *TxRequest) (*Transaction, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTransaction not implemented")
+}
+func (UnimplementedTransactionsServer) SendTransaction(context.Context, *SendTxRequest) (*SendTxResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SendTransaction not implemented")
+}
+func (UnimplementedTransactionsServer) GetMempool(context.Context, *MempoolRequest) (*MempoolResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMempool not implemented")
+}
+func (UnimplementedTransactionsServer) mustEmbedUnimplementedTransactionsServer() {}
+
+// UnsafeTransactionsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TransactionsServer will
+// result in compilation errors.
+type UnsafeTransactionsServer interface {
+	mustEmbedUnimplementedTransactionsServer()
+}
+
+func RegisterTransactionsServer(s grpc.ServiceRegistrar, srv TransactionsServer) {
+	s.RegisterService(&Transactions_ServiceDesc, srv)
+}
+
+func _Transactions_GetTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TxRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionsServer).GetTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transactions_GetTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionsServer).GetTransaction(ctx, req.(*TxRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transactions_SendTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendTxRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionsServer).SendTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transactions_SendTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionsServer).SendTransaction(ctx, req.(*SendTxRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Transactions_GetMempool_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MempoolRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionsServer).GetMempool(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Transactions_GetMempool_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionsServer).GetMempool(ctx, req.(*MempoolRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Transactions_ServiceDesc is the grpc.ServiceDesc for Transactions service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Transactions_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "duod.Transactions",
+	HandlerType: (*TransactionsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetTransaction",
+			Handler:    _Transactions_GetTransaction_Handler,
+		},
+		{
+			MethodName: "SendTransaction",
+			Handler:    _Transactions_SendTransaction_Handler,
+		},
+		{
+			MethodName: "GetMempool",
+			Handler:    _Transactions_GetMempool_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "duod.proto",
+}
+
+const (
+	Addresses_GetBalance_FullMethodName = "/duod.Addresses/GetBalance"
+)
+
+// AddressesClient is the client API for Addresses service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AddressesClient interface {
+	// GetBalance - needs the wallet functionality (AllBalances) enabled
+	GetBalance(ctx context.Context, in *BalanceRequest, opts ...grpc.CallOption) (*BalanceResponse, error)
+}
+
+type addressesClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAddressesClient(cc grpc.ClientConnInterface) AddressesClient {
+	return &addressesClient{cc}
+}
+
+func (c *addressesClient) GetBalance(ctx context.Context, in *BalanceRequest, opts ...grpc.CallOption) (*BalanceResponse, error) {
+	out := new(BalanceResponse)
+	err := c.cc.Invoke(ctx, Addresses_GetBalance_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AddressesServer is the server API for Addresses service.
+// All implementations must embed UnimplementedAddressesServer
+// for forward compatibility
+type AddressesServer interface {
+	// GetBalance - needs the wallet functionality (AllBalances) enabled
+	GetBalance(context.Context, *BalanceRequest) (*BalanceResponse, error)
+	mustEmbedUnimplementedAddressesServer()
+}
+
+// UnimplementedAddressesServer must be embedded to have forward compatible implementations.
+type UnimplementedAddressesServer struct {
+}
+
+func (UnimplementedAddressesServer) GetBalance(context.Context, *BalanceRequest) (*BalanceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBalance not implemented")
+}
+func (UnimplementedAddressesServer) mustEmbedUnimplementedAddressesServer() {}
+
+// UnsafeAddressesServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AddressesServer will
+// result in compilation errors.
+type UnsafeAddressesServer interface {
+	mustEmbedUnimplementedAddressesServer()
+}
+
+func RegisterAddressesServer(s grpc.ServiceRegistrar, srv AddressesServer) {
+	s.RegisterService(&Addresses_ServiceDesc, srv)
+}
+
+func _Addresses_GetBalance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BalanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AddressesServer).GetBalance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Addresses_GetBalance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AddressesServer).GetBalance(ctx, req.(*BalanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Addresses_ServiceDesc is the grpc.ServiceDesc for Addresses service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Addresses_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "duod.Addresses",
+	HandlerType: (*AddressesServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetBalance",
+			Handler:    _Addresses_GetBalance_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "duod.proto",
+}
+
+const (
+	Events_SubscribeBlocks_FullMethodName = "/duod.Events/SubscribeBlocks"
+	Events_SubscribeTxs_FullMethodName    = "/duod.Events/SubscribeTxs"
+)
+
+// EventsClient is the client API for Events service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type EventsClient interface {
+	// SubscribeBlocks - streams the blocks as they get connected to the chain
+	SubscribeBlocks(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (Events_SubscribeBlocksClient, error)
+	// SubscribeTxs - streams the transactions as they get accepted to the memory pool
+	SubscribeTxs(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (Events_SubscribeTxsClient, error)
+}
+
+type eventsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewEventsClient(cc grpc.ClientConnInterface) EventsClient {
+	return &eventsClient{cc}
+}
+
+func (c *eventsClient) SubscribeBlocks(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (Events_SubscribeBlocksClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Events_ServiceDesc.Streams[0], Events_SubscribeBlocks_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &eventsSubscribeBlocksClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Events_SubscribeBlocksClient interface {
+	Recv() (*BlockHeader, error)
+	grpc.ClientStream
+}
+
+type eventsSubscribeBlocksClient struct {
+	grpc.ClientStream
+}
+
+func (x *eventsSubscribeBlocksClient) Recv() (*BlockHeader, error) {
+	m := new(BlockHeader)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *eventsClient) SubscribeTxs(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (Events_SubscribeTxsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Events_ServiceDesc.Streams[1], Events_SubscribeTxs_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &eventsSubscribeTxsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Events_SubscribeTxsClient interface {
+	Recv() (*MempoolTx, error)
+	grpc.ClientStream
+}
+
+type eventsSubscribeTxsClient struct {
+	grpc.ClientStream
+}
+
+func (x *eventsSubscribeTxsClient) Recv() (*MempoolTx, error) {
+	m := new(MempoolTx)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// EventsServer is the server API for Events service.
+// All implementations must embed UnimplementedEventsServer
+// for forward compatibility
+type EventsServer interface {
+	// SubscribeBlocks - streams the blocks as they get connected to the chain
+	SubscribeBlocks(*SubscribeRequest, Events_SubscribeBlocksServer) error
+	// SubscribeTxs - streams the transactions as they get accepted to the memory pool
+	SubscribeTxs(*SubscribeRequest, Events_SubscribeTxsServer) error
+	mustEmbedUnimplementedEventsServer()
+}
+
+// UnimplementedEventsServer must be embedded to have forward compatible implementations.
+type UnimplementedEventsServer struct {
+}
+
+func (UnimplementedEventsServer) SubscribeBlocks(*SubscribeRequest, Events_SubscribeBlocksServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeBlocks not implemented")
+}
+func (UnimplementedEventsServer) SubscribeTxs(*SubscribeRequest, Events_SubscribeTxsServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeTxs not implemented")
+}
+func (UnimplementedEventsServer) mustEmbedUnimplementedEventsServer() {}
+
+// UnsafeEventsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to EventsServer will
+// result in compilation errors.
+type UnsafeEventsServer interface {
+	mustEmbedUnimplementedEventsServer()
+}
+
+func RegisterEventsServer(s grpc.ServiceRegistrar, srv EventsServer) {
+	s.RegisterService(&Events_ServiceDesc, srv)
+}
+
+func _Events_SubscribeBlocks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EventsServer).SubscribeBlocks(m, &eventsSubscribeBlocksServer{stream})
+}
+
+type Events_SubscribeBlocksServer interface {
+	Send(*BlockHeader) error
+	grpc.ServerStream
+}
+
+type eventsSubscribeBlocksServer struct {
+	grpc.ServerStream
+}
+
+func (x *eventsSubscribeBlocksServer) Send(m *BlockHeader) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Events_SubscribeTxs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EventsServer).SubscribeTxs(m, &eventsSubscribeTxsServer{stream})
+}
+
+type Events_SubscribeTxsServer interface {
+	Send(*MempoolTx) error
+	grpc.ServerStream
+}
+
+type eventsSubscribeTxsServer struct {
+	grpc.ServerStream
+}
+
+func (x *eventsSubscribeTxsServer) Send(m *MempoolTx) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Events_ServiceDesc is the grpc.ServiceDesc for Events service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Events_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "duod.Events",
+	HandlerType: (*EventsServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SubscribeBlocks",
+			Handler:       _Events_SubscribeBlocks_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "SubscribeTxs",
+			Handler:       _Events_SubscribeTxs_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "duod.proto",
+}
@@ -0,0 +1,117 @@
+// Package grpcapi - gRPC interface of the node (see pb/duod.proto)
+package grpcapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/ParallelCoinTeam/duod/client/common"
+	"github.com/ParallelCoinTeam/duod/client/grpcapi/pb"
+	"github.com/ParallelCoinTeam/duod/lib/L"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type server struct {
+	pb.UnimplementedChainServer
+	pb.UnimplementedTransactionsServer
+	pb.UnimplementedAddressesServer
+	pb.UnimplementedEventsServer
+}
+
+// checkAuth - expects "authorization" metadata with HTTP basic credentials, as the JSON-RPC does
+func checkAuth(ctx context.Context) error {
+	common.LockCfg()
+	user, pass := common.CFG.GRPC.Username, common.CFG.GRPC.Password
+	common.UnlockCfg()
+	if pass == "" {
+		return status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, a := range md.Get("authorization") {
+		if !strings.HasPrefix(a, "Basic ") {
+			continue
+		}
+		b, er := base64.StdEncoding.DecodeString(a[6:])
+		if er != nil {
+			continue
+		}
+		up := strings.SplitN(string(b), ":", 2)
+		if len(up) == 2 && subtle.ConstantTimeCompare([]byte(up[0]), []byte(user)) == 1 &&
+			subtle.ConstantTimeCompare([]byte(up[1]), []byte(pass)) == 1 {
+			return nil
+		}
+		var addr string
+		if p, ok := peer.FromContext(ctx); ok {
+			addr = p.Addr.String()
+		}
+		L.Error("gRPC: incorrect username or password from ", addr)
+		time.Sleep(250 * time.Millisecond) // slow down brute-force attacks
+		break
+	}
+	return status.Error(codes.Unauthenticated, "Unauthorized")
+}
+
+func unaryAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if er := checkAuth(ctx); er != nil {
+		return nil, er
+	}
+	return handler(ctx, req)
+}
+
+func streamAuth(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if er := checkAuth(ss.Context()); er != nil {
+		return er
+	}
+	return handler(srv, ss)
+}
+
+// StartServer - runs the gRPC server (it does not return unless the server fails)
+func StartServer(port uint32) {
+	common.LockCfg()
+	addr := net.JoinHostPort(common.CFG.GRPC.Interface, fmt.Sprint(port))
+	useTLS := common.CFG.GRPC.TLS
+	certFile, keyFile, clientCA := common.CFG.GRPC.TLSCert, common.CFG.GRPC.TLSKey, common.CFG.GRPC.ClientCA
+	noPass := common.CFG.GRPC.Password == ""
+	common.UnlockCfg()
+
+	if noPass {
+		L.Error("gRPC server not started: set GRPC.Password in the config first")
+		return
+	}
+
+	opts := []grpc.ServerOption{grpc.UnaryInterceptor(unaryAuth), grpc.StreamInterceptor(streamAuth)}
+	if useTLS {
+		cfg, er := common.TLSConfig(addr, certFile, keyFile, clientCA, clientCA != "")
+		if er != nil {
+			L.Error("gRPC TLS: ", er.Error())
+			return
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(cfg)))
+	}
+
+	lis, er := net.Listen("tcp", addr)
+	if er != nil {
+		L.Error("gRPC server: ", er.Error())
+		return
+	}
+	L.Debug("Starting gRPC server at ", addr)
+
+	srv := grpc.NewServer(opts...)
+	s := new(server)
+	pb.RegisterChainServer(srv, s)
+	pb.RegisterTransactionsServer(srv, s)
+	pb.RegisterAddressesServer(srv, s)
+	pb.RegisterEventsServer(srv, s)
+	L.Error("gRPC server: ", srv.Serve(lis).Error())
+}
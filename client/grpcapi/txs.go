@@ -0,0 +1,142 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/hex"
+
+	"github.com/ParallelCoinTeam/duod/client/common"
+	"github.com/ParallelCoinTeam/duod/client/grpcapi/pb"
+	"github.com/ParallelCoinTeam/duod/client/network"
+	"github.com/ParallelCoinTeam/duod/client/rpcapi"
+	"github.com/ParallelCoinTeam/duod/client/usif"
+	"github.com/ParallelCoinTeam/duod/client/wallet"
+	"github.com/ParallelCoinTeam/duod/lib/btc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// mempoolTx - call it with TxMutex locked
+func mempoolTx(t2s *network.OneTxToSend) *pb.MempoolTx {
+	return &pb.MempoolTx{Txid: t2s.Hash.String(), Size: uint32(len(t2s.Raw)), Weight: uint32(t2s.Weight()),
+		Fee: t2s.Fee, Time: t2s.Firstseen.Unix(), Local: t2s.Local}
+}
+
+// rpcStatus - converts JSON-RPC error into gRPC status
+func rpcStatus(e rpcapi.RPCError) error {
+	c := codes.Unknown
+	switch e.Code {
+	case rpcapi.RPCErrDeserialization, rpcapi.RPCErrInvalidParams, rpcapi.RPCErrInvalidParameter:
+		c = codes.InvalidArgument
+	case rpcapi.RPCErrVerify, rpcapi.RPCErrVerifyRejected:
+		c = codes.FailedPrecondition
+	case rpcapi.RPCErrVerifyAlreadyInChain:
+		c = codes.AlreadyExists
+	case rpcapi.RPCErrInvalidAddressOrKey:
+		c = codes.NotFound
+	}
+	return status.Error(c, e.Message)
+}
+
+// GetTransaction -
+func (s *server) GetTransaction(ctx context.Context, req *pb.TxRequest) (*pb.Transaction, error) {
+	txid := btc.NewUint256FromString(req.Txid)
+	if txid == nil {
+		return nil, status.Error(codes.InvalidArgument, "Bad transaction ID")
+	}
+
+	if req.BlockHash == "" {
+		network.TxMutex.Lock()
+		defer network.TxMutex.Unlock()
+		if t2s, ok := network.TransactionsToSend[txid.BIdx()]; ok {
+			return &pb.Transaction{Txid: req.Txid, Raw: t2s.Raw}, nil
+		}
+		return nil, status.Error(codes.NotFound, "No such mempool transaction. Provide the hash of the block it was mined in.")
+	}
+
+	common.BlockChain.BlockIndexAccess.Lock()
+	n, er := findNode(&pb.BlockRequest{Hash: req.BlockHash})
+	var conf int32
+	if er == nil && common.BlockChain.OnActiveBranch(n) {
+		conf = int32(common.BlockChain.LastBlock().Height-n.Height) + 1
+	}
+	common.BlockChain.BlockIndexAccess.Unlock()
+	if er != nil {
+		return nil, er
+	}
+
+	raw, _, er := common.BlockChain.Blocks.BlockGet(n.BlockHash)
+	if er != nil {
+		return nil, status.Error(codes.Unavailable, "Block not available")
+	}
+	bl, er := btc.NewBlock(raw)
+	if er == nil {
+		er = bl.BuildTxList()
+	}
+	if er != nil {
+		return nil, status.Error(codes.Internal, er.Error())
+	}
+	for _, tx := range bl.Txs {
+		if tx.Hash.Equal(txid) {
+			return &pb.Transaction{Txid: req.Txid, Raw: tx.Raw, BlockHash: n.BlockHash.String(), Confirmations: conf}, nil
+		}
+	}
+	return nil, status.Error(codes.NotFound, "No such transaction found in the provided block")
+}
+
+// SendTransaction - does the same as "sendrawtransaction" JSON-RPC call
+func (s *server) SendTransaction(ctx context.Context, req *pb.SendTxRequest) (*pb.SendTxResponse, error) {
+	var resp rpcapi.RPCResponse
+	rpcapi.SendRawTransaction(&rpcapi.RPCCommand{Method: "sendrawtransaction",
+		Params: []interface{}{hex.EncodeToString(req.Raw)}}, &resp)
+	if e, ok := resp.Error.(rpcapi.RPCError); ok {
+		return nil, rpcStatus(e)
+	}
+	txid, _ := resp.Result.(string)
+	return &pb.SendTxResponse{Txid: txid}, nil
+}
+
+// GetMempool -
+func (s *server) GetMempool(ctx context.Context, req *pb.MempoolRequest) (*pb.MempoolResponse, error) {
+	network.TxMutex.Lock()
+	defer network.TxMutex.Unlock()
+	res := &pb.MempoolResponse{Txs: make([]*pb.MempoolTx, 0, len(network.TransactionsToSend)),
+		Bytes: network.TransactionsToSendSize, Weight: network.TransactionsToSendWeight}
+	for _, t2s := range network.TransactionsToSend {
+		res.Txs = append(res.Txs, mempoolTx(t2s))
+	}
+	return res, nil
+}
+
+// GetBalance - returns the unspent outputs of the addresses (the memory pool is not taken into account)
+func (s *server) GetBalance(ctx context.Context, req *pb.BalanceRequest) (*pb.BalanceResponse, error) {
+	if !common.GetBool(&common.WalletON) {
+		return nil, status.Error(codes.Unavailable, "Wallet functionality is not available")
+	}
+	addrs := make([]*btc.Addr, len(req.Addresses))
+	for i, a := range req.Addresses {
+		aa, er := btc.NewAddrFromString(a)
+		if er != nil {
+			return nil, status.Error(codes.InvalidArgument, a+": "+er.Error())
+		}
+		addrs[i] = aa
+	}
+
+	// the balance database is only accessed from the chain's thread
+	lck := new(usif.OneLock)
+	lck.In.Add(1)
+	lck.Out.Add(1)
+	usif.LocksChan <- lck
+	lck.In.Wait()
+	res := &pb.BalanceResponse{Balances: make([]*pb.AddressBalance, len(addrs))}
+	for i, aa := range addrs {
+		ab := &pb.AddressBalance{Address: req.Addresses[i]}
+		for _, u := range wallet.GetAllUnspent(aa) {
+			ab.Value += u.Value
+			ab.Unspent = append(ab.Unspent, &pb.Unspent{Txid: btc.NewUint256(u.TxPrevOut.Hash[:]).String(),
+				Vout: u.TxPrevOut.Vout, Value: u.Value, Height: u.MinedAt, Coinbase: u.Coinbase})
+		}
+		res.Balances[i] = ab
+	}
+	lck.Out.Done()
+	return res, nil
+}
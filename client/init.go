@@ -79,7 +79,8 @@ func hostInit() {
 	ext := &chain.NewChanOpts{
 		UTXOVolatileMode: common.FLAG.VolatileUTXO,
 		UndoBlocks:       common.FLAG.UndoBlocks,
-		BlockMinedCB:     blockMined}
+		BlockMinedCB:     blockMined,
+		BlockUndoneCB:    blockUndone}
 
 	sta := time.Now()
 	common.BlockChain = chain.NewChainExt(common.DuodHomeDir, common.GenesisBlock, common.FLAG.Rescan, ext,
@@ -12,8 +12,12 @@ import (
 
 	"github.com/ParallelCoinTeam/duod"
 	"github.com/ParallelCoinTeam/duod/client/common"
+	"github.com/ParallelCoinTeam/duod/client/electrum"
+	"github.com/ParallelCoinTeam/duod/client/grpcapi"
+	"github.com/ParallelCoinTeam/duod/client/miner"
 	"github.com/ParallelCoinTeam/duod/client/network"
 	"github.com/ParallelCoinTeam/duod/client/rpcapi"
+	"github.com/ParallelCoinTeam/duod/client/stratum"
 	"github.com/ParallelCoinTeam/duod/client/usif"
 	"github.com/ParallelCoinTeam/duod/client/usif/textui"
 	"github.com/ParallelCoinTeam/duod/client/usif/webui"
@@ -47,11 +51,39 @@ func resetSaveTimer() {
 
 func blockMined(bl *btc.Block) {
 	network.BlockMined(bl)
+	grpcapi.BlockConnected(bl)
+	webui.BlockConnected(bl)
+	usif.IndexBlockMiner(bl)
 	if int(bl.LastKnownHeight)-int(bl.Height) < 144 { // do not run it when syncing chain
 		usif.ProcessBlockFees(bl.Height, bl)
 	}
 }
 
+// chainUpdated - call it after the chain's tip may have changed (and common.Last is updated)
+func chainUpdated() {
+	webui.ChainUpdated()
+	stratum.NewTip()
+	electrum.NewTip()
+	rpcapi.NewTip()
+	miner.NewTip()
+}
+
+func blockUndone(bl *btc.Block) {
+	webui.BlockDisconnected(bl)
+	usif.BlockMinerUndone(bl)
+}
+
+func txAccepted(t2s *network.OneTxToSend) {
+	grpcapi.TxAccepted(t2s)
+	webui.TxAccepted(t2s)
+	electrum.MempoolChanged(t2s)
+}
+
+func txRemoved(t2s *network.OneTxToSend, reason byte) {
+	webui.TxRemoved(t2s, reason)
+	electrum.MempoolChanged(t2s)
+}
+
 // LocalAcceptBlock -
 func LocalAcceptBlock(newbl *network.BlockRcvd) (e error) {
 	bl := newbl.Block
@@ -102,6 +134,7 @@ func LocalAcceptBlock(newbl *network.BlockRcvd) (e error) {
 		network.DiscardedBlocks[newbl.Hash.BIdx()] = true
 		network.MutexRcv.Unlock()
 	}
+	chainUpdated()
 	return
 }
 
@@ -260,6 +293,7 @@ func HandleRPCblock(msg *rpcapi.BlockSubmitted) {
 	common.Last.Time = time.Now()
 	common.Last.Block = common.BlockChain.LastBlock()
 	common.Last.Mutex.Unlock()
+	chainUpdated()
 
 	msg.Done.Done()
 }
@@ -367,7 +401,11 @@ func main() {
 		}
 
 		if common.CFG.WebUI.Interface != "" {
-			L.Infof("Starting WebUI at http://%s\n", common.CFG.WebUI.Interface)
+			proto := "http"
+			if common.CFG.WebUI.TLS {
+				proto = "https"
+			}
+			L.Infof("Starting WebUI at %s://%s\n", proto, common.CFG.WebUI.Interface)
 			go webui.ServerThread(common.CFG.WebUI.Interface)
 		}
 
@@ -375,7 +413,29 @@ func main() {
 			go rpcapi.StartServer(common.RPCPort())
 		}
 
+		if common.CFG.GRPC.Enabled {
+			go grpcapi.StartServer(common.GRPCPort())
+		}
+
+		if common.CFG.Stratum.Enabled {
+			go stratum.StartServer()
+		}
+
+		if common.CFG.Electrum.Enabled {
+			go electrum.StartServer()
+		}
+
+		rpcapi.GenerateStart = miner.Start
+		rpcapi.GenerateStop = miner.Stop
+		rpcapi.GenerateStatus = func() interface{} { return miner.GetStatus() }
+
+		network.TxAcceptedCB = txAccepted
+		network.TxRemovedCB = txRemoved
+		wallet.TxNotifyAddCB = webui.UTXOAdded
+		wallet.TxNotifyDelCB = webui.UTXOSpent
+
 		usif.LoadBlockFees()
+		usif.LoadMinersIdx()
 
 		wallet.FetchingBalanceTick = func() bool {
 			select {
@@ -499,6 +559,7 @@ func main() {
 				common.Busy()
 				peersdb.ExpirePeers()
 				usif.ExpireBlockFees()
+				usif.ExpireMinersIdx()
 
 			case on := <-wallet.OnOff:
 				common.Busy()
@@ -524,6 +585,9 @@ func main() {
 	L.Debug("Blockchain closed in ", time.Now().Sub(sta).String())
 	peersdb.ClosePeerDB()
 	usif.SaveBlockFees()
+	usif.SaveMinersIdx()
+	electrum.SaveIndex()
+	rpcapi.DeleteCookie()
 	sys.UnlockDatabaseDir()
 	os.RemoveAll(common.TempBlocksDir())
 	L.Debug("Completed shutdown")
@@ -0,0 +1,286 @@
+// Package miner - CPU miner for development networks
+package miner
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ParallelCoinTeam/duod/client/common"
+	"github.com/ParallelCoinTeam/duod/client/rpcapi"
+	"github.com/ParallelCoinTeam/duod/lib/btc"
+	"github.com/ParallelCoinTeam/duod/lib/L"
+)
+
+const (
+	// CoinbaseTag - put into the coinbase of the mined blocks
+	CoinbaseTag = "/Duod-cpu/"
+	// RefreshTime - how often the block template is rebuilt (to include new transactions)
+	RefreshTime = 30 * time.Second
+	// Extranonce1Size - bytes of the extranonce with the thread's index
+	Extranonce1Size = 4
+	// Extranonce2Size - bytes of the extranonce incremented by each thread
+	Extranonce2Size = 4
+
+	hashesPerCheck = 0x10000 // how often a thread checks for a new job
+)
+
+var (
+	mutex   sync.Mutex
+	running bool
+	quit    chan bool
+	wg      sync.WaitGroup
+	threads int
+	address string
+
+	job     *rpcapi.Job
+	jobMut  sync.Mutex
+	tipChan = make(chan bool, 1)
+
+	hashes    uint64 // counted by the threads
+	hrate     float64
+	hrateMut  sync.Mutex
+	foundCnt  uint32
+	jobCnt    uint64
+	startTime time.Time
+)
+
+// Status - the miner's state, as shown by "gen" command and the RPC
+type Status struct {
+	Generate bool    `json:"generate"`
+	Threads  int     `json:"threads,omitempty"`
+	Address  string  `json:"address,omitempty"`
+	Hashrate float64 `json:"hashespersec"`
+	Found    uint32  `json:"blocksfound"`
+	Uptime   int64   `json:"uptime,omitempty"`
+}
+
+// GetStatus -
+func GetStatus() (s Status) {
+	mutex.Lock()
+	s.Generate = running
+	if running {
+		s.Threads = threads
+		s.Address = address
+		s.Uptime = int64(time.Now().Sub(startTime).Seconds())
+	}
+	mutex.Unlock()
+	s.Hashrate = Hashrate()
+	s.Found = atomic.LoadUint32(&foundCnt)
+	return
+}
+
+// Hashrate - hashes per second, measured over the last few seconds
+func Hashrate() float64 {
+	hrateMut.Lock()
+	defer hrateMut.Unlock()
+	return hrate
+}
+
+// NewTip - call it when the chain's tip changes (it does not block)
+func NewTip() {
+	select {
+	case tipChan <- true:
+	default:
+	}
+}
+
+// Start - starts mining to the given address (if empty, to the payouts of the Mining config section).
+// It refuses to mine on mainnet, unless force is set.
+func Start(nthreads int, addr string, force bool) error {
+	if !common.Testnet && !force {
+		return errors.New("CPU mining on mainnet needs to be forced")
+	}
+	var payouts []common.Payout
+	var er error
+	if addr != "" {
+		payouts, er = common.ParsePayouts(addr, nil)
+	} else {
+		payouts, er = common.MiningPayouts()
+		common.LockCfg()
+		addr = common.CFG.Mining.PayoutAddress
+		common.UnlockCfg()
+	}
+	if er != nil {
+		return er
+	}
+	if nthreads <= 0 {
+		nthreads = 1
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if running {
+		return errors.New("already mining - stop it first")
+	}
+	if er = makeJob(payouts); er != nil {
+		return er
+	}
+	running = true
+	threads = nthreads
+	address = addr
+	startTime = time.Now()
+	quit = make(chan bool)
+	wg.Add(nthreads + 1)
+	go jobMaker(payouts, quit)
+	for i := 0; i < nthreads; i++ {
+		go worker(uint32(i), quit)
+	}
+	L.Info("CPU miner started with ", nthreads, " thread(s), paying to ", addr)
+	return nil
+}
+
+// Stop - stops mining and waits for the threads to finish
+func Stop() {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if !running {
+		return
+	}
+	close(quit)
+	wg.Wait()
+	running = false
+	hrateMut.Lock()
+	hrate = 0
+	hrateMut.Unlock()
+	L.Info("CPU miner stopped")
+}
+
+// makeJob - builds a new job from the current block template
+func makeJob(payouts []common.Payout) error {
+	j, er := rpcapi.NewJob(fmt.Sprintf("%x", atomic.AddUint64(&jobCnt, 1)), &rpcapi.CoinbaseParams{
+		Payouts: payouts, Tag: CoinbaseTag, ExtranonceSize: Extranonce1Size + Extranonce2Size})
+	if er != nil {
+		return er
+	}
+	jobMut.Lock()
+	job = j
+	jobMut.Unlock()
+	return nil
+}
+
+func currentJob() *rpcapi.Job {
+	jobMut.Lock()
+	defer jobMut.Unlock()
+	return job
+}
+
+// jobMaker - rebuilds the job on every new tip and periodically, and measures the hashrate
+func jobMaker(payouts []common.Payout, quit chan bool) {
+	defer wg.Done()
+	refresh := time.NewTicker(RefreshTime)
+	defer refresh.Stop()
+	meter := time.NewTicker(5 * time.Second)
+	defer meter.Stop()
+	lastHashes, lastTime := atomic.LoadUint64(&hashes), time.Now()
+	for {
+		select {
+		case <-quit:
+			return
+		case <-tipChan:
+		case <-refresh.C:
+		case now := <-meter.C:
+			cnt := atomic.LoadUint64(&hashes)
+			hrateMut.Lock()
+			hrate = float64(cnt-lastHashes) / now.Sub(lastTime).Seconds()
+			hrateMut.Unlock()
+			lastHashes, lastTime = cnt, now
+			continue
+		}
+		if er := makeJob(payouts); er != nil {
+			L.Error("CPU miner: ", er.Error())
+		}
+	}
+}
+
+// targetLE - the block's target as little endian bytes (like the hashes)
+func targetLE(j *rpcapi.Job) (res [32]byte) {
+	b := j.Target.Bytes()
+	for i := range b {
+		res[i] = b[len(b)-1-i]
+	}
+	return
+}
+
+// hashBelow - checks if the (little endian) hash does not exceed the target
+func hashBelow(h, target *[32]byte) bool {
+	for i := 31; i >= 0; i-- {
+		if h[i] != target[i] {
+			return h[i] < target[i]
+		}
+	}
+	return true
+}
+
+// worker - one mining thread
+// Each thread has its own extranonce1 and increments extranonce2 when the nonce range is exhausted.
+func worker(idx uint32, quit chan bool) {
+	defer wg.Done()
+	en1 := make([]byte, Extranonce1Size)
+	binary.BigEndian.PutUint32(en1, idx)
+	en2 := make([]byte, Extranonce2Size)
+	var en2cnt uint32
+
+	for {
+		j := currentJob()
+		binary.BigEndian.PutUint32(en2, en2cnt)
+		en2cnt++
+		cb := j.Coinbase(en1, en2)
+		hdr := j.Header(cb, j.Curtime, 0)
+		target := targetLE(j)
+
+		for nonce := uint32(0); ; nonce++ {
+			binary.LittleEndian.PutUint32(hdr[76:80], nonce)
+			h := btc.Sha2Sum(hdr) // the proof of work is the block's hash
+			if hashBelow(&h, &target) {
+				atomic.AddUint64(&hashes, uint64(nonce&(hashesPerCheck-1))+1)
+				submit(j, hdr, cb)
+				// do not mine on top of the old tip
+				for currentJob() == j {
+					select {
+					case <-quit:
+						return
+					case <-time.After(100 * time.Millisecond):
+					}
+				}
+				break
+			}
+			if nonce&(hashesPerCheck-1) == hashesPerCheck-1 {
+				atomic.AddUint64(&hashes, hashesPerCheck)
+				select {
+				case <-quit:
+					return
+				default:
+				}
+				if currentJob() != j {
+					break
+				}
+			}
+			if nonce == math.MaxUint32 {
+				break
+			}
+		}
+	}
+}
+
+// submit - passes the solved block to the chain
+func submit(j *rpcapi.Job, hdr, cb []byte) {
+	raw := j.Block(hdr, cb)
+	L.Info("CPU miner: block ", j.Height, " found - ", btc.NewSha2Hash(hdr).String())
+	res, er := rpcapi.SubmitRawBlock(raw)
+	if er != nil {
+		res = er.Error()
+	}
+	if res != "" {
+		L.Error("CPU miner: block rejected: ", res)
+		common.CountSafe("MinerBlockRejected")
+		return
+	}
+	atomic.AddUint32(&foundCnt, 1)
+	common.CountSafe("MinerBlockOK")
+	NewTip()
+}
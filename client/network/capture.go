@@ -0,0 +1,95 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ParallelCoinTeam/duod/client/common"
+	"github.com/ParallelCoinTeam/duod/lib/others/netcap"
+)
+
+// StartCapture - writes all the messages sent and received by this connection to the given file
+func (c *OneConnection) StartCapture(fn string) error {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	if c.capFile != nil {
+		return errors.New("Already capturing to " + c.X.CaptureFile)
+	}
+	f, er := os.Create(fn)
+	if er != nil {
+		return er
+	}
+	w, er := netcap.NewWriter(f, common.Magic)
+	if er != nil {
+		f.Close()
+		return er
+	}
+	c.capFile = f
+	c.capWriter = w
+	c.X.CaptureFile = fn
+	return nil
+}
+
+// StopCapture -
+func (c *OneConnection) StopCapture() {
+	c.Mutex.Lock()
+	c.stopCapture()
+	c.Mutex.Unlock()
+}
+
+// stopCapture - call it with c.Mutex locked
+func (c *OneConnection) stopCapture() {
+	if c.capFile != nil {
+		c.capFile.Close()
+		c.capFile = nil
+		c.capWriter = nil
+		c.X.CaptureFile = ""
+	}
+}
+
+// captureMsg - call it with c.Mutex locked
+func (c *OneConnection) captureMsg(out bool, cmd string, pl []byte) {
+	if c.capWriter == nil {
+		return
+	}
+	if er := c.capWriter.Write(&netcap.Record{Time: time.Now(), Out: out, Cmd: cmd, Payload: pl}); er != nil {
+		println("Capture of", c.ConnID, "aborted:", er.Error())
+		c.stopCapture()
+	}
+}
+
+// CaptureConn - start or stop capturing messages of the given connection
+// If fn is empty, a new file in the "capture" folder is created. Returns the name of the capture file.
+func CaptureConn(conid uint32, fn string, stop bool) (string, error) {
+	var conn *OneConnection
+	MutexNet.Lock()
+	for _, v := range OpenCons {
+		if v.ConnID == conid {
+			conn = v
+			break
+		}
+	}
+	MutexNet.Unlock()
+	if conn == nil {
+		return "", errors.New("There is no such an active connection")
+	}
+
+	if stop {
+		conn.Mutex.Lock()
+		fn = conn.X.CaptureFile
+		conn.stopCapture()
+		conn.Mutex.Unlock()
+		return fn, nil
+	}
+
+	if fn == "" {
+		dir := common.DuodHomeDir + "capture" + string(os.PathSeparator)
+		os.MkdirAll(dir, 0700)
+		ip := conn.PeerAddr.IPv4
+		fn = fmt.Sprintf("%s%d_%d.%d.%d.%d_%s.cap", dir, conid, ip[0], ip[1], ip[2], ip[3],
+			time.Now().Format("20060102_150405"))
+	}
+	return fn, conn.StartCapture(fn)
+}
@@ -102,7 +102,7 @@ func (c *OneConnection) SendCompactBlock(hash *btc.Uint256) bool {
 	k1 := binary.LittleEndian.Uint64(crec.BIP152[16:24])
 
 	msg := new(bytes.Buffer)
-	msg.Write(crec.Data[:80])
+	msg.Write(crec.Data[:crec.Block.HeaderLen()]) // with AuxPoW of merge-mined blocks
 	msg.Write(crec.BIP152[:8])
 	btc.WriteVlen(msg, uint64(len(crec.Block.Txs)-1)) // all except coinbase
 	for i := 1; i < len(crec.Block.Txs); i++ {
@@ -191,7 +191,8 @@ func delB2Gcallback(hash *btc.Uint256) {
 // ProcessCompactBlock -
 func (c *OneConnection) ProcessCompactBlock(pl []byte) {
 	L.Debug("Processing compact block")
-	if len(pl) < 90 {
+	hl := btc.AuxPowHeaderLen(pl) // headers of merge-mined blocks are followed by AuxPoW data
+	if hl == 0 || len(pl) < hl+10 {
 		L.Debug(c.ConnID, c.PeerAddr.IP(), c.Node.Agent, "cmpctblock error A", hex.EncodeToString(pl))
 		c.DoS("CmpctBlkErrA")
 		return
@@ -200,10 +201,13 @@ func (c *OneConnection) ProcessCompactBlock(pl []byte) {
 	MutexRcv.Lock()
 	defer MutexRcv.Unlock()
 
-	var tmpHdr [81]byte
-	copy(tmpHdr[:80], pl[:80])
-	sta, b2g := c.ProcessNewHeader(tmpHdr[:]) // ProcessNewHeader() needs byte(0) after the header,
-	// but don't try to change it to ProcessNewHeader(append(pl[:80], 0)) as it'd overwrite pl[80]
+	// ProcessNewHeader() needs byte(0) after the header, so make a copy not to overwrite pl[hl]
+	tmpHdr := append(append(make([]byte, 0, hl+1), pl[:hl]...), 0)
+	if c.lowWorkHeaders([][]byte{tmpHdr}) {
+		common.CountSafe("CmpctBlockLowWork")
+		return
+	}
+	sta, b2g := c.ProcessNewHeader(tmpHdr)
 
 	if b2g == nil {
 		common.CountSafe("CmpctBlockHdrNo")
@@ -248,9 +252,9 @@ func (c *OneConnection) ProcessCompactBlock(pl []byte) {
 	var n, idx, shortidscnt, shortIdxIdx, prefilledcnt int
 
 	col := new(CompactBlockCollector)
-	col.Header = b2g.Block.Raw[:80]
+	col.Header = b2g.Block.Raw[:b2g.Block.HeaderLen()]
 
-	offs := 88
+	offs := hl + 8
 	shortidscnt, n = btc.VLen(pl[offs:])
 	if shortidscnt < 0 || n > 3 {
 		L.Debug(c.ConnID, c.PeerAddr.IP(), c.Node.Agent, "cmpctblock error B", hex.EncodeToString(pl))
@@ -301,9 +305,10 @@ func (c *OneConnection) ProcessCompactBlock(pl []byte) {
 		exp = int(idx) + 1
 	}
 
-	// calculate K0 and K1 params for siphash-4-2
+	// calculate K0 and K1 params for siphash-4-2 (AuxPoW is not a part of it)
 	sha := sha256.New()
-	sha.Write(pl[:88])
+	sha.Write(pl[:80])
+	sha.Write(pl[hl : hl+8])
 	kks := sha.Sum(nil)
 	col.K0 = binary.LittleEndian.Uint64(kks[0:8])
 	col.K1 = binary.LittleEndian.Uint64(kks[8:16])
@@ -421,7 +426,7 @@ func (c *OneConnection) ProcessCompactBlock(pl []byte) {
 		orb := &OneReceivedBlock{TmStart: b2g.Started, TmPreproc: time.Now(), FromConID: c.ConnID, DoInvs: b2g.SendInvs}
 		ReceivedBlocks[bidx] = orb
 		DelB2G(bidx) //remove it from BlocksToGet if no more pending downloads
-		if c.X.Authorized {
+		if c.X.Permissions.Has(common.PermDownload) {
 			b2g.Block.Trusted = true
 		}
 		NetBlocks <- &BlockRcvd{Conn: c, Block: b2g.Block, BlockTreeNode: b2g.BlockTreeNode, OneReceivedBlock: orb}
@@ -558,7 +563,7 @@ func (c *OneConnection) ProcessBlockTx(pl []byte) {
 	orb := &OneReceivedBlock{TmStart: b2g.Started, TmPreproc: b2g.TmPreproc,
 		TmDownload: c.LastMsgTime, TxMissing: col.Missing, FromConID: c.ConnID, DoInvs: b2g.SendInvs}
 	ReceivedBlocks[idx] = orb
-	if c.X.Authorized {
+	if c.X.Permissions.Has(common.PermDownload) {
 		b2g.Block.Trusted = true
 	}
 	NetBlocks <- &BlockRcvd{Conn: c, Block: b2g.Block, BlockTreeNode: b2g.BlockTreeNode, OneReceivedBlock: orb}
@@ -8,6 +8,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"net"
+	"os"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -16,6 +17,7 @@ import (
 	"github.com/ParallelCoinTeam/duod/client/common"
 	"github.com/ParallelCoinTeam/duod/lib/btc"
 	"github.com/ParallelCoinTeam/duod/lib/L"
+	"github.com/ParallelCoinTeam/duod/lib/others/netcap"
 	"github.com/ParallelCoinTeam/duod/lib/others/peersdb"
 )
 
@@ -77,6 +79,8 @@ var (
 	InConsActive uint32
 	// OutConsActive -
 	OutConsActive uint32
+	// BlockRelayConsActive - block-relay-only outgoing connections (not included in OutConsActive)
+	BlockRelayConsActive uint32
 	// LastConnID -
 	LastConnID uint32
 	nonce      [8]byte
@@ -140,10 +144,20 @@ type ConnectionStatus struct {
 	IsSpecial bool // Special connections get more debgs and are not being automatically dropped
 	IsDuod    bool
 
-	Authorized bool
+	Authorized bool // peer has proven one of the whitelisted (or friends.txt) auth keys
 	AuthMsgGot uint
 	AuthAckGot bool
 
+	Permissions common.PeerPerms // from the whitelist rules
+
+	BlockRelayOnly bool // outgoing connection that does not relay transactions nor addresses
+
+	CaptureFile string // all the messages are being written to this file (if not empty)
+
+	HdrsPresync       string // headers pre-sync phase ("presync" or "redownload"), if in progress
+	HdrsPresyncMem    int
+	HdrsPresyncHeight uint32
+
 	LastMinFeePerKByte uint64
 
 	PingSentCnt   uint64
@@ -165,6 +179,7 @@ type ConnInfo struct {
 	InvsDone         int
 	BlocksReceived   int
 	GetMPInProgress  bool
+	PermissionList   string
 
 	LocalAddr, RemoteAddr string
 
@@ -236,6 +251,14 @@ type OneConnection struct {
 	writingThreadPush chan bool
 
 	GetMP chan bool
+
+	authPubkey []byte // the key the peer has authorized with
+	permsGen   uint32 // common.WhitelistGen() at the time of the last permissions update
+
+	hdrSync *headersPresync // protected by MutexRcv
+
+	capFile   *os.File
+	capWriter *netcap.Writer
 }
 
 // BIDX -
@@ -320,7 +343,30 @@ func (c *OneConnection) GetStats(res *ConnInfo) {
 	res.InvsDone = len(c.InvDone.History)
 	res.BlocksReceived = len(c.blocksreceived)
 	res.GetMPInProgress = len(c.GetMP) != 0
+	res.PermissionList = c.X.Permissions.String()
+
+	c.Mutex.Unlock()
+}
 
+// UpdatePermissions - (re)calculates the peer's permissions from the current whitelist rules
+func (c *OneConnection) UpdatePermissions() {
+	gen := common.WhitelistGen()
+	perms := common.WhitelistIP(c.PeerAddr.IPv4)
+	c.Mutex.Lock()
+	if c.authPubkey != nil {
+		perms |= common.WhitelistPubkey(c.authPubkey)
+		for _, pk := range AuthPubkeys {
+			if bytes.Equal(pk, c.authPubkey) {
+				perms |= common.PermDefault // keys from friends.txt
+				break
+			}
+		}
+	}
+	if c.PeerAddr.Friend {
+		perms |= common.PermNoBan
+	}
+	c.X.Permissions = perms
+	c.permsGen = gen
 	c.Mutex.Unlock()
 }
 
@@ -340,6 +386,7 @@ func (c *OneConnection) SendRawMsg(cmd string, pl []byte) (e error) {
 
 		c.counters["sent_"+cmd]++
 		c.counters["sbts_"+cmd] += uint64(len(pl))
+		c.captureMsg(true, cmd, pl)
 
 		common.CountSafe("sent_" + cmd)
 		common.CountSafeAdd("sbts_"+cmd, uint64(len(pl)))
@@ -549,6 +596,7 @@ func (c *OneConnection) FetchMessage() (ret *BCmsg, timeoutOrData bool) {
 	ret.pl = c.recv.dat
 
 	c.Mutex.Lock()
+	c.captureMsg(false, ret.cmd, ret.pl)
 	c.recv.hdrLen = 0
 	c.recv.cmd = ""
 	c.recv.dat = nil
@@ -561,7 +609,7 @@ func (c *OneConnection) FetchMessage() (ret *BCmsg, timeoutOrData bool) {
 
 // GetMPNow -
 func (c *OneConnection) GetMPNow() {
-	if c.X.Authorized && common.GetBool(&common.CFG.TXPool.Enabled) {
+	if c.X.Permissions.Has(common.PermMempool) && !c.X.BlockRelayOnly && common.GetBool(&common.CFG.TXPool.Enabled) {
 		select {
 		case c.GetMP <- true:
 		default:
@@ -703,6 +751,20 @@ func DropPeer(conid uint32) {
 	L.Debug("DropPeer: There is no such an active connection", conid)
 }
 
+// DisconnectPeer - like DropPeer, but the peer does not get banned
+func DisconnectPeer(conid uint32) {
+	MutexNet.Lock()
+	defer MutexNet.Unlock()
+	for _, v := range OpenCons {
+		if uint32(conid) == v.ConnID {
+			v.Disconnect("FromRPC")
+			L.Debug("The connection with", v.PeerAddr.IP(), "is being dropped")
+			return
+		}
+	}
+	L.Debug("DisconnectPeer: There is no such an active connection", conid)
+}
+
 // GetMP -
 func GetMP(conid uint32) {
 	MutexNet.Lock()
@@ -64,6 +64,10 @@ func (c *OneConnection) ProcessGetData(pl []byte) {
 			}
 		} else if typ == MsgTx || typ == MsgWitnessTx {
 			// transaction
+			if c.X.BlockRelayOnly {
+				common.CountSafe("GetdataTxBlockRelay")
+				continue
+			}
 			TxMutex.Lock()
 			if tx, ok := TransactionsToSend[btc.NewUint256(h[4:]).BIdx()]; ok && tx.Blocked == 0 {
 				tx.SentCnt++
@@ -109,7 +113,12 @@ func netBlockReceived(conn *OneConnection, b []byte) {
 		return
 	}
 
-	hash := btc.NewSha2Hash(b[:80])
+	bl, er := btc.NewBlock(b) // to know the length of the header with AuxPoW
+	if er != nil {
+		conn.DoS("BadBlockHdr")
+		return
+	}
+	hash := bl.Hash
 	idx := hash.BIdx()
 	//println("got block data", hash.String())
 
@@ -131,10 +140,14 @@ func netBlockReceived(conn *OneConnection, b []byte) {
 	if b2g == nil {
 		//println("Block", hash.String(), " from", conn.PeerAddr.IP(), conn.Node.Agent, " was not expected")
 
-		var hdr [81]byte
 		var sta int
-		copy(hdr[:80], b[:80])
-		sta, b2g = conn.ProcessNewHeader(hdr[:])
+		hdr := append(append([]byte{}, b[:bl.HeaderLen()]...), 0)
+		if conn.lowWorkHeaders([][]byte{hdr}) {
+			common.CountSafe("UnreqBlockLowWork")
+			MutexRcv.Unlock()
+			return
+		}
+		sta, b2g = conn.ProcessNewHeader(hdr)
 		if b2g == nil {
 			if sta == PHstatusFatal {
 				L.Debug("Unrequested Block: FAIL - Ban", conn.PeerAddr.IP(), conn.Node.Agent)
@@ -154,12 +167,12 @@ func netBlockReceived(conn *OneConnection, b []byte) {
 	}
 
 	//println("block", b2g.BlockTreeNode.Height," len", len(b), " got from", conn.PeerAddr.IP(), b2g.InProgress)
-	b2g.Block.Raw = b
-	if conn.X.Authorized {
+	b2g.Block.UpdateContent(b) // it has been parsed above, so it cannot fail
+	if conn.X.Permissions.Has(common.PermDownload) {
 		b2g.Block.Trusted = true
 	}
 
-	er := common.BlockChain.PostCheckBlock(b2g.Block)
+	er = common.BlockChain.PostCheckBlock(b2g.Block)
 	if er != nil {
 		b2g.InProgress--
 		L.Debug("Corrupt block received from", conn.PeerAddr.IP(), er.Error())
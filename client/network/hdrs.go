@@ -84,36 +84,60 @@ func (c *OneConnection) ProcessNewHeader(hdr []byte) (int, *OneBlockToGet) {
 func (c *OneConnection) HandleHeaders(pl []byte) (newHeadersGot int) {
 	var highestBlockFound uint32
 
-	c.MutexSetBool(&c.X.GetHeadersInProgress, false)
-
 	b := bytes.NewReader(pl)
 	cnt, e := btc.ReadVLen(b)
 	if e != nil {
+		c.MutexSetBool(&c.X.GetHeadersInProgress, false)
 		L.Debug("HandleHeaders:", e.Error(), c.PeerAddr.IP())
 		return
 	}
+	pl = pl[len(pl)-b.Len():]
 
 	if cnt > 0 {
-		MutexRcv.Lock()
-		defer MutexRcv.Unlock()
-
-		for i := 0; i < int(cnt); i++ {
-			var hdr [81]byte
-
-			n, _ := b.Read(hdr[:])
-			if n != 81 {
-				L.Debug("HandleHeaders: pl too short", c.PeerAddr.IP())
+		hdrs := make([][]byte, int(cnt))
+		for i := range hdrs {
+			// headers of merge-mined blocks are followed by AuxPoW data
+			hl := btc.AuxPowHeaderLen(pl)
+			if hl == 0 || len(pl) <= hl {
+				L.Debug("HandleHeaders: pl too short or AuxPoW corrupt", c.PeerAddr.IP())
 				c.DoS("HdrErr1")
 				return
 			}
 
-			if hdr[80] != 0 {
+			if pl[hl] != 0 {
 				L.Debug("Unexpected value of txn_count from", c.PeerAddr.IP())
 				c.DoS("HdrErr2")
 				return
 			}
+			hdrs[i] = append([]byte{}, pl[:hl+1]...)
+			pl = pl[hl+1:]
+		}
+
+		MutexRcv.Lock()
+		defer MutexRcv.Unlock()
+
+		if c.hdrSync != nil && !c.hdrSync.connects(hdrs[0]) {
+			// most likely an announcement of the peer's new tip, so not the answer we wait for
+			common.CountSafe("PresyncHdrsIgnored")
+			return
+		}
+
+		c.MutexSetBool(&c.X.GetHeadersInProgress, false)
+
+		if c.hdrSync != nil {
+			newHeadersGot = c.continuePresync(hdrs)
+			return
+		}
+
+		if c.lowWorkHeaders(hdrs) {
+			if c.hdrSync != nil {
+				return // pre-sync in progress - do not ask for headers again
+			}
+			hdrs = nil
+		}
 
-			sta, b2g := c.ProcessNewHeader(hdr[:])
+		for i := range hdrs {
+			sta, b2g := c.ProcessNewHeader(hdrs[i])
 			if b2g == nil {
 				if sta == PHstatusFatal {
 					L.Debug("c.DoS(BadHeader)")
@@ -144,6 +168,13 @@ func (c *OneConnection) HandleHeaders(pl []byte) (newHeadersGot int) {
 				}
 			}
 		}
+	} else {
+		c.MutexSetBool(&c.X.GetHeadersInProgress, false)
+		MutexRcv.Lock()
+		if c.hdrSync != nil {
+			c.abortPresync("PresyncNoHdrs") // the peer has nothing more to give us
+		}
+		MutexRcv.Unlock()
 	}
 
 	c.Mutex.Lock()
@@ -204,8 +235,7 @@ func (c *OneConnection) GetHeaders(pl []byte) {
 		bestBlock = common.BlockChain.BlockTreeRoot
 	}
 
-	var resp []byte
-	var cnt uint32
+	var nodes []*chain.BlockTreeNode
 
 	defer func() {
 		// If we get a hash of an old orphaned blocks, FindPathTo() will panic, so...
@@ -215,6 +245,19 @@ func (c *OneConnection) GetHeaders(pl []byte) {
 
 		common.BlockChain.BlockIndexAccess.Unlock()
 
+		// AuxPoW data is read from the blocks database, so do it with BlockIndexAccess unlocked
+		var resp []byte
+		var cnt uint32
+		for _, n := range nodes {
+			hdr := fullHeader(n)
+			if hdr == nil {
+				common.CountSafe("GetHeadersNoAuxPow")
+				break
+			}
+			resp = append(resp, hdr...)
+			cnt++
+		}
+
 		// send the response
 		out := new(bytes.Buffer)
 		btc.WriteVlen(out, uint64(cnt))
@@ -222,7 +265,7 @@ func (c *OneConnection) GetHeaders(pl []byte) {
 		c.SendRawMsg("headers", out.Bytes())
 	}()
 
-	for cnt < 2000 {
+	for len(nodes) < 2000 {
 		if lastBlock.Height <= bestBlock.Height {
 			break
 		}
@@ -230,8 +273,7 @@ func (c *OneConnection) GetHeaders(pl []byte) {
 		if bestBlock == nil {
 			break
 		}
-		resp = append(resp, append(bestBlock.BlockHeader[:], 0)...) // 81st byte is always zero
-		cnt++
+		nodes = append(nodes, bestBlock)
 	}
 
 	// Note: the deferred function will be called before exiting
@@ -239,6 +281,23 @@ func (c *OneConnection) GetHeaders(pl []byte) {
 	return
 }
 
+// fullHeader - the block header, with AuxPoW data (if any), followed by zero txn_count
+// Returns nil for a merge-mined block whose data we do not have (yet).
+func fullHeader(n *chain.BlockTreeNode) []byte {
+	if n.BlockVersion()&btc.AuxPowVersion == 0 {
+		return append(n.BlockHeader[:], 0)
+	}
+	crec, _, er := common.BlockChain.Blocks.BlockGetInternal(n.BlockHash, true)
+	if er != nil {
+		return nil
+	}
+	hl := btc.AuxPowHeaderLen(crec.Data)
+	if hl == 0 {
+		return nil
+	}
+	return append(crec.Data[:hl:hl], 0)
+}
+
 func (c *OneConnection) sendGetHeaders() {
 	MutexRcv.Lock()
 	lb := LastCommitedHeader
@@ -269,15 +328,29 @@ func (c *OneConnection) sendGetHeaders() {
 			step = step * 2
 		}
 	}
+	c.sendGetHeadersMsg(blks.Bytes(), cnt, lb.Height)
+}
+
+// sendGetHeadersFrom - ask for headers following the given one (used by headers pre-sync)
+// Call it with MutexRcv locked.
+func (c *OneConnection) sendGetHeadersFrom(hash *btc.Uint256, height uint32) {
+	c.sendGetHeadersMsg(hash.Hash[:], 1, height)
+	if c.hdrSync != nil {
+		c.hdrSync.timeout = time.Now().Add(PresyncTimeout)
+	}
+}
+
+func (c *OneConnection) sendGetHeadersMsg(locators []byte, cnt uint64, height uint32) {
 	var nullStop [32]byte
-	blks.Write(nullStop[:])
 
 	bhdr := new(bytes.Buffer)
 	binary.Write(bhdr, binary.LittleEndian, common.Version)
 	btc.WriteVlen(bhdr, cnt)
+	bhdr.Write(locators)
+	bhdr.Write(nullStop[:])
 
-	c.SendRawMsg("getheaders", append(bhdr.Bytes(), blks.Bytes()...))
-	c.X.LastHeadersHeightAsk = lb.Height
+	c.SendRawMsg("getheaders", bhdr.Bytes())
+	c.X.LastHeadersHeightAsk = height
 	c.MutexSetBool(&c.X.GetHeadersInProgress, true)
 	c.X.GetHeadersTimeout = time.Now().Add(GetHeadersTimeout)
 }
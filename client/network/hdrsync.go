@@ -0,0 +1,325 @@
+package network
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"time"
+
+	"github.com/ParallelCoinTeam/duod/client/common"
+	"github.com/ParallelCoinTeam/duod/lib/btc"
+	"github.com/ParallelCoinTeam/duod/lib/chain"
+	"github.com/ParallelCoinTeam/duod/lib/L"
+)
+
+// Headers pre-sync (anti-DoS):
+// A chain of headers that does not have enough work is not stored in BlockIndex straight away.
+// First we download it from the peer only to sum up its work, keeping one bit of a salted hash
+// every PresyncCommitPeriod headers. If the work turns out to be sufficient, we download the
+// same headers again, check them against the commitments and only then store them.
+
+const (
+	// MaxHeadersPerMsg - peers never send more in a single "headers" message
+	MaxHeadersPerMsg = 2000
+	// PresyncCommitPeriod - keep one commitment bit per this many headers
+	PresyncCommitPeriod = 600
+	// PresyncRedownloadBuffer - keep that many redownloaded headers before storing them
+	PresyncRedownloadBuffer = 15000
+	// PresyncMaxMemPerPeer - abort the pre-sync if its state takes more memory than this
+	PresyncMaxMemPerPeer = 2 << 20
+	// AntiDoSWorkBlocks - a headers chain is good enough if its work is not lower than our top's minus this many blocks
+	AntiDoSWorkBlocks = 144
+	// PresyncTimeout - abort the pre-sync if the peer does not send the next headers within this time
+	PresyncTimeout = 2 * time.Minute
+)
+
+var presyncSalt [32]byte
+
+type headersPresync struct {
+	fork      *chain.BlockTreeNode // the last header we know (the peer's chain starts after it)
+	threshold float64              // work that the peer's chain must reach
+	offset    uint32               // commitments are taken at heights where (height % PresyncCommitPeriod) == offset
+	timeout   time.Time            // when to give up waiting for the next headers
+
+	// First phase
+	last    *btc.Uint256
+	height  uint32
+	work    float64
+	commits []byte
+	ncommit int
+
+	// Second phase
+	redownload bool
+	rdLast     *btc.Uint256
+	rdHeight   uint32
+	rdWork     float64
+	rdCommit   int
+	rdReached  bool
+	buffer     [][]byte
+	bufferMem  int // bytes taken by the buffer
+}
+
+func (hs *headersPresync) mem() int {
+	return len(hs.commits) + hs.bufferMem + 256
+}
+
+// connects - tells if the header follows the last one that we have got in the current phase
+func (hs *headersPresync) connects(hdr []byte) bool {
+	last := hs.last
+	if hs.redownload {
+		last = hs.rdLast
+	}
+	return bytes.Equal(hdr[4:36], last.Hash[:])
+}
+
+func (hs *headersPresync) commitBit(hash *btc.Uint256) byte {
+	sh := btc.Sha2Sum(append(presyncSalt[:], hash.Hash[:]...))
+	return sh[0] & 1
+}
+
+// AntiDoSWorkThreshold - the minimum work that a headers chain must have to be stored without pre-sync
+func AntiDoSWorkThreshold() (res float64) {
+	res = common.MinChainWork()
+	common.BlockChain.BlockIndexAccess.Lock()
+	last := common.BlockChain.LastBlock()
+	if w := last.ChainWork() - AntiDoSWorkBlocks*btc.GetDifficulty(last.Bits()); w > res {
+		res = w
+	}
+	common.BlockChain.BlockIndexAccess.Unlock()
+	return
+}
+
+// checkPresyncHeader - context free checks of a header that we are not going to store (yet)
+func checkPresyncHeader(bl *btc.Block, prev *btc.Uint256) bool {
+	if !bytes.Equal(bl.ParentHash(), prev.Hash[:]) {
+		return false
+	}
+	if bl.Version() == 0 {
+		return false
+	}
+	if btc.SetCompact(bl.Bits()).Cmp(common.BlockChain.Consensus.MaxPOWValue) > 0 {
+		return false
+	}
+	if common.BlockChain.CheckProofOfWork(bl) != nil {
+		return false // this includes AuxPoW of merge-mined blocks
+	}
+	return int64(bl.BlockTime()) <= time.Now().Unix()+2*60*60
+}
+
+// lowWorkHeaders - checks if the given headers need to be pre-synced, possibly starting it
+// Also used for single headers of compact and unrequested blocks, which never start a pre-sync.
+// Call it with MutexRcv locked. Returns true if the headers shall not be processed now.
+func (c *OneConnection) lowWorkHeaders(hdrs [][]byte) bool {
+	common.BlockChain.BlockIndexAccess.Lock()
+	fork := common.BlockChain.BlockIndex[btc.NewUint256(hdrs[0][4:36]).BIdx()]
+	var work float64
+	if fork != nil {
+		work = fork.ChainWork()
+	}
+	common.BlockChain.BlockIndexAccess.Unlock()
+
+	if fork == nil {
+		return false // let ProcessNewHeader() deal with it
+	}
+
+	for i := range hdrs {
+		work += btc.GetDifficulty(binary.LittleEndian.Uint32(hdrs[i][72:76]))
+	}
+
+	threshold := AntiDoSWorkThreshold()
+	if work >= threshold {
+		return false
+	}
+
+	if len(hdrs) < MaxHeadersPerMsg {
+		// the peer has no more headers to give us, so its chain is not worth storing
+		common.CountSafe("HdrsLowWork")
+		return true
+	}
+
+	common.CountSafe("HdrsPresyncStart")
+	L.Debug(c.ConnID, "starting headers pre-sync from", fork.Height)
+	hs := &headersPresync{fork: fork, threshold: threshold, last: fork.BlockHash, height: fork.Height}
+	var rnd [4]byte
+	rand.Read(rnd[:])
+	hs.offset = binary.LittleEndian.Uint32(rnd[:]) % PresyncCommitPeriod
+	hs.work = fork.ChainWork()
+	c.hdrSync = hs
+	c.continuePresync(hdrs)
+	return true
+}
+
+// continuePresync - process headers received while pre-syncing
+// Call it with MutexRcv locked. Returns number of new headers stored.
+func (c *OneConnection) continuePresync(hdrs [][]byte) int {
+	hs := c.hdrSync
+	if hs.redownload {
+		return c.redownloadHeaders(hdrs)
+	}
+
+	for i := range hdrs {
+		bl, _ := btc.NewBlock(hdrs[i])
+		if !checkPresyncHeader(bl, hs.last) {
+			c.abortPresync("PresyncBadHdr")
+			c.Misbehave("PresyncBadHdr", 100)
+			return 0
+		}
+		hs.height++
+		hs.work += btc.GetDifficulty(bl.Bits())
+		hs.last = bl.Hash
+		if hs.height%PresyncCommitPeriod == hs.offset {
+			if hs.ncommit/8 >= len(hs.commits) {
+				hs.commits = append(hs.commits, 0)
+			}
+			hs.commits[hs.ncommit/8] |= hs.commitBit(bl.Hash) << uint(hs.ncommit%8)
+			hs.ncommit++
+		}
+	}
+
+	if !c.updatePresyncMem() {
+		return 0
+	}
+
+	if hs.work >= hs.threshold {
+		common.CountSafe("HdrsPresyncDone")
+		L.Debug(c.ConnID, "headers pre-sync reached", hs.height, "- redownloading from", hs.fork.Height)
+		hs.redownload = true
+		hs.rdLast = hs.fork.BlockHash
+		hs.rdHeight = hs.fork.Height
+		hs.rdWork = hs.fork.ChainWork()
+		c.Mutex.Lock()
+		c.X.HdrsPresync = "redownload"
+		c.Mutex.Unlock()
+		c.sendGetHeadersFrom(hs.rdLast, hs.rdHeight)
+		return 0
+	}
+
+	if len(hdrs) < MaxHeadersPerMsg {
+		c.abortPresync("HdrsPresyncLowWork")
+		return 0
+	}
+
+	c.sendGetHeadersFrom(hs.last, hs.height)
+	return 0
+}
+
+// redownloadHeaders - verify the headers against the commitments and store them
+// Call it with MutexRcv locked. Returns number of new headers stored.
+func (c *OneConnection) redownloadHeaders(hdrs [][]byte) (newHeadersGot int) {
+	hs := c.hdrSync
+	for i := range hdrs {
+		bl, _ := btc.NewBlock(hdrs[i])
+		if !checkPresyncHeader(bl, hs.rdLast) {
+			c.abortPresync("PresyncBadHdr")
+			c.Misbehave("PresyncBadHdr", 100)
+			return
+		}
+		hs.rdHeight++
+		hs.rdWork += btc.GetDifficulty(bl.Bits())
+		hs.rdLast = bl.Hash
+		if hs.rdHeight <= hs.height && hs.rdHeight%PresyncCommitPeriod == hs.offset {
+			if hs.rdCommit >= hs.ncommit ||
+				(hs.commits[hs.rdCommit/8]>>uint(hs.rdCommit%8))&1 != hs.commitBit(bl.Hash) {
+				c.abortPresync("PresyncCommitErr")
+				c.Misbehave("PresyncCommitErr", 100)
+				return
+			}
+			hs.rdCommit++
+		}
+		if hs.rdWork >= hs.threshold {
+			hs.rdReached = true
+		}
+		hs.buffer = append(hs.buffer, hdrs[i])
+		hs.bufferMem += len(hdrs[i])
+	}
+
+	// Store the headers that are now confirmed enough
+	var n int
+	if hs.rdReached {
+		n = len(hs.buffer)
+	} else if len(hs.buffer) > PresyncRedownloadBuffer {
+		n = len(hs.buffer) - PresyncRedownloadBuffer
+	}
+	for i := 0; i < n; i++ {
+		sta, _ := c.ProcessNewHeader(hs.buffer[i])
+		hs.bufferMem -= len(hs.buffer[i])
+		if sta == PHstatusError || sta == PHstatusFatal {
+			c.abortPresync("PresyncStoreErr")
+			c.Misbehave("PresyncStoreErr", 100)
+			return
+		}
+		if sta == PHstatusNew {
+			newHeadersGot++
+		}
+	}
+	hs.buffer = hs.buffer[n:]
+
+	if hs.rdReached {
+		common.CountSafe("HdrsPresyncStored")
+		L.Debug(c.ConnID, "headers pre-sync finished at", hs.rdHeight)
+		c.abortPresync("")
+		c.MutexSetBool(&c.X.GetBlocksDataNow, true)
+		if newHeadersGot == 0 {
+			newHeadersGot = 1 // make sure the caller continues with the normal sync
+		}
+		return
+	}
+
+	if !c.updatePresyncMem() {
+		return
+	}
+
+	if len(hdrs) < MaxHeadersPerMsg {
+		c.abortPresync("PresyncRedownloadShort")
+		c.Misbehave("PresyncRedownloadShort", 100)
+		return
+	}
+
+	c.sendGetHeadersFrom(hs.rdLast, hs.rdHeight)
+	return
+}
+
+// updatePresyncMem - returns false if the pre-sync has been aborted because of the memory limit
+func (c *OneConnection) updatePresyncMem() bool {
+	mem := c.hdrSync.mem()
+	if mem > PresyncMaxMemPerPeer {
+		c.abortPresync("PresyncMemLimit")
+		c.Disconnect("PresyncMemLimit")
+		return false
+	}
+	c.Mutex.Lock()
+	if c.X.HdrsPresync == "" {
+		c.X.HdrsPresync = "presync"
+	}
+	c.X.HdrsPresyncMem = mem
+	c.X.HdrsPresyncHeight = c.hdrSync.height
+	c.Mutex.Unlock()
+	return true
+}
+
+// presyncTimeout - aborts the pre-sync if the peer has stopped sending the headers
+func (c *OneConnection) presyncTimeout(now time.Time) {
+	MutexRcv.Lock()
+	if c.hdrSync != nil && now.After(c.hdrSync.timeout) {
+		c.abortPresync("PresyncTimeout")
+	}
+	MutexRcv.Unlock()
+}
+
+// abortPresync - forget the pre-sync state (give empty reason if finished successfully)
+func (c *OneConnection) abortPresync(why string) {
+	if why != "" {
+		common.CountSafe(why)
+		L.Debug(c.ConnID, "headers pre-sync aborted:", why)
+	}
+	c.hdrSync = nil
+	c.Mutex.Lock()
+	c.X.HdrsPresync = ""
+	c.X.HdrsPresyncMem = 0
+	c.X.AllHeadersReceived = why != ""
+	c.Mutex.Unlock()
+}
+
+func init() {
+	rand.Read(presyncSalt[:])
+}
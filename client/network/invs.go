@@ -105,7 +105,9 @@ func (c *OneConnection) ProcessInv(pl []byte) {
 				}
 			}
 		} else if typ == MsgTx {
-			if common.AcceptTx() {
+			if c.X.BlockRelayOnly {
+				common.CountSafe("InvTxBlockRelay")
+			} else if common.AcceptTx() {
 				c.TxInvNotify(pl[of+4 : of+36])
 			} else {
 				common.CountSafe("InvTxIgnored")
@@ -148,7 +150,7 @@ func NetRouteInvExt(typ uint32, h *btc.Uint256, fromConn *OneConnection, feeSpkb
 			sendInv := true
 			v.Mutex.Lock()
 			if typ == MsgTx {
-				if v.Node.DoNotRelayTxs {
+				if v.Node.DoNotRelayTxs || v.X.BlockRelayOnly {
 					sendInv = false
 					common.CountSafe("SendInvNoTxNode")
 				} else if v.X.MinFeeSPKB > 0 && uint64(v.X.MinFeeSPKB) > feeSpkb {
@@ -242,7 +244,7 @@ func (c *OneConnection) GetBlocks(pl []byte) {
 // SendInvs -
 func (c *OneConnection) SendInvs() (res bool) {
 	bTxs := new(bytes.Buffer)
-	bBlk := new(bytes.Buffer)
+	var hBlk []*chain.BlockTreeNode
 	var cBlk []*btc.Uint256
 
 	c.Mutex.Lock()
@@ -260,8 +262,7 @@ func (c *OneConnection) SendInvs() (res bool) {
 					common.BlockChain.BlockIndexAccess.Lock()
 					bl := common.BlockChain.BlockIndex[btc.NewUint256((*c.PendingInvs[i])[4:]).BIdx()]
 					if bl != nil {
-						bBlk.Write(bl.BlockHeader[:])
-						bBlk.Write([]byte{0}) // 0 txs
+						hBlk = append(hBlk, bl)
 					}
 					common.BlockChain.BlockIndexAccess.Unlock()
 					invSentOtherwise = true
@@ -283,12 +284,23 @@ func (c *OneConnection) SendInvs() (res bool) {
 		}
 	}
 
-	if bBlk.Len() > 0 {
-		common.CountSafe("InvSentAsHeader")
-		b := new(bytes.Buffer)
-		btc.WriteVlen(b, uint64(bBlk.Len()/81))
-		c.SendRawMsg("headers", append(b.Bytes(), bBlk.Bytes()...))
-		L.Debug("sent block's header(s)", bBlk.Len(), uint64(bBlk.Len()/81))
+	if len(hBlk) > 0 {
+		// headers of merge-mined blocks go with AuxPoW, so their length varies
+		bBlk := new(bytes.Buffer)
+		var cnt uint64
+		for _, n := range hBlk {
+			if hdr := fullHeader(n); hdr != nil {
+				bBlk.Write(hdr)
+				cnt++
+			}
+		}
+		if cnt > 0 {
+			common.CountSafe("InvSentAsHeader")
+			b := new(bytes.Buffer)
+			btc.WriteVlen(b, cnt)
+			c.SendRawMsg("headers", append(b.Bytes(), bBlk.Bytes()...))
+			L.Debug("sent block's header(s)", bBlk.Len(), cnt)
+		}
 	}
 
 	if bTxs.Len() > 0 {
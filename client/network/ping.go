@@ -94,7 +94,7 @@ func GetSortedConnections() (list SortedConnections, anyPing bool, segwitCount i
 		tlist[cnt].Ping = v.GetAveragePing()
 		tlist[cnt].BlockCount = len(v.blocksreceived)
 		tlist[cnt].TxsCount = v.X.TxsReceived
-		tlist[cnt].Special = v.X.IsSpecial
+		tlist[cnt].Special = v.X.IsSpecial || v.X.Permissions.Has(common.PermNoBan)
 		if v.X.VersionReceived == false || v.X.ConnectedAt.IsZero() {
 			tlist[cnt].MinutesOnline = 0
 		} else {
@@ -169,7 +169,7 @@ func dropWorstPeer() bool {
 		if v.MinutesOnline < OnlineImmunityMinutes {
 			continue
 		}
-		if v.Special {
+		if v.Special || v.Conn.X.BlockRelayOnly {
 			continue
 		}
 		if common.CFG.Net.MinSegwitCons > 0 && segwitCount <= int(common.CFG.Net.MinSegwitCons) &&
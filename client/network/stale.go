@@ -0,0 +1,119 @@
+package network
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ParallelCoinTeam/duod/client/common"
+	"github.com/ParallelCoinTeam/duod/lib/chain"
+	"github.com/ParallelCoinTeam/duod/lib/L"
+)
+
+const (
+	// StaleTipSpacings - the tip is considered stale if no new block was accepted during that many target spacings
+	StaleTipSpacings = 3
+	// StaleTipCheckEvery - how often to check for the stale tip (and rotate peers while it is stale)
+	StaleTipCheckEvery = 10 * time.Minute
+)
+
+var (
+	staleTipMutex     sync.Mutex
+	staleTipSince     time.Time // zero if the tip is not stale
+	staleTipExtraPeer bool      // allow one more outgoing connection than MaxOutCons
+	nextStaleTipCheck time.Time
+)
+
+// StaleTipWarning - returns a warning if no new block has been accepted for too long (or an empty string)
+func StaleTipWarning() (res string) {
+	staleTipMutex.Lock()
+	if !staleTipSince.IsZero() {
+		common.Last.Mutex.Lock()
+		res = fmt.Sprint("No new block for ", time.Now().Sub(common.Last.Time)/time.Minute,
+			" minutes - the chain tip might be stale. Rotating outgoing peers.")
+		common.Last.Mutex.Unlock()
+	}
+	staleTipMutex.Unlock()
+	return
+}
+
+// maxOutCons - MaxOutCons plus possibly one extra connection when the tip is stale
+func maxOutCons() (res uint32) {
+	res = common.GetUint32(&common.CFG.Net.MaxOutCons)
+	staleTipMutex.Lock()
+	if staleTipExtraPeer {
+		res++
+	}
+	staleTipMutex.Unlock()
+	return
+}
+
+// checkStaleTip - called from Ticking()
+func checkStaleTip(now time.Time) {
+	if now.Before(nextStaleTipCheck) {
+		return
+	}
+	nextStaleTipCheck = now.Add(StaleTipCheckEvery)
+
+	common.Last.Mutex.Lock()
+	stale := now.Sub(common.Last.Time) > StaleTipSpacings*chain.TargetSpacing*time.Second
+	common.Last.Mutex.Unlock()
+
+	staleTipMutex.Lock()
+	defer staleTipMutex.Unlock()
+
+	if !stale {
+		if !staleTipSince.IsZero() {
+			L.Info("Chain tip is no longer stale")
+			staleTipSince = time.Time{}
+			staleTipExtraPeer = false
+		}
+		return
+	}
+
+	if staleTipSince.IsZero() {
+		common.CountSafe("StaleTipDetected")
+		L.Warn("Chain tip seems to be stale - connecting to an extra peer")
+		staleTipSince = now
+		staleTipExtraPeer = true
+		return // give the extra peer some time before rotating
+	}
+
+	if dropLeastUsefulOutPeer(now) {
+		common.CountSafe("StaleTipRotate")
+	}
+}
+
+// dropLeastUsefulOutPeer - disconnects the outgoing peer which delivered the least blocks recently
+func dropLeastUsefulOutPeer(now time.Time) bool {
+	var worst *OneConnection
+	var worstCnt int
+	var worstLast time.Time
+
+	MutexNet.Lock()
+	for _, v := range OpenCons {
+		v.Mutex.Lock()
+		skip := v.X.Incomming || v.X.IsSpecial || v.X.BlockRelayOnly || v.X.Permissions.Has(common.PermNoBan) ||
+			!v.X.VersionReceived || now.Sub(v.X.ConnectedAt) < StaleTipCheckEvery
+		cnt := len(v.blocksreceived)
+		var last time.Time
+		if cnt > 0 {
+			last = v.blocksreceived[cnt-1]
+		}
+		v.Mutex.Unlock()
+		if skip {
+			continue
+		}
+		if worst == nil || cnt < worstCnt || cnt == worstCnt && last.Before(worstLast) {
+			worst, worstCnt, worstLast = v, cnt, last
+		}
+	}
+	MutexNet.Unlock()
+
+	if worst == nil {
+		return false
+	}
+	L.Debug("Stale tip - dropping outgoing peer", worst.ConnID, worst.PeerAddr.IP(), "with", worstCnt, "blocks")
+	worst.Disconnect("StaleTipRotate")
+	return true
+}
@@ -90,6 +90,13 @@ func (c *OneConnection) Tick(now time.Time) {
 		return
 	}
 
+	c.Mutex.Lock()
+	permsGen := c.permsGen
+	c.Mutex.Unlock()
+	if permsGen != common.WhitelistGen() {
+		c.UpdatePermissions()
+	}
+
 	if c.MutexGetBool(&c.X.GetHeadersInProgress) && now.After(c.X.GetHeadersTimeout) {
 		//println(c.ConnID, "- GetHdrs Timeout")
 		c.Disconnect("HeadersTimeout")
@@ -97,6 +104,13 @@ func (c *OneConnection) Tick(now time.Time) {
 		return
 	}
 
+	c.Mutex.Lock()
+	presync := c.X.HdrsPresync != ""
+	c.Mutex.Unlock()
+	if presync {
+		c.presyncTimeout(now)
+	}
+
 	if common.GetBool(&common.BlockChainSynchronized) {
 		// See if to send "getmp" command
 		select {
@@ -124,7 +138,7 @@ func (c *OneConnection) Tick(now time.Time) {
 		c.Mutex.Unlock()
 	}
 
-	if mfpb := common.MinFeePerKB(); mfpb != c.X.LastMinFeePerKByte {
+	if mfpb := common.MinFeePerKB(); !c.X.BlockRelayOnly && mfpb != c.X.LastMinFeePerKByte {
 		c.X.LastMinFeePerKByte = mfpb
 		if c.Node.Version >= 70013 {
 			c.SendFeeFilter()
@@ -137,7 +151,7 @@ func (c *OneConnection) Tick(now time.Time) {
 	}
 
 	// Ask node for new addresses...?
-	if !c.X.OurGetAddrDone && peersdb.PeerDB.Count() < common.MaxPeersNeeded {
+	if !c.X.OurGetAddrDone && !c.X.BlockRelayOnly && peersdb.PeerDB.Count() < common.MaxPeersNeeded {
 		common.CountSafe("AddrWanted")
 		c.SendRawMsg("getaddr", nil)
 		c.X.OurGetAddrDone = true
@@ -173,6 +187,11 @@ func (c *OneConnection) Tick(now time.Time) {
 
 // DoNetwork -
 func DoNetwork(ad *peersdb.PeerAddr) {
+	doNetwork(ad, false)
+}
+
+// doNetwork - open an outgoing connection (optionally a block-relay-only one)
+func doNetwork(ad *peersdb.PeerAddr, blockRelayOnly bool) {
 	conn := NewConnection(ad)
 	MutexNet.Lock()
 	if _, ok := OpenCons[ad.UniqID()]; ok {
@@ -182,9 +201,15 @@ func DoNetwork(ad *peersdb.PeerAddr) {
 	}
 	if ad.Friend || ad.Manual {
 		conn.MutexSetBool(&conn.X.IsSpecial, true)
+	} else {
+		conn.X.BlockRelayOnly = blockRelayOnly
 	}
 	OpenCons[ad.UniqID()] = conn
-	OutConsActive++
+	if conn.X.BlockRelayOnly {
+		BlockRelayConsActive++
+	} else {
+		OutConsActive++
+	}
 	MutexNet.Unlock()
 	go func() {
 		var con net.Conn
@@ -217,7 +242,11 @@ func DoNetwork(ad *peersdb.PeerAddr) {
 
 		MutexNet.Lock()
 		delete(OpenCons, ad.UniqID())
-		OutConsActive--
+		if conn.X.BlockRelayOnly {
+			BlockRelayConsActive--
+		} else {
+			OutConsActive--
+		}
 		MutexNet.Unlock()
 		ad.Dead()
 	}()
@@ -258,7 +287,8 @@ func tcpServer() {
 					HammeringMutex.Lock()
 					ti, ok := RecentlyDisconencted[ad.NetAddr.IPv4]
 					HammeringMutex.Unlock()
-					if ok && time.Now().Sub(ti) < HammeringMinReconnect {
+					if ok && time.Now().Sub(ti) < HammeringMinReconnect &&
+						!common.WhitelistIP(ad.NetAddr.IPv4).Has(common.PermNoBan) {
 						//println(ad.IP(), "is hammering within", time.Now().Sub(ti).String())
 						common.CountSafe("BanHammerIn")
 						ad.Ban()
@@ -349,6 +379,7 @@ func ConnectFriends() {
 					curr.Mutex.Lock()
 					curr.PeerAddr.Friend = true
 					curr.X.IsSpecial = true
+					curr.permsGen = 0 // recalculate the permissions
 					curr.Mutex.Unlock()
 				}
 				friendIDs[ad.UniqID()] = true
@@ -372,6 +403,8 @@ func ConnectFriends() {
 				v.X.IsSpecial = false
 			}
 		}
+		v.permsGen = 0 // friends.txt may have changed the auth keys as well
+
 		v.Unlock()
 	}
 	MutexNet.Unlock()
@@ -458,7 +491,9 @@ func Ticking() {
 	}
 	MutexNet.Unlock()
 
-	for connCount < common.GetUint32(&common.CFG.Net.MaxOutCons) {
+	checkStaleTip(now)
+
+	for connCount < maxOutCons() {
 		var segwitConns uint32
 		if common.CFG.Net.MinSegwitCons > 0 {
 			MutexNet.Lock()
@@ -495,6 +530,23 @@ func Ticking() {
 		MutexNet.Unlock()
 	}
 
+	// Block-relay-only connections
+	MutexNet.Lock()
+	connCount = BlockRelayConsActive
+	MutexNet.Unlock()
+	for connCount < common.GetUint32(&common.CFG.Net.BlockRelayOnlyCons) {
+		adrs := peersdb.GetBestPeers(128, func(ad *peersdb.PeerAddr) bool {
+			return ConnectionActive(ad)
+		})
+		if len(adrs) == 0 {
+			break
+		}
+		doNetwork(adrs[rand.Int31n(int32(len(adrs)))], true)
+		MutexNet.Lock()
+		connCount = BlockRelayConsActive
+		MutexNet.Unlock()
+	}
+
 	if expireTxsNow {
 		ExpireTxs()
 	} else if now.After(lastTxsExpire.Add(time.Minute)) {
@@ -533,9 +585,13 @@ func (c *OneConnection) AuthRvcd(pl []byte) {
 	c.X.AuthMsgGot++
 	rnd := make([]byte, 32)
 	copy(rnd, nonce[:])
-	for _, pub := range AuthPubkeys {
+	for _, pub := range append(common.WhitelistPubkeys(), AuthPubkeys...) {
 		if btc.EcdsaVerify(pub, pl, rnd) {
+			c.Mutex.Lock()
 			c.X.Authorized = true
+			c.authPubkey = pub
+			c.Mutex.Unlock()
+			c.UpdatePermissions()
 			c.SendRawMsg("authack", nil)
 			return
 		}
@@ -572,6 +628,8 @@ func (c *OneConnection) Run() {
 
 	c.Mutex.Unlock()
 
+	c.UpdatePermissions()
+
 	nextTick := now
 	nextInvs := now
 
@@ -639,11 +697,13 @@ func (c *OneConnection) Run() {
 					f.Close()
 				}
 			}
-			if c.Node.DoNotRelayTxs {
+			if c.Node.DoNotRelayTxs && !c.X.Permissions.Has(common.PermBloomFilter) {
 				c.DoS("SPV")
 				break
 			}
-			c.X.LastMinFeePerKByte = common.MinFeePerKB()
+			if !c.X.BlockRelayOnly {
+				c.X.LastMinFeePerKByte = common.MinFeePerKB()
+			}
 
 			if c.X.IsDuod {
 				c.SendAuth()
@@ -672,7 +732,7 @@ func (c *OneConnection) Run() {
 			c.PeerAddr.Services = c.Node.Services
 			c.PeerAddr.Save()
 
-			if common.IsListenTCP() {
+			if common.IsListenTCP() && !c.X.BlockRelayOnly {
 				c.SendOwnAddr()
 			}
 			continue
@@ -683,12 +743,18 @@ func (c *OneConnection) Run() {
 			c.ProcessInv(cmd.pl)
 
 		case "tx":
-			if common.AcceptTx() {
+			if c.X.BlockRelayOnly {
+				common.CountSafe("TxBlockRelayOnly")
+			} else if common.AcceptTx() {
 				c.ParseTxNet(cmd.pl)
 			}
 
 		case "addr":
-			c.ParseAddr(cmd.pl)
+			if c.X.BlockRelayOnly {
+				common.CountSafe("AddrBlockRelayOnly")
+			} else {
+				c.ParseAddr(cmd.pl)
+			}
 
 		case "block": //block received
 			netBlockReceived(c, cmd.pl)
@@ -701,7 +767,9 @@ func (c *OneConnection) Run() {
 			c.ProcessGetData(cmd.pl)
 
 		case "getaddr":
-			if !c.X.GetAddrDone {
+			if c.X.BlockRelayOnly {
+				common.CountSafe("GetAddrBlockRelayOnly")
+			} else if !c.X.GetAddrDone {
 				c.SendAddr()
 				c.X.GetAddrDone = true
 			} else {
@@ -774,7 +842,7 @@ func (c *OneConnection) Run() {
 			L.Debug(c.ConnID, c.PeerAddr.IP(), c.Node.Agent, "blocktxn", hex.EncodeToString(cmd.pl))
 
 		case "getmp":
-			if c.X.Authorized {
+			if c.X.Permissions.Has(common.PermMempool) {
 				c.ProcessGetMP(cmd.pl)
 			}
 
@@ -799,6 +867,7 @@ func (c *OneConnection) Run() {
 
 	c.Conn.SetWriteDeadline(time.Now()) // this should cause c.Conn.Write() to terminate
 	c.writingThreadDone.Wait()
+	c.StopCapture()
 
 	c.Mutex.Lock()
 	MutexRcv.Lock()
@@ -814,7 +883,7 @@ func (c *OneConnection) Run() {
 	ban := c.banit
 	c.Mutex.Unlock()
 
-	if c.PeerAddr.Friend || c.X.Authorized {
+	if c.X.Permissions.Has(common.PermNoBan) {
 		common.CountSafe(fmt.Sprint("FDisconnect-", ban))
 	} else {
 		if ban {
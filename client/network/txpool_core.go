@@ -30,6 +30,8 @@ const (
 	TxRejectedOverspend = 154
 	// TxRejectedBadInput -
 	TxRejectedBadInput = 157
+	// TxRejectedScript - only recorded for txs not received from peers (they get banned instead)
+	TxRejectedScript = 158
 	// Anything from the list below might eventually get mined
 
 	// TxRejectedNoTxOU -
@@ -86,6 +88,18 @@ var (
 	WaitingForInputs = make(map[BIDX]*OneWaitingList)
 	// WaitingForInputsSize -
 	WaitingForInputsSize uint64
+
+	// Fee deltas set with "prioritisetransaction" (also for txs not in the pool yet):
+
+	// FeeDeltas -
+	FeeDeltas = make(map[BIDX]int64)
+
+	// Called (with TxMutex locked, so they must not block) when the memory pool changes:
+
+	// TxAcceptedCB -
+	TxAcceptedCB func(*OneTxToSend)
+	// TxRemovedCB - the reason is zero if the tx has been mined (or conflicts with a mined one)
+	TxRemovedCB func(*OneTxToSend, byte)
 )
 
 // OneTxToSend -
@@ -102,6 +116,7 @@ type OneTxToSend struct {
 	SigopsCost  uint64
 	Final       bool // if true RFB will not work on it
 	VerifyTime  time.Duration
+	FeeDelta    int64 // only used for sorting the mempool (see PrioritiseTx)
 }
 
 // OneTxRejected -
@@ -140,6 +155,8 @@ func ReasonToString(reason byte) string {
 		return "OVERSPEND"
 	case TxRejectedBadInput:
 		return "BAD_INPUT"
+	case TxRejectedScript:
+		return "SCRIPT_FAIL"
 	case TxRejectedNoTxOU:
 		return "NO_TXOU"
 	case TxRejectedLowFee:
@@ -262,7 +279,8 @@ func (c *OneConnection) ParseTxNet(pl []byte) {
 		// This body is called with a locked TxMutex
 		tx.Raw = pl
 		select {
-		case NetTxs <- &TxRcvd{conn: c, Tx: tx, trusted: c.X.Authorized}:
+		case NetTxs <- &TxRcvd{conn: c, Tx: tx, trusted: c.X.Permissions.Has(common.PermRelay),
+			forceRelay: c.X.Permissions.Has(common.PermForceRelay)}:
 			TransactionsPending[tx.Hash.BIdx()] = true
 		default:
 			common.CountSafe("TxRejectedFullQ")
@@ -506,6 +524,9 @@ func HandleNetTx(ntx *TxRcvd, retry bool) (accepted bool) {
 
 		if verErrCount > 0 {
 			// not moving it to rejected, but baning the peer
+			if ntx.conn == nil {
+				RejectTx(ntx.Tx, TxRejectedScript) // so the submitter can see why
+			}
 			TxMutex.Unlock()
 			if ntx.conn != nil {
 				ntx.conn.DoS("TxScriptFail")
@@ -534,7 +555,8 @@ func HandleNetTx(ntx *TxRcvd, retry bool) (accepted bool) {
 
 	rec := &OneTxToSend{Spent: spent, Volume: totinp, Local: ntx.local,
 		Fee: fee, Firstseen: time.Now(), Tx: tx, MemInputs: frommem, MemInputCnt: frommemcnt,
-		SigopsCost: uint64(sigops), Final: final, VerifyTime: time.Now().Sub(startTime)}
+		SigopsCost: uint64(sigops), Final: final, VerifyTime: time.Now().Sub(startTime),
+		FeeDelta: FeeDeltas[tx.Hash.BIdx()]}
 
 	TransactionsToSend[tx.Hash.BIdx()] = rec
 
@@ -558,6 +580,10 @@ func HandleNetTx(ntx *TxRcvd, retry bool) (accepted bool) {
 		defer RetryWaitingForInput(wtg) // Redo waiting txs when leaving this function
 	}
 
+	if TxAcceptedCB != nil {
+		TxAcceptedCB(rec)
+	}
+
 	TxMutex.Unlock()
 	common.CountSafe("TxAccepted")
 
@@ -565,7 +591,7 @@ func HandleNetTx(ntx *TxRcvd, retry bool) (accepted bool) {
 		// By default Duod does not route txs that spend unconfirmed inputs
 		rec.Blocked = TxRejectedNotMined
 		common.CountSafe("TxRouteNotMined")
-	} else if !ntx.trusted && rec.isRoutable() {
+	} else if ntx.forceRelay || !ntx.trusted && rec.isRoutable() {
 		// do not automatically route loacally loaded txs
 		rec.Invsentcnt += NetRouteInvExt(1, &tx.Hash, ntx.conn, 1000*fee/uint64(len(ntx.Raw)))
 		common.CountSafe("TxRouteOK")
@@ -642,6 +668,9 @@ func (tx *OneTxToSend) Delete(withChildren bool, reason byte) {
 	if reason != 0 {
 		RejectTx(tx.Tx, reason)
 	}
+	if TxRemovedCB != nil {
+		TxRemovedCB(tx, reason)
+	}
 }
 
 func txChecker(tx *btc.Tx) bool {
@@ -692,11 +721,42 @@ func RemoveFromRejected(hash *btc.Uint256) {
 	TxMutex.Unlock()
 }
 
+// PrioritiseTx - adds the fee delta (in satoshis) to the given transaction
+// The delta is only used when sorting the mempool and building block templates. Returns the total delta.
+func PrioritiseTx(txid *btc.Uint256, delta int64) int64 {
+	TxMutex.Lock()
+	defer TxMutex.Unlock()
+	bidx := txid.BIdx()
+	delta += FeeDeltas[bidx]
+	if delta == 0 {
+		delete(FeeDeltas, bidx)
+	} else {
+		FeeDeltas[bidx] = delta
+	}
+	if t2s, ok := TransactionsToSend[bidx]; ok {
+		t2s.FeeDelta = delta
+	}
+	return delta
+}
+
+// ModifiedFee - the fee with the delta from PrioritiseTx applied
+func (tx *OneTxToSend) ModifiedFee() uint64 {
+	if tx.FeeDelta < 0 && uint64(-tx.FeeDelta) > tx.Fee {
+		return 0
+	}
+	return uint64(int64(tx.Fee) + tx.FeeDelta)
+}
+
 // SubmitLocalTx -
 func SubmitLocalTx(tx *btc.Tx, rawtx []byte) bool {
 	return HandleNetTx(&TxRcvd{Tx: tx, trusted: true, local: true}, true)
 }
 
+// SubmitUntrustedTx - for txs from API clients: checked and routed like the ones from peers
+func SubmitUntrustedTx(tx *btc.Tx) bool {
+	return HandleNetTx(&TxRcvd{Tx: tx}, true)
+}
+
 func init() {
 	chain.TrustedTxChecker = txChecker
 }
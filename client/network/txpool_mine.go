@@ -76,6 +76,7 @@ func txMined(tx *btc.Tx) (wtg *OneWaitingList) {
 		common.CountSafe("TxMinedPending")
 		delete(TransactionsPending, h.BIdx())
 	}
+	delete(FeeDeltas, h.BIdx())
 
 	// Go through all the inputs and make sure we are not leaving them in SpentOutputs
 	for i := range tx.TxIn {
@@ -27,8 +27,8 @@ func GetSortedMempool() (result []*OneTxToSend) {
 	sort.Slice(allTxs, func(i, j int) bool {
 		recI := TransactionsToSend[allTxs[i]]
 		recJ := TransactionsToSend[allTxs[j]]
-		rateI := recI.Fee * uint64(recJ.Weight())
-		rateJ := recJ.Fee * uint64(recI.Weight())
+		rateI := recI.ModifiedFee() * uint64(recJ.Weight())
+		rateJ := recJ.ModifiedFee() * uint64(recI.Weight())
 		if rateI != rateJ {
 			return rateI > rateJ
 		}
@@ -454,7 +454,7 @@ func LookForPackages(txs []*OneTxToSend) (result []*OneTxsPackage) {
 			pkg.Txs = append(parents, tx)
 			for _, t := range pkg.Txs {
 				pkg.Weight += t.Weight()
-				pkg.Fee += t.Fee
+				pkg.Fee += t.ModifiedFee()
 			}
 			result = append(result, &pkg)
 		}
@@ -478,7 +478,7 @@ func GetSortedMempoolNew() (result []*OneTxToSend) {
 
 		if pksIndex < len(pkgs) {
 			pk := pkgs[pksIndex]
-			if pk.Fee*uint64(tx.Weight()) > tx.Fee*uint64(pk.Weight) {
+			if pk.Fee*uint64(tx.Weight()) > tx.ModifiedFee()*uint64(pk.Weight) {
 				pksIndex++
 				if pk.AnyIn(alreadyIn) {
 					continue
@@ -39,6 +39,7 @@ type TxRcvd struct {
 	conn *OneConnection
 	*btc.Tx
 	trusted, local bool
+	forceRelay     bool // route it even if trusted
 }
 
 // OneBlockToGet -
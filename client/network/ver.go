@@ -43,7 +43,7 @@ func (c *OneConnection) SendVersion() {
 	common.UnlockCfg()
 
 	binary.Write(b, binary.LittleEndian, uint32(common.Last.BlockHeight()))
-	if !common.GetBool(&common.CFG.TXPool.Enabled) {
+	if c.X.BlockRelayOnly || !common.GetBool(&common.CFG.TXPool.Enabled) {
 		b.WriteByte(0) // don't notify me about txs
 	}
 
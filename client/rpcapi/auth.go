@@ -0,0 +1,155 @@
+package rpcapi
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/ParallelCoinTeam/duod/client/common"
+	"github.com/ParallelCoinTeam/duod/lib/L"
+)
+
+// CookieUser - the username to use with the password from the cookie file
+const CookieUser = "__cookie__"
+
+var (
+	cookiePass  string
+	cookieMutex sync.Mutex
+)
+
+// CookieFile - path of the file with the cookie authentication data
+func CookieFile() string {
+	return common.DuodHomeDir + ".cookie"
+}
+
+// writeCookie - generates a new random password and stores it in the cookie file
+func writeCookie() {
+	var rnd [32]byte
+	if _, er := rand.Read(rnd[:]); er != nil {
+		L.Error("RPC cookie: ", er.Error())
+		return
+	}
+	pass := hex.EncodeToString(rnd[:])
+	if er := ioutil.WriteFile(CookieFile(), []byte(CookieUser+":"+pass), 0600); er != nil {
+		L.Error("RPC cookie: ", er.Error())
+		return
+	}
+	cookieMutex.Lock()
+	cookiePass = pass
+	cookieMutex.Unlock()
+}
+
+// DeleteCookie - removes the cookie file (call it at exit)
+func DeleteCookie() {
+	cookieMutex.Lock()
+	if cookiePass != "" {
+		os.Remove(CookieFile())
+		cookiePass = ""
+	}
+	cookieMutex.Unlock()
+}
+
+// NewRPCAuth - returns "user:salt$hmac" record for CFG.RPC.Auth
+func NewRPCAuth(user, pass string) string {
+	var salt [16]byte
+	rand.Read(salt[:])
+	s := hex.EncodeToString(salt[:])
+	return user + ":" + s + "$" + rpcAuthHash(s, pass)
+}
+
+// rpcAuthHash - HMAC-SHA256 of the password, with the salt as the key (same as bitcoind's rpcauth)
+func rpcAuthHash(salt, pass string) string {
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(pass))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// equalStrings - constant time comparison
+func equalStrings(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// checkUser - verifies the username and password against all the configured credentials
+func checkUser(user, pass string) bool {
+	if user == CookieUser {
+		cookieMutex.Lock()
+		cp := cookiePass
+		cookieMutex.Unlock()
+		return cp != "" && equalStrings(pass, cp)
+	}
+
+	common.LockCfg()
+	defer common.UnlockCfg()
+
+	if common.CFG.RPC.Password != "" && equalStrings(user, common.CFG.RPC.Username) &&
+		equalStrings(pass, common.CFG.RPC.Password) {
+		return true
+	}
+	for _, rec := range common.CFG.RPC.Auth {
+		ss := strings.SplitN(rec, ":", 2)
+		if len(ss) != 2 || ss[0] != user {
+			continue
+		}
+		sh := strings.SplitN(ss[1], "$", 2)
+		if len(sh) == 2 && equalStrings(rpcAuthHash(sh[0], pass), strings.ToLower(sh[1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// methodAllowed - checks the user's method whitelist (users without a whitelist can call everything)
+// An empty method is never allowed for a user with a whitelist.
+func methodAllowed(user, method string) bool {
+	common.LockCfg()
+	defer common.UnlockCfg()
+
+	var found bool
+	for _, rec := range common.CFG.RPC.Whitelist {
+		ss := strings.SplitN(rec, ":", 2)
+		if len(ss) != 2 || strings.TrimSpace(ss[0]) != user {
+			continue
+		}
+		found = true
+		for _, m := range strings.Split(ss[1], ",") {
+			if method != "" && strings.TrimSpace(m) == method {
+				return true
+			}
+		}
+	}
+	return !found
+}
+
+// forbiddenMethod - returns the first method from the request (or batch) that the user may not call
+// The requests are decoded the same way as for executing them and those that cannot be decoded count as
+// an empty method.
+func forbiddenMethod(user string, b []byte) (method string, forbidden bool) {
+	reqs := [][]byte{b}
+	if len(b) > 0 && b[0] == '[' {
+		var batch []json.RawMessage
+		if json.Unmarshal(b, &batch) != nil {
+			return "", !methodAllowed(user, "")
+		}
+		reqs = reqs[:0]
+		for _, r := range batch {
+			reqs = append(reqs, r)
+		}
+	}
+	for _, r := range reqs {
+		method = ""
+		if cmd, e := decodeCommand(r); e == nil {
+			method = cmd.Method
+		}
+		if !methodAllowed(user, method) {
+			return method, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,149 @@
+package rpcapi
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ParallelCoinTeam/duod/client/common"
+	"github.com/ParallelCoinTeam/duod/lib/btc"
+	"github.com/ParallelCoinTeam/duod/lib/L"
+)
+
+const (
+	// AuxCoinbaseTag - put into the coinbase of blocks made by "createauxblock"
+	AuxCoinbaseTag = "/Duod-aux/"
+	// AuxBlockRefresh - a new block is created (for the same address) if the previous one is older
+	AuxBlockRefresh = time.Minute
+)
+
+type auxBlock struct {
+	*Job
+	hdr, cb []byte
+}
+
+var (
+	auxMutex  sync.Mutex
+	auxBlocks = make(map[string]*auxBlock) // by the block's hash
+	auxByAddr = make(map[string]*auxBlock) // the last one created for the address
+	auxCnt    uint64
+)
+
+// newAuxBlock - call it with auxMutex locked
+func newAuxBlock(addr string) (*auxBlock, error) {
+	if ab := auxByAddr[addr]; ab != nil && time.Now().Sub(ab.Created) < AuxBlockRefresh {
+		common.Last.Mutex.Lock()
+		fresh := bytes.Equal(ab.PrevHash, common.Last.Block.BlockHash.Hash[:])
+		common.Last.Mutex.Unlock()
+		if fresh {
+			return ab, nil
+		}
+	}
+
+	payouts, er := common.ParsePayouts(addr, nil)
+	if er != nil {
+		return nil, er
+	}
+	auxCnt++
+	j, er := NewJob(fmt.Sprint("aux", auxCnt), &CoinbaseParams{Payouts: payouts, Tag: AuxCoinbaseTag})
+	if er != nil {
+		return nil, er
+	}
+	if cons := &common.BlockChain.Consensus; cons.AuxPowHeight == 0 || j.Height < cons.AuxPowHeight {
+		return nil, fmt.Errorf("AuxPoW is not active until block %d", cons.AuxPowHeight)
+	}
+	j.Version |= btc.AuxPowVersion | common.BlockChain.Consensus.AuxPowChainID<<16
+
+	ab := &auxBlock{Job: j, cb: j.Coinbase(nil, nil)}
+	ab.hdr = j.Header(ab.cb, j.Curtime, 0)
+
+	// forget blocks of the previous tips
+	for h, b := range auxBlocks {
+		if !bytes.Equal(b.PrevHash, j.PrevHash) {
+			delete(auxBlocks, h)
+		}
+	}
+	for a, b := range auxByAddr {
+		if !bytes.Equal(b.PrevHash, j.PrevHash) {
+			delete(auxByAddr, a)
+		}
+	}
+	auxBlocks[btc.NewSha2Hash(ab.hdr).String()] = ab
+	auxByAddr[addr] = ab
+	return ab, nil
+}
+
+// CreateAuxBlock - params: [address]
+// Returns a block to be merge-mined, paying to the given address.
+func CreateAuxBlock(cmd *RPCCommand, resp *RPCResponse) {
+	addr, ok := paramString(cmd.paramsArray(), 0)
+	if !ok {
+		resp.Error = RPCError{Code: RPCErrInvalidParams, Message: "Payout address expected"}
+		return
+	}
+	auxMutex.Lock()
+	ab, er := newAuxBlock(addr)
+	auxMutex.Unlock()
+	if er != nil {
+		resp.Error = RPCError{Code: RPCErrMisc, Message: er.Error()}
+		return
+	}
+
+	var target [32]byte // little endian, as hashes are
+	tb := ab.Target.Bytes()
+	for i := range tb {
+		target[i] = tb[len(tb)-1-i]
+	}
+	resp.Result = map[string]interface{}{
+		"hash":              btc.NewSha2Hash(ab.hdr).String(),
+		"chainid":           common.BlockChain.Consensus.AuxPowChainID,
+		"previousblockhash": btc.NewUint256(ab.PrevHash).String(),
+		"coinbasevalue":     ab.Fees + btc.GetBlockReward(ab.Height),
+		"bits":              fmt.Sprintf("%08x", ab.Bits),
+		"height":            ab.Height,
+		"_target":           hex.EncodeToString(target[:]),
+	}
+}
+
+// SubmitAuxBlock - params: [hash, auxpow]
+// Attaches the AuxPoW to a block returned by "createauxblock" and submits it.
+func SubmitAuxBlock(cmd *RPCCommand, resp *RPCResponse) {
+	par := cmd.paramsArray()
+	hash, ok1 := paramString(par, 0)
+	aux, ok2 := paramString(par, 1)
+	if !ok1 || !ok2 {
+		resp.Error = RPCError{Code: RPCErrInvalidParams, Message: "Block hash and AuxPoW expected"}
+		return
+	}
+	auxMutex.Lock()
+	ab := auxBlocks[hash]
+	auxMutex.Unlock()
+	if ab == nil {
+		resp.Error = RPCError{Code: RPCErrInvalidParameter, Message: "block hash unknown"}
+		return
+	}
+
+	data, er := hex.DecodeString(aux)
+	if er != nil {
+		resp.Error = RPCError{Code: RPCErrDeserialization, Message: er.Error()}
+		return
+	}
+	ap, er := btc.NewAuxPow(data)
+	if er != nil || ap.Size != len(data) {
+		resp.Error = RPCError{Code: RPCErrDeserialization, Message: "AuxPoW decode failed"}
+		return
+	}
+
+	raw := append(append(append([]byte{}, ab.hdr...), data...), ab.Block(ab.hdr, ab.cb)[80:]...)
+	res, er := SubmitRawBlock(raw)
+	if er != nil {
+		resp.Error = RPCError{Code: RPCErrDeserialization, Message: er.Error()}
+		return
+	}
+	if res != "" {
+		L.Debug("submitauxblock ", hash, " rejected: ", res)
+	}
+	resp.Result = res == ""
+}
@@ -2,16 +2,16 @@ package rpcapi
 
 import (
 	"encoding/hex"
-	"encoding/json"
-	"fmt"
+	"errors"
 	"io/ioutil"
-	"strings"
 	"sync"
 	"time"
 
 	"github.com/ParallelCoinTeam/duod/client/common"
 	"github.com/ParallelCoinTeam/duod/client/network"
+	"github.com/ParallelCoinTeam/duod/client/usif"
 	"github.com/ParallelCoinTeam/duod/lib/btc"
+	"github.com/ParallelCoinTeam/duod/lib/chain"
 	"github.com/ParallelCoinTeam/duod/lib/L"
 )
 
@@ -26,7 +26,7 @@ type BlockSubmitted struct {
 var RPCBlocks = make(chan *BlockSubmitted, 1)
 
 // SubmitBlock -
-func SubmitBlock(cmd *RPCCommand, resp *RPCResponse, b []byte) {
+func SubmitBlock(cmd *RPCCommand, resp *RPCResponse) {
 	var bd []byte
 	var er error
 
@@ -60,11 +60,23 @@ func SubmitBlock(cmd *RPCCommand, resp *RPCResponse, b []byte) {
 		return
 	}
 
+	res, er := SubmitRawBlock(bd)
+	if er != nil {
+		resp.Error = RPCError{Code: -4, Message: er.Error()}
+		return
+	}
+	if res != "" {
+		resp.Result = res
+	}
+}
+
+// SubmitRawBlock - passes the block to the chain's thread and waits for the result
+// Returns an empty string if the block has been accepted, otherwise BIP22 reason ("inconclusive" if not known).
+func SubmitRawBlock(bd []byte) (res string, er error) {
 	bs := new(BlockSubmitted)
 
 	bs.Block, er = btc.NewBlock(bd)
 	if er != nil {
-		resp.Error = RPCError{Code: -4, Message: er.Error()}
 		return
 	}
 
@@ -77,15 +89,9 @@ func SubmitBlock(cmd *RPCCommand, resp *RPCResponse, b []byte) {
 	RPCBlocks <- bs
 	bs.Done.Wait()
 	if bs.Error != "" {
-		//resp.Error = RPCError{Code: -10, Message: bs.Error}
-		idx := strings.Index(bs.Error, "- RPC_Result:")
-		if idx == -1 {
-			resp.Result = "inconclusive"
-		} else {
-			resp.Result = bs.Error[idx+13:]
-		}
+		res = chain.RejectReason(errors.New(bs.Error))
 		L.Debug("submiting block error:", bs.Error)
-		L.Debug("submiting block result:", resp.Result.(string))
+		L.Debug("submiting block result:", res)
 
 		L.Debug("time_now:", time.Now().Unix())
 		L.Debug("  cur_block_ts:", bs.Block.BlockTime())
@@ -94,21 +100,38 @@ func SubmitBlock(cmd *RPCCommand, resp *RPCResponse, b []byte) {
 		common.Last.Mutex.Lock()
 		L.Debug("  prev_block_ts:", common.Last.Block.Timestamp())
 		common.Last.Mutex.Unlock()
+	}
+	return
+}
 
+// ProposeBlock - handles "getblocktemplate" in "proposal" mode (BIP23)
+// The result is null if the block is valid, otherwise BIP22 reject reason.
+func ProposeBlock(req map[string]interface{}, resp *RPCResponse) {
+	data, _ := req["data"].(string)
+	bd, er := hex.DecodeString(data)
+	if er != nil || len(bd) == 0 {
+		resp.Error = RPCError{Code: RPCErrDeserialization, Message: "Block decode failed"}
+		return
+	}
+	bl, er := btc.NewBlock(bd)
+	if er != nil {
+		resp.Error = RPCError{Code: RPCErrDeserialization, Message: "Block decode failed: " + er.Error()}
 		return
 	}
 
-	// cress check with bitcoind...
-	if false {
-		BitcoindResult := processRPC(b)
-		json.Unmarshal(BitcoindResult, &resp)
-		switch cmd.Params.(type) {
-		case string:
-			L.Debug("\007Block rejected by bitcoind:", resp.Result.(string))
-			ioutil.WriteFile(fmt.Sprint(bs.Block.Height, "-", bs.Block.Hash.String()), bd, 0777)
-		default:
-			L.Debug("submiting block verified OK", bs.Error)
-		}
+	// the unspent database must be accessed from the chain's thread
+	lck := new(usif.OneLock)
+	lck.In.Add(1)
+	lck.Out.Add(1)
+	usif.LocksChan <- lck
+	lck.In.Wait()
+	res := common.BlockChain.CheckBlockProposal(bl)
+	lck.Out.Done()
+
+	common.CountSafe("RPCBlockProposal")
+	if res != "" {
+		L.Debug("Block proposal ", bl.Hash.String(), " rejected: ", res)
+		resp.Result = res
 	}
 }
 
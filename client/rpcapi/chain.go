@@ -0,0 +1,391 @@
+package rpcapi
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+
+	"github.com/ParallelCoinTeam/duod/client/common"
+	"github.com/ParallelCoinTeam/duod/client/network"
+	"github.com/ParallelCoinTeam/duod/lib/btc"
+	"github.com/ParallelCoinTeam/duod/lib/chain"
+)
+
+// BlockHeaderResp - the result of "getblockheader" (and a part of "getblock")
+type BlockHeaderResp struct {
+	Hash              string  `json:"hash"`
+	Confirmations     int     `json:"confirmations"`
+	Height            uint32  `json:"height"`
+	Version           uint32  `json:"version"`
+	VersionHex        string  `json:"versionHex"`
+	Merkleroot        string  `json:"merkleroot"`
+	Time              uint32  `json:"time"`
+	Mediantime        uint32  `json:"mediantime"`
+	Nonce             uint32  `json:"nonce"`
+	Bits              string  `json:"bits"`
+	Difficulty        float64 `json:"difficulty"`
+	Chainwork         string  `json:"chainwork"`
+	NTx               uint32  `json:"nTx"`
+	PreviousBlockHash string  `json:"previousblockhash,omitempty"`
+	NextBlockHash     string  `json:"nextblockhash,omitempty"`
+}
+
+// BlockResp - the result of "getblock" with verbosity 1 or 2
+type BlockResp struct {
+	BlockHeaderResp
+	StrippedSize int           `json:"strippedsize"`
+	Size         int           `json:"size"`
+	Weight       uint          `json:"weight"`
+	Tx           []interface{} `json:"tx"`
+}
+
+// ChainTip - one record of "getchaintips"
+type ChainTip struct {
+	Height    uint32 `json:"height"`
+	Hash      string `json:"hash"`
+	BranchLen uint32 `json:"branchlen"`
+	Status    string `json:"status"`
+}
+
+// workToHex - converts the chain work (sum of difficulties) into bitcoind's "chainwork" format
+func workToHex(work float64) string {
+	w, _ := new(big.Float).Mul(big.NewFloat(work), big.NewFloat(btc.HashesPerDifficulty)).Int(nil)
+	return fmt.Sprintf("%064x", w)
+}
+
+// nodeAtHeight - returns the main chain node at the given height (call it with BlockIndexAccess locked)
+func nodeAtHeight(height uint32) *chain.BlockTreeNode {
+	return common.BlockChain.NodeAtHeight(height)
+}
+
+// findNode - returns the block tree node by its hash given as a hex string (lock BlockIndexAccess)
+func findNode(hs string) *chain.BlockTreeNode {
+	h := btc.NewUint256FromString(hs)
+	if h == nil {
+		return nil
+	}
+	return common.BlockChain.BlockIndex[h.BIdx()]
+}
+
+// fillHeader - call it with BlockIndexAccess locked
+func fillHeader(n *chain.BlockTreeNode, r *BlockHeaderResp) {
+	last := common.BlockChain.LastBlock()
+	r.Hash = n.BlockHash.String()
+	if nodeAtHeight(n.Height) == n {
+		r.Confirmations = int(last.Height-n.Height) + 1
+		if n != last {
+			r.NextBlockHash = nodeAtHeight(n.Height + 1).BlockHash.String()
+		}
+	} else {
+		r.Confirmations = -1
+	}
+	r.Height = n.Height
+	r.Version = n.BlockVersion()
+	r.VersionHex = fmt.Sprintf("%08x", r.Version)
+	r.Merkleroot = btc.NewUint256(n.BlockHeader[36:68]).String()
+	r.Time = n.Timestamp()
+	r.Mediantime = n.GetMedianTimePast()
+	r.Nonce = binary.LittleEndian.Uint32(n.BlockHeader[76:80])
+	r.Bits = fmt.Sprintf("%08x", n.Bits())
+	r.Difficulty = btc.GetDifficulty(n.Bits())
+	r.Chainwork = workToHex(n.ChainWork())
+	r.NTx = n.TxCount
+	if n.Parent != nil {
+		r.PreviousBlockHash = n.Parent.BlockHash.String()
+	}
+}
+
+// GetBlockchainInfo -
+func GetBlockchainInfo(cmd *RPCCommand, resp *RPCResponse) {
+	var res struct {
+		Chain                string  `json:"chain"`
+		Blocks               uint32  `json:"blocks"`
+		Headers              uint32  `json:"headers"`
+		BestBlockHash        string  `json:"bestblockhash"`
+		Difficulty           float64 `json:"difficulty"`
+		MedianTime           uint32  `json:"mediantime"`
+		VerificationProgress float64 `json:"verificationprogress"`
+		InitialBlockDownload bool    `json:"initialblockdownload"`
+		Chainwork            string  `json:"chainwork"`
+		Pruned               bool    `json:"pruned"`
+		Warnings             string  `json:"warnings"`
+	}
+
+	if common.Testnet {
+		res.Chain = "test"
+	} else {
+		res.Chain = "main"
+	}
+
+	network.MutexRcv.Lock()
+	res.Headers = network.LastCommitedHeader.Height
+	network.MutexRcv.Unlock()
+
+	common.BlockChain.BlockIndexAccess.Lock()
+	last := common.BlockChain.LastBlock()
+	res.Blocks = last.Height
+	res.BestBlockHash = last.BlockHash.String()
+	res.Difficulty = btc.GetDifficulty(last.Bits())
+	res.MedianTime = last.GetMedianTimePast()
+	res.Chainwork = workToHex(last.ChainWork())
+	common.BlockChain.BlockIndexAccess.Unlock()
+
+	if res.Headers > res.Blocks {
+		res.VerificationProgress = float64(res.Blocks) / float64(res.Headers)
+	} else {
+		res.VerificationProgress = 1
+	}
+	res.InitialBlockDownload = !common.GetBool(&common.BlockChainSynchronized)
+	res.Warnings = network.StaleTipWarning()
+
+	resp.Result = &res
+}
+
+// GetBlockHash -
+func GetBlockHash(cmd *RPCCommand, resp *RPCResponse) {
+	height, ok := paramInt(cmd.paramsArray(), 0, -1)
+	if !ok || height < 0 {
+		resp.Error = RPCError{Code: RPCErrInvalidParams, Message: "Block height expected"}
+		return
+	}
+
+	common.BlockChain.BlockIndexAccess.Lock()
+	defer common.BlockChain.BlockIndexAccess.Unlock()
+	var n *chain.BlockTreeNode
+	if height <= int64(common.BlockChain.LastBlock().Height) {
+		n = nodeAtHeight(uint32(height))
+	}
+	if n == nil {
+		resp.Error = RPCError{Code: RPCErrInvalidParameter, Message: "Block height out of range"}
+		return
+	}
+	resp.Result = n.BlockHash.String()
+}
+
+// GetBlockHeader -
+func GetBlockHeader(cmd *RPCCommand, resp *RPCResponse) {
+	par := cmd.paramsArray()
+	hs, ok := paramString(par, 0)
+	if !ok {
+		resp.Error = RPCError{Code: RPCErrInvalidParams, Message: "Block hash expected"}
+		return
+	}
+	verbose, ok := paramBool(par, 1, true)
+	if !ok {
+		resp.Error = RPCError{Code: RPCErrInvalidParams, Message: "Verbose must be a boolean"}
+		return
+	}
+
+	common.BlockChain.BlockIndexAccess.Lock()
+	defer common.BlockChain.BlockIndexAccess.Unlock()
+	n := findNode(hs)
+	if n == nil {
+		resp.Error = RPCError{Code: RPCErrInvalidAddressOrKey, Message: "Block not found"}
+		return
+	}
+	if !verbose {
+		resp.Result = hex.EncodeToString(n.BlockHeader[:])
+		return
+	}
+	res := new(BlockHeaderResp)
+	fillHeader(n, res)
+	resp.Result = res
+}
+
+// GetBlock -
+func GetBlock(cmd *RPCCommand, resp *RPCResponse) {
+	par := cmd.paramsArray()
+	hs, ok := paramString(par, 0)
+	if !ok {
+		resp.Error = RPCError{Code: RPCErrInvalidParams, Message: "Block hash expected"}
+		return
+	}
+	verbosity, ok := paramInt(par, 1, 1)
+	if !ok {
+		// bitcoind also accepts a boolean here
+		var verbose bool
+		if verbose, ok = paramBool(par, 1, true); !ok {
+			resp.Error = RPCError{Code: RPCErrInvalidParams, Message: "Verbosity must be a number"}
+			return
+		}
+		if verbose {
+			verbosity = 1
+		} else {
+			verbosity = 0
+		}
+	}
+
+	common.BlockChain.BlockIndexAccess.Lock()
+	n := findNode(hs)
+	if n == nil {
+		common.BlockChain.BlockIndexAccess.Unlock()
+		resp.Error = RPCError{Code: RPCErrInvalidAddressOrKey, Message: "Block not found"}
+		return
+	}
+	res := new(BlockResp)
+	fillHeader(n, &res.BlockHeaderResp)
+	haveData := n.BlockSize != 0
+	common.BlockChain.BlockIndexAccess.Unlock()
+
+	if !haveData {
+		resp.Error = RPCError{Code: RPCErrMisc, Message: "Block not available (not fully downloaded)"}
+		return
+	}
+
+	raw, _, er := common.BlockChain.Blocks.BlockGet(n.BlockHash)
+	if er != nil {
+		resp.Error = RPCError{Code: RPCErrMisc, Message: "Can't read block from disk: " + er.Error()}
+		return
+	}
+
+	if verbosity <= 0 {
+		resp.Result = hex.EncodeToString(raw)
+		return
+	}
+
+	bl, er := btc.NewBlock(raw)
+	if er == nil {
+		er = bl.BuildTxList()
+	}
+	if er != nil {
+		resp.Error = RPCError{Code: RPCErrMisc, Message: "Block data corrupt: " + er.Error()}
+		return
+	}
+
+	res.StrippedSize = bl.NoWitnessSize
+	res.Size = len(raw)
+	res.Weight = bl.BlockWeight
+	res.NTx = uint32(len(bl.Txs))
+	res.Tx = make([]interface{}, len(bl.Txs))
+	for i, tx := range bl.Txs {
+		if verbosity == 1 {
+			res.Tx[i] = tx.Hash.String()
+		} else {
+			res.Tx[i] = TxToJSON(tx)
+		}
+	}
+	resp.Result = res
+}
+
+// GetChainTips -
+func GetChainTips(cmd *RPCCommand, resp *RPCResponse) {
+	var res []*ChainTip
+
+	common.BlockChain.BlockIndexAccess.Lock()
+	last := common.BlockChain.LastBlock()
+	for _, n := range common.BlockChain.BlockIndex {
+		if len(n.Childs) > 0 {
+			continue
+		}
+		tip := &ChainTip{Height: n.Height, Hash: n.BlockHash.String()}
+		if n == last {
+			tip.Status = "active"
+		} else {
+			tip.Status = "valid-fork"
+			fork := n
+			for ; fork != nil && !common.BlockChain.OnActiveBranch(fork); fork = fork.Parent {
+				if fork.BlockSize == 0 {
+					tip.Status = "headers-only"
+				}
+			}
+			if fork != nil {
+				tip.BranchLen = n.Height - fork.Height
+			}
+		}
+		res = append(res, tip)
+	}
+	common.BlockChain.BlockIndexAccess.Unlock()
+
+	resp.Result = res
+}
+
+// scriptType - returns bitcoind's name of the given output script type
+func scriptType(pk []byte) string {
+	switch {
+	case len(pk) == 25 && pk[0] == 0x76 && pk[1] == 0xa9 && pk[2] == 0x14 && pk[23] == 0x88 && pk[24] == 0xac:
+		return "pubkeyhash"
+	case btc.IsP2SH(pk):
+		return "scripthash"
+	case len(pk) == 22 && pk[0] == 0 && pk[1] == 20:
+		return "witness_v0_keyhash"
+	case len(pk) == 34 && pk[0] == 0 && pk[1] == 32:
+		return "witness_v0_scripthash"
+	case (len(pk) == 35 && pk[0] == 33 || len(pk) == 67 && pk[0] == 65) && pk[len(pk)-1] == 0xac:
+		return "pubkey"
+	case len(pk) > 0 && pk[0] == 0x6a:
+		return "nulldata"
+	}
+	return "nonstandard"
+}
+
+// scriptAsm -
+func scriptAsm(scr []byte) (res string) {
+	ss, _ := btc.ScriptToText(scr)
+	for i := range ss {
+		if i > 0 {
+			res += " "
+		}
+		res += ss[i]
+	}
+	return
+}
+
+// ScriptPubKeyJSON - describes the output script, as bitcoind does
+func ScriptPubKeyJSON(pk []byte) map[string]interface{} {
+	spk := map[string]interface{}{
+		"asm":  scriptAsm(pk),
+		"hex":  hex.EncodeToString(pk),
+		"type": scriptType(pk),
+	}
+	if a := btc.NewAddrFromPkScript(pk, common.Testnet); a != nil {
+		spk["reqSigs"] = 1
+		spk["addresses"] = []string{a.String()}
+	}
+	return spk
+}
+
+// TxToJSON - returns the transaction in the format of bitcoind's "decoderawtransaction"
+func TxToJSON(tx *btc.Tx) map[string]interface{} {
+	vin := make([]map[string]interface{}, len(tx.TxIn))
+	for i, in := range tx.TxIn {
+		rec := map[string]interface{}{"sequence": in.Sequence}
+		if tx.IsCoinBase() {
+			rec["coinbase"] = hex.EncodeToString(in.ScriptSig)
+		} else {
+			rec["txid"] = btc.NewUint256(in.Input.Hash[:]).String()
+			rec["vout"] = in.Input.Vout
+			rec["scriptSig"] = map[string]string{"asm": scriptAsm(in.ScriptSig), "hex": hex.EncodeToString(in.ScriptSig)}
+		}
+		if tx.SegWit != nil && len(tx.SegWit[i]) > 0 {
+			wit := make([]string, len(tx.SegWit[i]))
+			for j := range tx.SegWit[i] {
+				wit[j] = hex.EncodeToString(tx.SegWit[i][j])
+			}
+			rec["txinwitness"] = wit
+		}
+		vin[i] = rec
+	}
+
+	vout := make([]map[string]interface{}, len(tx.TxOut))
+	for i, out := range tx.TxOut {
+		vout[i] = map[string]interface{}{
+			"value":        float64(out.Value) / 1e8,
+			"n":            i,
+			"scriptPubKey": ScriptPubKeyJSON(out.PkScript),
+		}
+	}
+
+	return map[string]interface{}{
+		"txid":     tx.Hash.String(),
+		"hash":     tx.WTxID().String(),
+		"version":  tx.Version,
+		"size":     tx.Size,
+		"vsize":    tx.VSize(),
+		"weight":   tx.Weight(),
+		"locktime": tx.LockTime,
+		"vin":      vin,
+		"vout":     vout,
+		"hex":      hex.EncodeToString(tx.Raw),
+	}
+}
@@ -0,0 +1,62 @@
+package rpcapi
+
+import (
+	"runtime"
+)
+
+// Hooks of the CPU miner (set in main, as the miner's package uses this one)
+var (
+	GenerateStart  func(threads int, addr string, force bool) error
+	GenerateStop   func()
+	GenerateStatus func() interface{}
+)
+
+// SetGenerate - params: [generate, genproclimit, address, force]
+// Without the address, the payouts from the Mining section of the config are used.
+// Mining on mainnet is refused, unless force is true.
+func SetGenerate(cmd *RPCCommand, resp *RPCResponse) {
+	if GenerateStart == nil || GenerateStop == nil {
+		resp.Error = RPCError{Code: RPCErrMisc, Message: "CPU miner not available"}
+		return
+	}
+	par := cmd.paramsArray()
+	gen, ok := paramBool(par, 0, false)
+	if !ok || len(par) < 1 {
+		resp.Error = RPCError{Code: RPCErrInvalidParams, Message: "generate must be true or false"}
+		return
+	}
+	if !gen {
+		GenerateStop()
+		return
+	}
+	threads, ok := paramInt(par, 1, -1)
+	if !ok {
+		resp.Error = RPCError{Code: RPCErrInvalidParams, Message: "genproclimit must be a number"}
+		return
+	}
+	if threads <= 0 {
+		threads = int64(runtime.NumCPU())
+	}
+	addr, ok := paramString(par, 2)
+	if !ok && len(par) > 2 && par[2] != nil {
+		resp.Error = RPCError{Code: RPCErrInvalidParams, Message: "Payout address must be a string"}
+		return
+	}
+	force, ok := paramBool(par, 3, false)
+	if !ok {
+		resp.Error = RPCError{Code: RPCErrInvalidParams, Message: "force must be true or false"}
+		return
+	}
+	if er := GenerateStart(int(threads), addr, force); er != nil {
+		resp.Error = RPCError{Code: RPCErrMisc, Message: er.Error()}
+	}
+}
+
+// GetGenerate - returns the CPU miner's status
+func GetGenerate(cmd *RPCCommand, resp *RPCResponse) {
+	if GenerateStatus == nil {
+		resp.Error = RPCError{Code: RPCErrMisc, Message: "CPU miner not available"}
+		return
+	}
+	resp.Result = GenerateStatus()
+}
@@ -0,0 +1,215 @@
+package rpcapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ParallelCoinTeam/duod/client/common"
+	"github.com/ParallelCoinTeam/duod/lib/btc"
+)
+
+// Job - a block to be mined, with the coinbase split around the extranonce (used by Stratum, CPU miner and AuxPoW)
+type Job struct {
+	ID       string
+	Height   uint32
+	PrevHash []byte // as in the block header
+	Version  uint32
+	Bits     uint32
+	Curtime  uint32
+	Mintime  uint32
+	Coinb1   []byte // coinbase tx (without witness) before the extranonces
+	Coinb2   []byte // ... and after them
+	Branch   [][32]byte
+	Txs      [][]byte // raw transactions following the coinbase
+	Witness  bool     // the coinbase needs the witness nonce (there is a witness commitment)
+	Fees     uint64
+	Target   *big.Int // of the block
+	Created  time.Time
+}
+
+// scriptNumber - serializes n as a script push (BIP34 height)
+func scriptNumber(n uint32) []byte {
+	if n >= 1 && n <= 16 {
+		return []byte{0x50 + byte(n)} // OP_1 .. OP_16
+	}
+	var num []byte
+	for v := n; v > 0; v >>= 8 {
+		num = append(num, byte(v))
+	}
+	if len(num) > 0 && num[len(num)-1]&0x80 != 0 {
+		num = append(num, 0)
+	}
+	return append([]byte{byte(len(num))}, num...)
+}
+
+// CoinbaseParams - how the coinbase transaction of a job is built
+type CoinbaseParams struct {
+	Payouts        []common.Payout
+	Tag            string // put into the input script, after the height and the extranonce
+	ExtranonceSize int    // bytes left for the extranonce between Coinb1 and Coinb2
+}
+
+// splitReward - returns the values of the payouts (the main one gets what is left from the splits)
+func splitReward(payouts []common.Payout, value uint64) []uint64 {
+	res := make([]uint64, len(payouts))
+	left := value
+	for i := 1; i < len(payouts); i++ {
+		res[i] = uint64(float64(value) * payouts[i].Perc / 100)
+		left -= res[i]
+	}
+	res[0] = left
+	return res
+}
+
+// NewJob - makes a new job from the current block template
+func NewJob(id string, cp *CoinbaseParams) (*Job, error) {
+	var tmpl GetBlockTemplateResp
+	GetNextBlockTemplate(&tmpl)
+	return JobFromTemplate(id, &tmpl, cp)
+}
+
+// JobFromTemplate - builds the coinbase (with BIP34 height, extranonce space, tag, payouts
+// and the witness commitment) for the given block template
+func JobFromTemplate(id string, tmpl *GetBlockTemplateResp, cp *CoinbaseParams) (*Job, error) {
+	if len(cp.Payouts) == 0 {
+		return nil, errors.New("no payouts for the coinbase")
+	}
+	j := &Job{ID: id, Height: uint32(tmpl.Height), Version: tmpl.Version, Curtime: uint32(tmpl.Curtime),
+		Mintime: uint32(tmpl.Mintime), Created: time.Now()}
+	if prev := btc.NewUint256FromString(tmpl.PreviousBlockHash); prev != nil {
+		j.PrevHash = prev.Hash[:]
+	} else {
+		return nil, errors.New("bad previous block hash in the template")
+	}
+	if _, er := fmt.Sscanf(tmpl.Bits, "%08x", &j.Bits); er != nil {
+		return nil, er
+	}
+	j.Target = btc.SetCompact(j.Bits)
+
+	txs := make([]*btc.Tx, len(tmpl.Transactions)+1)
+	txids := make([][32]byte, len(tmpl.Transactions)+1) // the coinbase's one does not matter for its branch
+	for i, t := range tmpl.Transactions {
+		raw, er := hex.DecodeString(t.Data)
+		if er != nil {
+			return nil, er
+		}
+		tx, _ := btc.NewTx(raw)
+		if tx == nil {
+			return nil, errors.New("bad transaction in the template")
+		}
+		tx.SetHash(raw)
+		txs[i+1] = tx
+		txids[i+1] = tx.Hash.Hash
+		j.Txs = append(j.Txs, raw)
+		j.Fees += t.Fee
+	}
+	j.Branch = btc.MerkleBranch(txids, 0)
+
+	// the coinbase's input script: height, extranonce, tag
+	height := scriptNumber(j.Height)
+	if cp.ExtranonceSize < 0 || len(height)+cp.ExtranonceSize > 100 {
+		return nil, fmt.Errorf("extranonce of %d bytes does not fit in the coinbase", cp.ExtranonceSize)
+	}
+	tag := cp.Tag
+	if len(tag) > 100-len(height)-cp.ExtranonceSize {
+		tag = tag[:100-len(height)-cp.ExtranonceSize]
+	}
+	if len(height)+cp.ExtranonceSize+len(tag) < 2 {
+		tag += "\x00" // the input script of a coinbase must be at least 2 bytes long
+	}
+	b := new(bytes.Buffer)
+	binary.Write(b, binary.LittleEndian, uint32(1)) // version
+	b.WriteByte(1)                                  // one input
+	b.Write(make([]byte, 32))
+	binary.Write(b, binary.LittleEndian, uint32(0xffffffff))
+	btc.WriteVlen(b, uint64(len(height)+cp.ExtranonceSize+len(tag)))
+	b.Write(height)
+	j.Coinb1 = append([]byte{}, b.Bytes()...)
+
+	b.Reset()
+	b.WriteString(tag)
+	binary.Write(b, binary.LittleEndian, uint32(0xffffffff)) // sequence
+
+	j.Witness = common.BlockChain.Consensus.EnforceSegwit != 0 && j.Height >= common.BlockChain.Consensus.EnforceSegwit
+	values := splitReward(cp.Payouts, tmpl.Coinbasevalue)
+	outs := 0
+	for i := range values {
+		if i == 0 || values[i] > 0 {
+			outs++
+		}
+	}
+	if j.Witness {
+		outs++
+	}
+	btc.WriteVlen(b, uint64(outs))
+	for i, p := range cp.Payouts {
+		if i == 0 || values[i] > 0 {
+			binary.Write(b, binary.LittleEndian, values[i])
+			btc.WriteVlen(b, uint64(len(p.Script)))
+			b.Write(p.Script)
+		}
+	}
+	if j.Witness {
+		// witness commitment (the witness nonce is all zeros)
+		wm, _ := btc.GetWitnessMerkle(txs)
+		com := btc.Sha2Sum(append(wm, make([]byte, 32)...))
+		binary.Write(b, binary.LittleEndian, uint64(0))
+		b.WriteByte(38)
+		b.Write([]byte{0x6a, 0x24, 0xaa, 0x21, 0xa9, 0xed})
+		b.Write(com[:])
+	}
+	binary.Write(b, binary.LittleEndian, uint32(0)) // lock time
+	j.Coinb2 = b.Bytes()
+	return j, nil
+}
+
+// Coinbase - returns the coinbase tx (without witness) for the given extranonce (in two parts)
+func (j *Job) Coinbase(en1, en2 []byte) []byte {
+	cb := make([]byte, 0, len(j.Coinb1)+len(en1)+len(en2)+len(j.Coinb2))
+	cb = append(cb, j.Coinb1...)
+	cb = append(cb, en1...)
+	cb = append(cb, en2...)
+	return append(cb, j.Coinb2...)
+}
+
+// Header - builds the block header for the given coinbase, time and nonce
+func (j *Job) Header(cb []byte, ntime, nonce uint32) []byte {
+	cbid := btc.Sha2Sum(cb)
+	root := btc.CheckMerkleBranch(cbid, j.Branch, 0)
+	hdr := make([]byte, 80)
+	binary.LittleEndian.PutUint32(hdr[0:4], j.Version)
+	copy(hdr[4:36], j.PrevHash)
+	copy(hdr[36:68], root[:])
+	binary.LittleEndian.PutUint32(hdr[68:72], ntime)
+	binary.LittleEndian.PutUint32(hdr[72:76], j.Bits)
+	binary.LittleEndian.PutUint32(hdr[76:80], nonce)
+	return hdr
+}
+
+// CoinbaseTx - returns the coinbase as it goes into the block (with the witness nonce, if needed)
+func (j *Job) CoinbaseTx(cb []byte) []byte {
+	if !j.Witness {
+		return cb
+	}
+	tx, _ := btc.NewTx(cb)
+	tx.SegWit = [][][]byte{{make([]byte, 32)}}
+	b := new(bytes.Buffer)
+	tx.WriteSerializedNew(b)
+	return b.Bytes()
+}
+
+// Block - serializes the whole block
+func (j *Job) Block(hdr, cb []byte) []byte {
+	b := bytes.NewBuffer(hdr)
+	btc.WriteVlen(b, uint64(len(j.Txs)+1))
+	b.Write(j.CoinbaseTx(cb))
+	for _, raw := range j.Txs {
+		b.Write(raw)
+	}
+	return b.Bytes()
+}
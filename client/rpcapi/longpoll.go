@@ -0,0 +1,85 @@
+package rpcapi
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ParallelCoinTeam/duod/client/common"
+)
+
+var (
+	tipMutex   sync.Mutex
+	tipChanged = make(chan bool) // closed (and replaced) on every new tip
+)
+
+// NewTip - wakes up the long polling "getblocktemplate" requests (call it after the chain's tip changed)
+func NewTip() {
+	tipMutex.Lock()
+	close(tipChanged)
+	tipChanged = make(chan bool)
+	tipMutex.Unlock()
+}
+
+// longPollID - the previous block's hash followed by the total fees of the template
+func longPollID(prevHash string, fees uint64) string {
+	return prevHash + strconv.FormatUint(fees, 10)
+}
+
+// templateRequest - returns the "template_request" object given to "getblocktemplate" (nil if none)
+func templateRequest(cmd *RPCCommand) map[string]interface{} {
+	if par := cmd.paramsArray(); len(par) > 0 {
+		if req, ok := par[0].(map[string]interface{}); ok {
+			return req
+		}
+	}
+	return nil
+}
+
+// waitLongPoll - blocks until the tip is different than in the longpollid
+// or until the template's fees rose by CFG.RPC.LongPollFeeGainPerc (checked every CFG.RPC.LongPollSec).
+// Returns false if the context got cancelled (the client went away).
+func waitLongPoll(ctx context.Context, id string) bool {
+	if len(id) < 64 {
+		return true
+	}
+	prevHash := id[:64]
+	oldFees, _ := strconv.ParseUint(id[64:], 10, 64)
+
+	for {
+		tipMutex.Lock()
+		ch := tipChanged
+		tipMutex.Unlock()
+
+		common.Last.Mutex.Lock()
+		last := common.Last.Block
+		common.Last.Mutex.Unlock()
+		if last.BlockHash.String() != prevHash {
+			common.CountSafe("RPCLongPollTip")
+			return true
+		}
+
+		common.LockCfg()
+		wait := time.Duration(common.CFG.RPC.LongPollSec) * time.Second
+		gain := common.CFG.RPC.LongPollFeeGainPerc
+		common.UnlockCfg()
+		if wait < time.Second {
+			wait = time.Second
+		}
+
+		select {
+		case <-ch:
+			// the tip may have changed - checked at the top of the loop
+		case <-ctx.Done():
+			common.CountSafe("RPCLongPollGone")
+			return false
+		case <-time.After(wait):
+			_, fees := GetTransactions(last.Height+1, uint32(time.Now().Unix()))
+			if fees > oldFees && float64(fees-oldFees) >= float64(oldFees)*gain/100 {
+				common.CountSafe("RPCLongPollFees")
+				return true
+			}
+		}
+	}
+}
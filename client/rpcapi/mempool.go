@@ -0,0 +1,144 @@
+package rpcapi
+
+import (
+	"github.com/ParallelCoinTeam/duod/client/common"
+	"github.com/ParallelCoinTeam/duod/client/network"
+	"github.com/ParallelCoinTeam/duod/lib/btc"
+)
+
+// MempoolEntry - the result of "getmempoolentry" (and "getrawmempool" with verbose set)
+type MempoolEntry struct {
+	VSize           int      `json:"vsize"`
+	Weight          int      `json:"weight"`
+	Fee             float64  `json:"fee"`
+	Time            int64    `json:"time"`
+	DescendantCount int      `json:"descendantcount"`
+	DescendantSize  int      `json:"descendantsize"`
+	DescendantFees  uint64   `json:"descendantfees"`
+	AncestorCount   int      `json:"ancestorcount"`
+	AncestorSize    int      `json:"ancestorsize"`
+	AncestorFees    uint64   `json:"ancestorfees"`
+	WTxID           string   `json:"wtxid"`
+	Depends         []string `json:"depends"`
+	SpentBy         []string `json:"spentby"`
+	Local           bool     `json:"local"`
+	Blocked         string   `json:"blocked,omitempty"`
+}
+
+// mempoolEntry - call it with TxMutex locked
+// Ancestor and descendant values include the transaction itself, as in bitcoind.
+func mempoolEntry(t2s *network.OneTxToSend) *MempoolEntry {
+	res := &MempoolEntry{
+		VSize:   t2s.VSize(),
+		Weight:  t2s.Weight(),
+		Fee:     float64(t2s.Fee) / 1e8,
+		Time:    t2s.Firstseen.Unix(),
+		WTxID:   t2s.WTxID().String(),
+		Local:   t2s.Local,
+		Blocked: network.ReasonToString(t2s.Blocked),
+		Depends: []string{},
+		SpentBy: []string{},
+	}
+
+	res.AncestorCount, res.AncestorSize, res.AncestorFees = 1, res.VSize, t2s.Fee
+	for _, p := range t2s.GetAllParents() {
+		res.AncestorCount++
+		res.AncestorSize += p.VSize()
+		res.AncestorFees += p.Fee
+	}
+	res.DescendantCount, res.DescendantSize, res.DescendantFees = 1, res.VSize, t2s.Fee
+	for _, ch := range t2s.GetAllChildren() {
+		res.DescendantCount++
+		res.DescendantSize += ch.VSize()
+		res.DescendantFees += ch.Fee
+	}
+
+	if t2s.MemInputCnt > 0 {
+		already := make(map[network.BIDX]bool)
+		for i, in := range t2s.TxIn {
+			if t2s.MemInputs[i] {
+				if bidx := btc.BIdx(in.Input.Hash[:]); !already[bidx] {
+					already[bidx] = true
+					res.Depends = append(res.Depends, btc.NewUint256(in.Input.Hash[:]).String())
+				}
+			}
+		}
+	}
+	for _, ch := range t2s.GetChildren() {
+		res.SpentBy = append(res.SpentBy, ch.Hash.String())
+	}
+	return res
+}
+
+// GetMempoolInfo -
+func GetMempoolInfo(cmd *RPCCommand, resp *RPCResponse) {
+	network.TxMutex.Lock()
+	var totfee uint64
+	for _, t2s := range network.TransactionsToSend {
+		totfee += t2s.Fee
+	}
+	res := map[string]interface{}{
+		"loaded":           common.CFG.TXPool.Enabled,
+		"size":             len(network.TransactionsToSend),
+		"bytes":            network.TransactionsToSendSize,
+		"weight":           network.TransactionsToSendWeight,
+		"total_fee":        float64(totfee) / 1e8,
+		"maxmempool":       common.MaxMempoolSize(),
+		"mempoolminfee":    float64(common.MinFeePerKB()) / 1e8,
+		"minrelaytxfee":    float64(common.RouteMinFeePerKB()) / 1e8,
+		"rejected":         len(network.TransactionsRejected),
+		"rejected_bytes":   network.TransactionsRejectedSize,
+		"pending":          len(network.TransactionsPending),
+		"awaiting_inputs":  len(network.WaitingForInputs),
+		"spent_outs_count": len(network.SpentOutputs),
+	}
+	network.TxMutex.Unlock()
+	resp.Result = res
+}
+
+// GetRawMempool - params: [verbose]
+func GetRawMempool(cmd *RPCCommand, resp *RPCResponse) {
+	verbose, ok := paramBool(cmd.paramsArray(), 0, false)
+	if !ok {
+		resp.Error = RPCError{Code: RPCErrInvalidParams, Message: "Verbose must be a boolean"}
+		return
+	}
+
+	network.TxMutex.Lock()
+	defer network.TxMutex.Unlock()
+
+	if !verbose {
+		res := make([]string, 0, len(network.TransactionsToSend))
+		for _, t2s := range network.TransactionsToSend {
+			res = append(res, t2s.Hash.String())
+		}
+		resp.Result = res
+		return
+	}
+
+	res := make(map[string]*MempoolEntry, len(network.TransactionsToSend))
+	for _, t2s := range network.TransactionsToSend {
+		res[t2s.Hash.String()] = mempoolEntry(t2s)
+	}
+	resp.Result = res
+}
+
+// GetMempoolEntry - params: [txid]
+func GetMempoolEntry(cmd *RPCCommand, resp *RPCResponse) {
+	hs, _ := paramString(cmd.paramsArray(), 0)
+	txid := btc.NewUint256FromString(hs)
+	if txid == nil {
+		resp.Error = RPCError{Code: RPCErrInvalidParams, Message: "Transaction ID expected"}
+		return
+	}
+
+	network.TxMutex.Lock()
+	defer network.TxMutex.Unlock()
+
+	t2s, ok := network.TransactionsToSend[txid.BIdx()]
+	if !ok {
+		resp.Error = RPCError{Code: RPCErrInvalidAddressOrKey, Message: "Transaction not in mempool"}
+		return
+	}
+	resp.Result = mempoolEntry(t2s)
+}
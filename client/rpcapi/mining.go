@@ -33,17 +33,18 @@ type GetBlockTemplateResp struct {
 	Coinbaseaux       struct {
 		Flags string `json:"flags"`
 	} `json:"coinbaseaux"`
-	Coinbasevalue uint64   `json:"coinbasevalue"`
-	Longpollid    string   `json:"longpollid"`
-	Target        string   `json:"target"`
-	Mintime       uint     `json:"mintime"`
-	Mutable       []string `json:"mutable"`
-	Noncerange    string   `json:"noncerange"`
-	Sigoplimit    uint     `json:"sigoplimit"`
-	Sizelimit     uint     `json:"sizelimit"`
-	Curtime       uint     `json:"curtime"`
-	Bits          string   `json:"bits"`
-	Height        uint     `json:"height"`
+	Coinbasevalue uint64          `json:"coinbasevalue"`
+	Coinbasetxn   *OneTransaction `json:"coinbasetxn,omitempty"`
+	Longpollid    string          `json:"longpollid"`
+	Target        string          `json:"target"`
+	Mintime       uint            `json:"mintime"`
+	Mutable       []string        `json:"mutable"`
+	Noncerange    string          `json:"noncerange"`
+	Sigoplimit    uint            `json:"sigoplimit"`
+	Sizelimit     uint            `json:"sizelimit"`
+	Curtime       uint            `json:"curtime"`
+	Bits          string          `json:"bits"`
+	Height        uint            `json:"height"`
 }
 
 // RPCGetBlockTemplateResp -
@@ -68,13 +69,13 @@ func GetNextBlockTemplate(r *GetBlockTemplateResp) {
 	bits := common.BlockChain.GetNextWorkRequired(common.Last.Block, uint32(r.Curtime))
 	target := btc.SetCompact(bits).Bytes()
 
-	r.Capabilities = []string{"proposal"}
+	r.Capabilities = []string{"proposal", "longpoll"}
 	r.Version = 4
 	r.PreviousBlockHash = common.Last.Block.BlockHash.String()
 	r.Transactions, r.Coinbasevalue = GetTransactions(height, uint32(r.Mintime))
+	r.Longpollid = longPollID(r.PreviousBlockHash, r.Coinbasevalue)
 	r.Coinbasevalue += btc.GetBlockReward(height)
 	r.Coinbaseaux.Flags = ""
-	r.Longpollid = r.PreviousBlockHash
 	r.Target = hex.EncodeToString(append(zer[:32-len(target)], target...))
 	r.Mutable = []string{"time", "transactions", "prevblock"}
 	r.Noncerange = "00000000ffffffff"
@@ -89,6 +90,40 @@ func GetNextBlockTemplate(r *GetBlockTemplateResp) {
 	common.Last.Mutex.Unlock()
 }
 
+// requestCapability - checks if the template request lists the capability
+func requestCapability(req map[string]interface{}, name string) bool {
+	caps, _ := req["capabilities"].([]interface{})
+	for _, c := range caps {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// AddCoinbaseTxn - puts the coinbase built by the node (for the Mining section's payouts) into the template.
+// The extranonce in the input script is zeroed.
+func AddCoinbaseTxn(r *GetBlockTemplateResp) error {
+	payouts, er := common.MiningPayouts()
+	if er != nil {
+		return er
+	}
+	common.LockCfg()
+	cp := &CoinbaseParams{Payouts: payouts, Tag: common.CFG.Mining.CoinbaseTag,
+		ExtranonceSize: int(common.CFG.Mining.ExtranonceSize)}
+	common.UnlockCfg()
+	j, er := JobFromTemplate("", r, cp)
+	if er != nil {
+		return er
+	}
+	cb := j.Coinbase(make([]byte, cp.ExtranonceSize), nil)
+	tx, _ := btc.NewTx(cb)
+	r.Coinbasetxn = &OneTransaction{Data: hex.EncodeToString(j.CoinbaseTx(cb)), Hash: btc.NewSha2Hash(cb).String(),
+		Depends: []uint{}, Sigops: uint64(tx.GetLegacySigOpCount()) * btc.WitnessScaleFactor}
+	r.Mutable = append(r.Mutable, "coinbase/append")
+	return nil
+}
+
 /* memory pool transaction sorting stuff */
 type oneMiningTx struct {
 	*network.OneTxToSend
@@ -100,38 +135,43 @@ type sortedTxList []*oneMiningTx
 
 func (tl sortedTxList) Len() int           { return len(tl) }
 func (tl sortedTxList) Swap(i, j int)      { tl[i], tl[j] = tl[j], tl[i] }
-func (tl sortedTxList) Less(i, j int) bool { return tl[j].Fee < tl[i].Fee }
+func (tl sortedTxList) Less(i, j int) bool { return tl[j].ModifiedFee() < tl[i].ModifiedFee() }
 
 var txsSoFar map[[32]byte]uint
 var totlen int
 var sigops uint64
 
+// modifiedFeePerByte - the fee (with the delta from prioritisetransaction) per virtual byte
+func modifiedFeePerByte(v *network.OneTxToSend) float64 {
+	return float64(v.ModifiedFee()) / float64(v.VSize())
+}
+
 // getNextTrancheOfTxs -
 func getNextTrancheOfTxs(height, timestamp uint32) (res sortedTxList) {
 	var unsp *btc.TxOut
 	var allInputsFound bool
-	for _, v := range network.TransactionsToSend {
-		tx := v.Tx
 
-		if _, ok := txsSoFar[tx.Hash.Hash]; ok {
-			continue
+	// the best paying ones go first, so they are the ones that fit into the block
+	var cands []*network.OneTxToSend
+	for _, v := range network.TransactionsToSend {
+		if _, ok := txsSoFar[v.Tx.Hash.Hash]; !ok && v.Tx.IsFinal(height, timestamp) {
+			cands = append(cands, v)
 		}
+	}
+	sort.Slice(cands, func(i, j int) bool { return modifiedFeePerByte(cands[i]) > modifiedFeePerByte(cands[j]) })
 
-		if !tx.IsFinal(height, timestamp) {
-			continue
-		}
+	for _, v := range cands {
+		tx := v.Tx
 
 		if totlen+len(v.Raw) > 1e6 {
 			L.Debug("Too many txs - limit to 999000 bytes")
-			return
+			continue
 		}
-		totlen += len(v.Raw)
 
 		if sigops+v.SigopsCost > btc.MaxBlockSigOpsCost {
 			L.Debug("Too many sigops - limit to 999000 bytes")
-			return
+			continue
 		}
-		sigops += v.SigopsCost
 
 		allInputsFound = true
 		var depends []uint
@@ -151,6 +191,8 @@ func getNextTrancheOfTxs(height, timestamp uint32) (res sortedTxList) {
 		}
 
 		if allInputsFound {
+			totlen += len(v.Raw)
+			sigops += v.SigopsCost
 			res = append(res, &oneMiningTx{OneTxToSend: v, depends: depends, startat: 1 + len(txsSoFar)})
 		}
 	}
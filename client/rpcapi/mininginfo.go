@@ -0,0 +1,143 @@
+package rpcapi
+
+import (
+	"time"
+
+	"github.com/ParallelCoinTeam/duod/client/common"
+	"github.com/ParallelCoinTeam/duod/client/network"
+	"github.com/ParallelCoinTeam/duod/client/usif"
+	"github.com/ParallelCoinTeam/duod/lib/btc"
+	"github.com/ParallelCoinTeam/duod/lib/chain"
+)
+
+// networkHashPS - estimates the hashrate from the given number of blocks, ending at the given height
+// (call it with BlockIndexAccess locked)
+func networkHashPS(nblocks int64, height int64) float64 {
+	last := common.BlockChain.LastBlock()
+	if height >= 0 && height < int64(last.Height) {
+		last = nodeAtHeight(uint32(height))
+	}
+	if int64(last.Height) < nblocks {
+		nblocks = int64(last.Height)
+	}
+	if nblocks <= 0 {
+		return 0
+	}
+
+	var work float64
+	first := last
+	for i := int64(0); i < nblocks; i++ {
+		work += btc.GetDifficulty(first.Bits())
+		first = first.Parent
+	}
+	if last.Timestamp() <= first.Timestamp() {
+		return 0
+	}
+	return work * btc.HashesPerDifficulty / float64(last.Timestamp()-first.Timestamp())
+}
+
+// GetMiningInfo -
+func GetMiningInfo(cmd *RPCCommand, resp *RPCResponse) {
+	common.Last.Mutex.Lock()
+	last := common.Last.Block
+	common.Last.Mutex.Unlock()
+
+	network.TxMutex.Lock()
+	pooled := len(network.TransactionsToSend)
+	network.TxMutex.Unlock()
+
+	hr := usif.GetNetworkHashRateNum()
+	chainName := "main"
+	if common.Testnet {
+		chainName = "test"
+	}
+	resp.Result = map[string]interface{}{
+		"blocks":            last.Height,
+		"currentblocksize":  last.BlockSize,
+		"currentblocktx":    last.TxCount,
+		"difficulty":        btc.GetDifficulty(last.Bits()),
+		"networkhashps":     hr,
+		"networkhashps_str": common.HashrateToString(hr),
+		"hashrate_hours":    common.CFG.Stat.HashrateHrs,
+		"pooledtx":          pooled,
+		"chain":             chainName,
+		"warnings":          network.StaleTipWarning(),
+	}
+}
+
+// GetNetworkHashPS - params: [nblocks, height]
+// Without nblocks (or if it is not positive), the estimate from the last CFG.Stat.HashrateHrs is returned.
+func GetNetworkHashPS(cmd *RPCCommand, resp *RPCResponse) {
+	par := cmd.paramsArray()
+	nblocks, ok1 := paramInt(par, 0, 0)
+	height, ok2 := paramInt(par, 1, -1)
+	if !ok1 || !ok2 {
+		resp.Error = RPCError{Code: RPCErrInvalidParams, Message: "nblocks and height must be numbers"}
+		return
+	}
+	if nblocks <= 0 && height < 0 {
+		resp.Result = usif.GetNetworkHashRateNum()
+		return
+	}
+	if nblocks <= 0 {
+		nblocks = int64(common.CFG.Stat.HashrateHrs) * 3600 / chain.TargetSpacing
+	}
+	common.BlockChain.BlockIndexAccess.Lock()
+	resp.Result = networkHashPS(nblocks, height)
+	common.BlockChain.BlockIndexAccess.Unlock()
+}
+
+// GetMinerStats - params: [from, to]
+// Returns the miners' shares, hashrates and orphan rates for blocks with timestamps in the window.
+// Without from, the last CFG.Stat.MiningHrs are taken. Without to, the window ends now.
+// Miners are identified by miners.json from the data folder (if there is one).
+func GetMinerStats(cmd *RPCCommand, resp *RPCResponse) {
+	par := cmd.paramsArray()
+	from, ok1 := paramInt(par, 0, 0)
+	to, ok2 := paramInt(par, 1, 0)
+	if !ok1 || !ok2 || from < 0 || to < 0 {
+		resp.Error = RPCError{Code: RPCErrInvalidParams, Message: "from and to must be unix times"}
+		return
+	}
+	if from == 0 {
+		common.LockCfg()
+		from = time.Now().Unix() - int64(common.CFG.Stat.MiningHrs)*3600
+		common.UnlockCfg()
+	}
+	if to != 0 && to < from {
+		resp.Error = RPCError{Code: RPCErrInvalidParameter, Message: "to must not be before from"}
+		return
+	}
+	common.ReloadMiners()
+	resp.Result = usif.GetMiningStats(uint32(from), uint32(to))
+}
+
+// GetDifficulty -
+func GetDifficulty(cmd *RPCCommand, resp *RPCResponse) {
+	common.Last.Mutex.Lock()
+	resp.Result = btc.GetDifficulty(common.Last.Block.Bits())
+	common.Last.Mutex.Unlock()
+}
+
+// PrioritiseTransaction - params: [txid, dummy, fee_delta]
+// The fee delta (in satoshis) is used for sorting the mempool and for block templates.
+func PrioritiseTransaction(cmd *RPCCommand, resp *RPCResponse) {
+	par := cmd.paramsArray()
+	hs, _ := paramString(par, 0)
+	txid := btc.NewUint256FromString(hs)
+	if txid == nil {
+		resp.Error = RPCError{Code: RPCErrInvalidParams, Message: "Transaction ID expected"}
+		return
+	}
+	if dummy, ok := paramInt(par, 1, 0); !ok || dummy != 0 {
+		resp.Error = RPCError{Code: RPCErrInvalidParameter, Message: "Priority is no longer supported, dummy argument to prioritisetransaction must be 0."}
+		return
+	}
+	delta, ok := paramInt(par, 2, 0)
+	if !ok || len(par) < 3 {
+		resp.Error = RPCError{Code: RPCErrInvalidParams, Message: "Fee delta expected"}
+		return
+	}
+	network.PrioritiseTx(txid, delta)
+	resp.Result = true
+}
@@ -0,0 +1,179 @@
+package rpcapi
+
+import (
+	"fmt"
+
+	"github.com/ParallelCoinTeam/duod"
+	"github.com/ParallelCoinTeam/duod/client/common"
+	"github.com/ParallelCoinTeam/duod/client/network"
+	"github.com/ParallelCoinTeam/duod/lib/others/peersdb"
+)
+
+// PeerInfo - one record of "getpeerinfo"
+type PeerInfo struct {
+	ID             uint32            `json:"id"`
+	Addr           string            `json:"addr"`
+	AddrLocal      string            `json:"addrlocal,omitempty"`
+	Services       string            `json:"services"`
+	RelayTxes      bool              `json:"relaytxes"`
+	LastSend       int64             `json:"lastsend"`
+	LastRecv       int64             `json:"lastrecv"`
+	BytesSent      uint64            `json:"bytessent"`
+	BytesRecv      uint64            `json:"bytesrecv"`
+	ConnTime       int64             `json:"conntime"`
+	PingTime       float64           `json:"pingtime"`
+	Version        uint32            `json:"version"`
+	SubVer         string            `json:"subver"`
+	Inbound        bool              `json:"inbound"`
+	ConnectionType string            `json:"connection_type"`
+	StartingHeight uint32            `json:"startingheight"`
+	MinFeeFilter   float64           `json:"minfeefilter"`
+	InFlight       int               `json:"inflight"`
+	Permissions    string            `json:"permissions"`
+	Special        bool              `json:"special"`
+	Counters       map[string]uint64 `json:"counters"`
+}
+
+// GetPeerInfo - returns the same data as /netcon.json, in bitcoind's format
+func GetPeerInfo(cmd *RPCCommand, resp *RPCResponse) {
+	network.MutexNet.Lock()
+	tmp, _, _ := network.GetSortedConnections()
+	res := make([]*PeerInfo, len(tmp))
+	for i, v := range tmp {
+		var ci network.ConnInfo
+		v.Conn.GetStats(&ci)
+		p := &PeerInfo{
+			ID:             ci.ID,
+			Addr:           ci.RemoteAddr,
+			AddrLocal:      ci.LocalAddr,
+			Services:       fmt.Sprintf("%016x", ci.Services),
+			RelayTxes:      !ci.DoNotRelayTxs,
+			LastSend:       ci.LastSent.Unix(),
+			LastRecv:       ci.LastDataGot.Unix(),
+			BytesSent:      ci.BytesSent,
+			BytesRecv:      ci.BytesReceived,
+			ConnTime:       ci.ConnectedAt.Unix(),
+			PingTime:       float64(ci.AveragePing) / 1000,
+			Version:        ci.Version,
+			SubVer:         ci.Agent,
+			Inbound:        ci.Incomming,
+			StartingHeight: ci.Height,
+			MinFeeFilter:   float64(ci.MinFeeSPKB) / 1e8,
+			InFlight:       ci.BlocksInProgress,
+			Permissions:    ci.PermissionList,
+			Special:        ci.IsSpecial,
+			Counters:       ci.Counters,
+		}
+		if p.Addr == "" {
+			p.Addr = ci.PeerIP
+		}
+		switch {
+		case ci.Incomming:
+			p.ConnectionType = "inbound"
+		case ci.BlockRelayOnly:
+			p.ConnectionType = "block-relay-only"
+		case ci.IsSpecial:
+			p.ConnectionType = "manual"
+		default:
+			p.ConnectionType = "outbound-full-relay"
+		}
+		res[len(res)-1-i] = p // GetSortedConnections returns the worst ones first
+	}
+	network.MutexNet.Unlock()
+	resp.Result = res
+}
+
+// GetNetworkInfo -
+func GetNetworkInfo(cmd *RPCCommand, resp *RPCResponse) {
+	network.MutexNet.Lock()
+	cnt := len(network.OpenCons)
+	out := network.OutConsActive + network.BlockRelayConsActive
+	network.MutexNet.Unlock()
+
+	common.LockCfg()
+	agent := common.UserAgent
+	relay := common.CFG.TXRoute.Enabled
+	common.UnlockCfg()
+
+	resp.Result = map[string]interface{}{
+		"version":         Duod.Version,
+		"subversion":      agent,
+		"protocolversion": common.Version,
+		"localservices":   fmt.Sprintf("%016x", common.Services),
+		"localrelay":      relay,
+		"timeoffset":      0,
+		"networkactive":   true,
+		"connections":     cnt,
+		"connections_in":  cnt - int(out),
+		"connections_out": out,
+		"relayfee":        float64(common.MinFeePerKB()) / 1e8,
+		"warnings":        network.StaleTipWarning(),
+	}
+}
+
+// AddNode - params: ["ip:port", "add"|"onetry"]
+// There is no persistent list of added nodes, so both commands just connect to the node.
+func AddNode(cmd *RPCCommand, resp *RPCResponse) {
+	par := cmd.paramsArray()
+	node, ok1 := paramString(par, 0)
+	what, ok2 := paramString(par, 1)
+	if !ok1 || !ok2 {
+		resp.Error = RPCError{Code: RPCErrInvalidParams, Message: "Node address and command expected"}
+		return
+	}
+	if what != "add" && what != "onetry" {
+		resp.Error = RPCError{Code: RPCErrInvalidParameter, Message: "Only \"add\" and \"onetry\" commands are supported"}
+		return
+	}
+	ad, er := peersdb.NewAddrFromString(node, false)
+	if er != nil {
+		resp.Error = RPCError{Code: RPCErrInvalidParameter, Message: er.Error()}
+		return
+	}
+	if network.ConnectionActive(ad) {
+		resp.Error = RPCError{Code: RPCErrClientNodeAlreadyAdded, Message: "Node already connected"}
+		return
+	}
+	ad.Manual = true
+	network.DoNetwork(ad)
+}
+
+// DisconnectNode - params: ["ip:port"] or ["", nodeid]
+// Unlike "kill" from the UI, the peer does not get banned.
+func DisconnectNode(cmd *RPCCommand, resp *RPCResponse) {
+	par := cmd.paramsArray()
+	node, _ := paramString(par, 0)
+	id, ok := paramInt(par, 1, -1)
+	if !ok || node == "" && id < 0 || node != "" && id >= 0 {
+		resp.Error = RPCError{Code: RPCErrInvalidParams, Message: "Either the node address or the node id must be given"}
+		return
+	}
+
+	network.MutexNet.Lock()
+	if node != "" {
+		id = -1
+		if ad, er := peersdb.NewAddrFromString(node, false); er == nil {
+			if c, ok := network.OpenCons[ad.UniqID()]; ok {
+				id = int64(c.ConnID)
+			}
+		}
+	} else {
+		var found bool
+		for _, c := range network.OpenCons {
+			if int64(c.ConnID) == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			id = -1
+		}
+	}
+	network.MutexNet.Unlock()
+
+	if id < 0 {
+		resp.Error = RPCError{Code: RPCErrClientNodeNotConnected, Message: "Node not found in connected nodes"}
+		return
+	}
+	network.DisconnectPeer(uint32(id))
+}
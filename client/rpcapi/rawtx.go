@@ -0,0 +1,304 @@
+package rpcapi
+
+import (
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/ParallelCoinTeam/duod/client/common"
+	"github.com/ParallelCoinTeam/duod/client/network"
+	"github.com/ParallelCoinTeam/duod/client/usif"
+	"github.com/ParallelCoinTeam/duod/lib/btc"
+)
+
+// decodeRawTx - returns the transaction from the given hex string or nil if it cannot be decoded
+func decodeRawTx(hs string) *btc.Tx {
+	raw, er := hex.DecodeString(hs)
+	if er != nil || len(raw) == 0 {
+		return nil
+	}
+	tx, le := btc.NewTx(raw)
+	if tx == nil || le != len(raw) {
+		return nil
+	}
+	tx.SetHash(raw)
+	return tx
+}
+
+// txFromBlock - looks for the transaction in the given block (lock BlockIndexAccess)
+// Returns the transaction and the number of confirmations of the block (0 if not on the main chain).
+func txFromBlock(hs string, txid *btc.Uint256) (tx *btc.Tx, conf int, er *RPCError) {
+	n := findNode(hs)
+	if n == nil {
+		return nil, 0, &RPCError{Code: RPCErrInvalidAddressOrKey, Message: "Block hash not found"}
+	}
+	bd, _, e := common.BlockChain.Blocks.BlockGet(n.BlockHash)
+	if e != nil {
+		return nil, 0, &RPCError{Code: RPCErrMisc, Message: "Block not available"}
+	}
+	bl, e := btc.NewBlock(bd)
+	if e == nil {
+		e = bl.BuildTxList()
+	}
+	if e != nil {
+		return nil, 0, &RPCError{Code: RPCErrMisc, Message: e.Error()}
+	}
+	for _, t := range bl.Txs {
+		if t.Hash.Equal(txid) {
+			if common.BlockChain.OnActiveBranch(n) {
+				conf = int(common.BlockChain.LastBlock().Height-n.Height) + 1
+			}
+			return t, conf, nil
+		}
+	}
+	return nil, 0, &RPCError{Code: RPCErrInvalidAddressOrKey, Message: "No such transaction found in the provided block"}
+}
+
+// GetRawTransaction - returns a transaction from the memory pool or, if the block hash is given, from the block
+func GetRawTransaction(cmd *RPCCommand, resp *RPCResponse) {
+	par := cmd.paramsArray()
+	hs, ok := paramString(par, 0)
+	txid := btc.NewUint256FromString(hs)
+	if !ok || txid == nil {
+		resp.Error = RPCError{Code: RPCErrInvalidParams, Message: "Transaction ID expected"}
+		return
+	}
+	verbose, ok := paramBool(par, 1, false)
+	if !ok {
+		resp.Error = RPCError{Code: RPCErrInvalidParams, Message: "Verbose must be a boolean"}
+		return
+	}
+
+	var tx *btc.Tx
+	var blockHash string
+	var conf int
+	if bh, _ := paramString(par, 2); bh != "" {
+		var rer *RPCError
+		common.BlockChain.BlockIndexAccess.Lock()
+		tx, conf, rer = txFromBlock(bh, txid)
+		common.BlockChain.BlockIndexAccess.Unlock()
+		if rer != nil {
+			resp.Error = *rer
+			return
+		}
+		blockHash = bh
+	} else {
+		network.TxMutex.Lock()
+		if t2s, ok := network.TransactionsToSend[txid.BIdx()]; ok {
+			tx = t2s.Tx
+		}
+		network.TxMutex.Unlock()
+		if tx == nil {
+			resp.Error = RPCError{Code: RPCErrInvalidAddressOrKey,
+				Message: "No such mempool transaction. Provide the hash of the block it was mined in."}
+			return
+		}
+	}
+
+	if !verbose {
+		resp.Result = hex.EncodeToString(tx.Raw)
+		return
+	}
+	res := TxToJSON(tx)
+	if blockHash != "" {
+		res["blockhash"] = blockHash
+		res["confirmations"] = conf
+	}
+	resp.Result = res
+}
+
+// DecodeRawTransaction -
+func DecodeRawTransaction(cmd *RPCCommand, resp *RPCResponse) {
+	hs, _ := paramString(cmd.paramsArray(), 0)
+	tx := decodeRawTx(hs)
+	if tx == nil {
+		resp.Error = RPCError{Code: RPCErrDeserialization, Message: "TX decode failed"}
+		return
+	}
+	resp.Result = TxToJSON(tx)
+}
+
+// SendRawTransaction - puts the transaction into the memory pool and broadcasts it
+func SendRawTransaction(cmd *RPCCommand, resp *RPCResponse) {
+	hs, _ := paramString(cmd.paramsArray(), 0)
+	tx := decodeRawTx(hs)
+	if tx == nil {
+		resp.Error = RPCError{Code: RPCErrDeserialization, Message: "TX decode failed"}
+		return
+	}
+
+	// HandleNetTx must be called from the chain's thread
+	lck := new(usif.OneLock)
+	lck.In.Add(1)
+	lck.Out.Add(1)
+	usif.LocksChan <- lck
+	lck.In.Wait()
+	defer lck.Out.Done()
+
+	network.RemoveFromRejected(&tx.Hash) // in case we rejected it earlier, to try it again
+
+	var submitted bool
+	switch network.NeedThisTxExt(&tx.Hash, nil) {
+	case 0:
+		// API clients are not trusted - the scripts and the policy get checked as for txs from peers
+		if !network.SubmitUntrustedTx(tx) {
+			var reason string
+			network.TxMutex.Lock()
+			if rr := network.TransactionsRejected[tx.Hash.BIdx()]; rr != nil {
+				reason = network.ReasonToString(rr.Reason)
+			}
+			network.TxMutex.Unlock()
+			if reason == "" {
+				reason = "UNKNOWN"
+			}
+			resp.Error = RPCError{Code: RPCErrVerifyRejected, Message: reason}
+			return
+		}
+		submitted = true
+	case 1:
+		// already in the memory pool - broadcast it again, if it has passed the routing policy
+	case 4:
+		resp.Error = RPCError{Code: RPCErrVerifyAlreadyInChain, Message: "Transaction already in block chain"}
+		return
+	default:
+		resp.Error = RPCError{Code: RPCErrVerify, Message: "Transaction is being processed"}
+		return
+	}
+
+	var resend bool
+	network.TxMutex.Lock()
+	t2s := network.TransactionsToSend[tx.Hash.BIdx()]
+	if t2s != nil {
+		t2s.Local = true
+		resend = !submitted && t2s.Invsentcnt > 0
+	}
+	network.TxMutex.Unlock()
+	if t2s == nil {
+		resp.Error = RPCError{Code: RPCErrVerifyRejected, Message: "Transaction not accepted to the memory pool"}
+		return
+	}
+	if resend {
+		cnt := network.NetRouteInv(network.MsgTx, &tx.Hash, nil)
+		network.TxMutex.Lock()
+		t2s.Invsentcnt += cnt
+		network.TxMutex.Unlock()
+	}
+	resp.Result = tx.Hash.String()
+}
+
+// CreateRawTransaction - builds an unsigned transaction
+// params: [{"txid":"id","vout":n,"sequence":n},...] {"address":amount,"data":"hex",...} [locktime]
+func CreateRawTransaction(cmd *RPCCommand, resp *RPCResponse) {
+	par := cmd.paramsArray()
+	if len(par) < 2 {
+		resp.Error = RPCError{Code: RPCErrInvalidParams, Message: "Inputs and outputs expected"}
+		return
+	}
+	ins, ok := par[0].([]interface{})
+	if !ok {
+		resp.Error = RPCError{Code: RPCErrInvalidParams, Message: "Inputs must be an array"}
+		return
+	}
+	locktime, ok := paramInt(par, 2, 0)
+	if !ok || locktime < 0 || locktime > 0xffffffff {
+		resp.Error = RPCError{Code: RPCErrInvalidParameter, Message: "Invalid parameter, locktime out of range"}
+		return
+	}
+
+	tx := &btc.Tx{Version: 2, LockTime: uint32(locktime)}
+	for _, v := range ins {
+		in, ok := v.(map[string]interface{})
+		if !ok {
+			resp.Error = RPCError{Code: RPCErrInvalidParameter, Message: "Invalid parameter, input must be an object"}
+			return
+		}
+		id, _ := in["txid"].(string)
+		txid := btc.NewUint256FromString(id)
+		if txid == nil {
+			resp.Error = RPCError{Code: RPCErrInvalidParameter, Message: "Invalid parameter, txid expected"}
+			return
+		}
+		vout, ok := jsonUint32(in["vout"])
+		if !ok {
+			resp.Error = RPCError{Code: RPCErrInvalidParameter, Message: "Invalid parameter, vout must be a positive number"}
+			return
+		}
+		seq := uint32(0xffffffff)
+		if locktime != 0 {
+			seq = 0xfffffffe // so the locktime is enforced
+		}
+		if in["sequence"] != nil {
+			if seq, ok = jsonUint32(in["sequence"]); !ok {
+				resp.Error = RPCError{Code: RPCErrInvalidParameter, Message: "Invalid parameter, sequence number is out of range"}
+				return
+			}
+		}
+		txin := &btc.TxIn{Sequence: seq}
+		copy(txin.Input.Hash[:], txid.Hash[:])
+		txin.Input.Vout = vout
+		tx.TxIn = append(tx.TxIn, txin)
+	}
+
+	outs, ok := par[1].(map[string]interface{})
+	if !ok {
+		resp.Error = RPCError{Code: RPCErrInvalidParams, Message: "Outputs must be an object"}
+		return
+	}
+	seen := make(map[string]bool, len(outs))
+	for _, k := range cmd.paramKeys(1, outs) {
+		if seen[k] {
+			resp.Error = RPCError{Code: RPCErrInvalidParameter, Message: "Invalid parameter, duplicated key: " + k}
+			return
+		}
+		seen[k] = true
+		v := outs[k]
+		if k == "data" {
+			s, _ := v.(string)
+			dat, er := hex.DecodeString(s)
+			if er != nil {
+				resp.Error = RPCError{Code: RPCErrInvalidParameter, Message: "Invalid parameter, data must be hexadecimal"}
+				return
+			}
+			pk := []byte{0x6a}
+			switch {
+			case len(dat) <= 75:
+				pk = append(pk, byte(len(dat)))
+			case len(dat) <= 0xff:
+				pk = append(pk, 0x4c, byte(len(dat))) // OP_PUSHDATA1
+			case len(dat) <= btc.MaxScriptElementSize:
+				pk = append(pk, 0x4d, byte(len(dat)), byte(len(dat)>>8)) // OP_PUSHDATA2
+			default:
+				resp.Error = RPCError{Code: RPCErrInvalidParameter, Message: "Invalid parameter, data too long"}
+				return
+			}
+			tx.TxOut = append(tx.TxOut, &btc.TxOut{PkScript: append(pk, dat...)})
+			continue
+		}
+		ad, er := btc.NewAddrFromString(k)
+		if er != nil {
+			resp.Error = RPCError{Code: RPCErrInvalidAddressOrKey, Message: "Invalid address: " + k}
+			return
+		}
+		n, _ := v.(json.Number)
+		val, er := btc.StringToSatoshis(n.String())
+		if er != nil || n == "" {
+			resp.Error = RPCError{Code: RPCErrInvalidParameter, Message: "Invalid amount for " + k}
+			return
+		}
+		tx.TxOut = append(tx.TxOut, &btc.TxOut{Value: val, PkScript: ad.OutScript()})
+	}
+
+	resp.Result = hex.EncodeToString(tx.Serialize())
+}
+
+// jsonUint32 - converts a JSON number into uint32
+func jsonUint32(v interface{}) (uint32, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	i, er := n.Int64()
+	if er != nil || i < 0 || i > 0xffffffff {
+		return 0, false
+	}
+	return uint32(i), true
+}
@@ -0,0 +1,156 @@
+package rpcapi
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/ParallelCoinTeam/duod/client/common"
+	"github.com/ParallelCoinTeam/duod/lib/L"
+	"github.com/ParallelCoinTeam/duod/lib/others/refnode"
+)
+
+// RefNodeQueueLen - how many comparisons can wait for the reference node (the ones above are dropped)
+const RefNodeQueueLen = 64
+
+var (
+	refCmp      *refnode.Comparator
+	refCmpCfg   [3]string // URL, username and password that refCmp was made for
+	refCmpMutex sync.Mutex
+
+	refCmpQueue = make(chan refCmpJob, RefNodeQueueLen)
+	refCmpOnce  sync.Once
+)
+
+// refCmpJob - a result waiting to be compared with the reference node
+type refCmpJob struct {
+	method         string
+	params, result interface{}
+}
+
+// RefNode - returns the comparator for the reference node from CFG.RPC.RefNode (nil if not configured)
+func RefNode() *refnode.Comparator {
+	common.LockCfg()
+	cfg := [3]string{common.CFG.RPC.RefNode.URL, common.CFG.RPC.RefNode.Username, common.CFG.RPC.RefNode.Password}
+	common.UnlockCfg()
+
+	refCmpMutex.Lock()
+	defer refCmpMutex.Unlock()
+	if cfg[0] == "" {
+		refCmp = nil
+	} else if refCmp == nil || cfg != refCmpCfg {
+		refCmp = refnode.NewComparator(refnode.NewClient(cfg[0], cfg[1], cfg[2]))
+		refCmp.Filter = refNodeFilter
+	}
+	refCmpCfg = cfg
+	return refCmp
+}
+
+// refNodeCompared - returns true if results of the method are to be compared
+func refNodeCompared(method string) bool {
+	common.LockCfg()
+	defer common.UnlockCfg()
+	if common.CFG.RPC.RefNode.URL == "" {
+		return false
+	}
+	for _, m := range common.CFG.RPC.RefNode.Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// refNodeFilter - leaves only the values that should be the same on both nodes
+func refNodeFilter(method string, v interface{}) interface{} {
+	switch method {
+	case "getblocktemplate":
+		// the transactions (and so the fees) depend on the mempool, the times on the clock
+		if m, ok := v.(map[string]interface{}); ok {
+			res := make(map[string]interface{})
+			for _, k := range []string{"previousblockhash", "height", "bits", "target", "mintime"} {
+				if val, ok := m[k]; ok {
+					res[k] = val
+				}
+			}
+			return res
+		}
+
+	case "getblockheader", "getblock":
+		// may change between the two calls
+		if m, ok := v.(map[string]interface{}); ok {
+			delete(m, "confirmations")
+			delete(m, "nextblockhash")
+		}
+
+	case "getrawmempool":
+		// compare membership only, not the order
+		res := make(map[string]interface{})
+		switch vv := v.(type) {
+		case []interface{}:
+			for _, id := range vv {
+				if s, ok := id.(string); ok {
+					res[s] = true
+				}
+			}
+		case map[string]interface{}:
+			for id := range vv {
+				res[id] = true
+			}
+		}
+		return res
+	}
+	return v
+}
+
+// compareWithRefNode - checks our result against the reference node and logs the differences
+func compareWithRefNode(method string, params interface{}, result interface{}) *refnode.Result {
+	cmp := RefNode()
+	if cmp == nil {
+		return nil
+	}
+	res := cmp.Compare(method, params, result)
+	if res.Error != "" || len(res.Diffs) > 0 {
+		b, _ := json.Marshal(res)
+		L.Debug("RefNode mismatch: ", string(b))
+	}
+	return res
+}
+
+// queueRefNodeCompare - schedules comparison of the command's result (it does not block)
+// Long polling requests are not compared, as the reference node would wait with its answer.
+func queueRefNodeCompare(cmd *RPCCommand, result interface{}) {
+	if _, ok := templateRequest(cmd)["longpollid"]; ok {
+		return
+	}
+	refCmpOnce.Do(func() { go refNodeComparer() })
+	select {
+	case refCmpQueue <- refCmpJob{method: cmd.Method, params: cmd.Params, result: result}:
+	default:
+		common.CountSafe("RefNodeQueueFull")
+	}
+}
+
+// refNodeComparer - does the queued comparisons, one at a time
+func refNodeComparer() {
+	for j := range refCmpQueue {
+		compareWithRefNode(j.method, j.params, j.result)
+	}
+}
+
+// CompareNow - executes the method locally and on the reference node and returns the comparison
+func CompareNow(method string, params []interface{}) (*refnode.Result, error) {
+	if RefNode() == nil {
+		return nil, refnode.ErrDisabled
+	}
+	if params == nil {
+		params = []interface{}{}
+	}
+	cmd := &RPCCommand{Method: method, Params: params}
+	var resp RPCResponse
+	execCommand(cmd, &resp)
+	if resp.Error != nil {
+		b, _ := json.Marshal(resp.Error)
+		return &refnode.Result{Method: method, Params: params, Error: "ours: " + string(b)}, nil
+	}
+	return compareWithRefNode(method, params, resp.Result), nil
+}
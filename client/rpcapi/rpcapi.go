@@ -1,15 +1,19 @@
 package rpcapi
 
 // test it with:
-// curl --user someuser:somepass --data-binary '{"method":"Arith.Add","params":[{"A":7,"B":1}],"id":0}' -H 'content-type: text/plain;' http://127.0.0.1:8222/
+// curl --user Duodrpc:Duodpwd --data-binary '{"jsonrpc":"2.0","method":"getdifficulty","params":[],"id":0}' -H 'content-type: text/plain;' http://127.0.0.1:8332/
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
-	"os/exec"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/ParallelCoinTeam/duod/client/common"
 	"github.com/ParallelCoinTeam/duod/lib/L"
@@ -21,132 +25,544 @@ type RPCError struct {
 	Message string `json:"message"`
 }
 
+// Error codes (same as in bitcoind)
+const (
+	RPCErrMisc                   = -1
+	RPCErrInvalidAddressOrKey    = -5
+	RPCErrInvalidParameter       = -8
+	RPCErrDeserialization        = -22
+	RPCErrVerify                 = -25
+	RPCErrVerifyRejected         = -26
+	RPCErrVerifyAlreadyInChain   = -27
+	RPCErrClientNodeAlreadyAdded = -23
+	RPCErrClientNodeNotConnected = -29
+	RPCErrMethodNotFound         = -32601
+	RPCErrInvalidParams          = -32602
+	RPCErrInvalidRequest         = -32600
+	RPCErrInternal               = -32603
+	RPCErrParse                  = -32700
+)
+
+const (
+	// MaxRequestSize - bigger HTTP requests are refused
+	MaxRequestSize = 32 << 20
+	// MaxBatchSize - max number of commands in one batch request
+	MaxBatchSize = 1000
+)
+
 // RPCResponse -
 type RPCResponse struct {
-	ID     interface{} `json:"id"`
-	Result interface{} `json:"result"`
-	Error  interface{} `json:"error"`
+	ID      interface{} `json:"id"`
+	Result  interface{} `json:"result"`
+	Error   interface{} `json:"error"`
+	JSONRPC string      `json:"-"` // version of the request
+}
+
+// MarshalJSON - JSON-RPC 2.0 responses have either "result" or "error", 1.0 ones have both
+func (resp *RPCResponse) MarshalJSON() ([]byte, error) {
+	if resp.JSONRPC != "2.0" {
+		return json.Marshal(&struct {
+			Result interface{} `json:"result"`
+			Error  interface{} `json:"error"`
+			ID     interface{} `json:"id"`
+		}{resp.Result, resp.Error, resp.ID})
+	}
+	if resp.Error != nil {
+		return json.Marshal(&struct {
+			JSONRPC string      `json:"jsonrpc"`
+			Error   interface{} `json:"error"`
+			ID      interface{} `json:"id"`
+		}{"2.0", resp.Error, resp.ID})
+	}
+	return json.Marshal(&struct {
+		JSONRPC string      `json:"jsonrpc"`
+		Result  interface{} `json:"result"`
+		ID      interface{} `json:"id"`
+	}{"2.0", resp.Result, resp.ID})
 }
 
 // RPCCommand -
 type RPCCommand struct {
-	ID     interface{} `json:"id"`
-	Method string      `json:"method"`
-	Params interface{} `json:"params"`
+	JSONRPC string          `json:"jsonrpc"`
+	ID      interface{}     `json:"id"`
+	Method  string          `json:"method"`
+	Params  interface{}     `json:"params"`
+	raw     []byte          // the request as received (nil for internal calls)
+	ctx     context.Context // of the HTTP request (nil for internal calls)
+}
+
+// context - cancelled when the client goes away
+func (cmd *RPCCommand) context() context.Context {
+	if cmd.ctx != nil {
+		return cmd.ctx
+	}
+	return context.Background()
 }
 
-// processRPC -
-func processRPC(b []byte) (out []byte) {
-	ioutil.WriteFile("rpc_cmd.json", b, 0777)
-	exCmd := exec.Command("C:\\Tools\\DEV\\Git\\mingw64\\bin\\curl.EXE",
-		"--user", "Duodrpc:Duodpwd", "--data-binary", "@rpc_cmd.json", "http://127.0.0.1:18332/")
-	out, _ = exCmd.Output()
+// paramsArray - returns the command's params as an array (nil if not given or not an array)
+func (cmd *RPCCommand) paramsArray() []interface{} {
+	if arr, ok := cmd.Params.([]interface{}); ok {
+		return arr
+	}
+	return nil
+}
+
+// paramKeys - returns the keys of the object at the given index of the params
+// They come in the order of the request (with duplicates, if any) or sorted, if the request is not known.
+func (cmd *RPCCommand) paramKeys(idx int, obj map[string]interface{}) (keys []string) {
+	var req struct {
+		Params []json.RawMessage `json:"params"`
+	}
+	if cmd.raw != nil && json.NewDecoder(bytes.NewReader(cmd.raw)).Decode(&req) == nil && idx < len(req.Params) {
+		if keys = objectKeys(req.Params[idx]); keys != nil {
+			return
+		}
+	}
+	keys = make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
 	return
 }
 
-func myHandler(w http.ResponseWriter, r *http.Request) {
-	u, p, ok := r.BasicAuth()
-	if !ok {
-		L.Error("No HTTP Authentication data")
-		return
+// objectKeys - returns the keys of the JSON object in the order they appear (nil if it is not an object)
+func objectKeys(raw []byte) (keys []string) {
+	d := json.NewDecoder(bytes.NewReader(raw))
+	if t, er := d.Token(); er != nil || t != json.Delim('{') {
+		return nil
 	}
-	if u != common.CFG.RPC.Username {
-		L.Error("HTTP Authentication: bad username")
-		return
+	keys = []string{}
+	for d.More() {
+		t, er := d.Token()
+		if er != nil {
+			return nil
+		}
+		k, _ := t.(string)
+		keys = append(keys, k)
+		var v json.RawMessage
+		if d.Decode(&v) != nil {
+			return nil
+		}
 	}
-	if p != common.CFG.RPC.Password {
-		L.Error("HTTP Authentication: bad password")
-		return
+	return
+}
+
+// paramString - returns the string at the given index of the params
+func paramString(par []interface{}, idx int) (res string, ok bool) {
+	if idx < len(par) {
+		res, ok = par[idx].(string)
 	}
-	L.Debug("========================handler", r.Method, r.URL.String(), u, p, ok, "=================")
-	b, e := ioutil.ReadAll(r.Body)
-	if e != nil {
-		L.Error(e.Error())
-		return
+	return
+}
+
+// paramInt - returns the number at the given index of the params (or def if not given)
+func paramInt(par []interface{}, idx int, def int64) (int64, bool) {
+	if idx >= len(par) || par[idx] == nil {
+		return def, true
+	}
+	if n, ok := par[idx].(json.Number); ok {
+		if v, er := n.Int64(); er == nil {
+			return v, true
+		}
 	}
+	return 0, false
+}
 
-	var RPCCmd RPCCommand
-	jd := json.NewDecoder(bytes.NewReader(b))
-	jd.UseNumber()
-	e = jd.Decode(&RPCCmd)
-	if e != nil {
-		L.Error(e.Error())
+// paramBool - returns the boolean at the given index of the params (or def if not given)
+// Numbers are also accepted (0 is false).
+func paramBool(par []interface{}, idx int, def bool) (bool, bool) {
+	if idx >= len(par) || par[idx] == nil {
+		return def, true
+	}
+	switch v := par[idx].(type) {
+	case bool:
+		return v, true
+	case json.Number:
+		n, er := v.Int64()
+		return n != 0, er == nil
 	}
+	return false, false
+}
 
-	var resp RPCResponse
-	resp.ID = RPCCmd.ID
+// execCommand - executes a single command and sets the result (or the error) in resp
+func execCommand(RPCCmd *RPCCommand, resp *RPCResponse) {
 	switch RPCCmd.Method {
 	case "getblocktemplate":
-		var respMy RPCGetBlockTemplateResp
+		req := templateRequest(RPCCmd)
+		switch mode, _ := req["mode"].(string); mode {
+		case "", "template":
+			res := new(GetBlockTemplateResp)
+			GetNextBlockTemplate(res)
+			if requestCapability(req, "coinbasetxn") {
+				if er := AddCoinbaseTxn(res); er != nil && !requestCapability(req, "coinbasevalue") {
+					resp.Error = RPCError{Code: RPCErrMisc, Message: "Cannot build coinbase: " + er.Error()}
+					return
+				}
+			}
+			resp.Result = res
+		case "proposal":
+			ProposeBlock(req, resp)
+		default:
+			resp.Error = RPCError{Code: RPCErrInvalidParameter, Message: "Invalid mode"}
+		}
 
-		GetNextBlockTemplate(&respMy.Result)
+	case "validateaddress":
+		addr, ok := paramString(RPCCmd.paramsArray(), 0)
+		if !ok {
+			resp.Error = RPCError{Code: RPCErrInvalidParams, Message: "Address expected"}
+			return
+		}
+		resp.Result = ValidateAddress(addr)
 
-		if false {
-			var respOK RPCGetBlockTemplateResp
-			BitcoindResult := processRPC(b)
-			//ioutil.WriteFile("getblocktemplate_resp.json", BitcoindResult, 0777)
+	case "submitblock":
+		SubmitBlock(RPCCmd, resp)
 
-			//fmt.Print("getblocktemplate...", sto.Sub(sta).String(), string(b))
+	case "getblockchaininfo":
+		GetBlockchainInfo(RPCCmd, resp)
 
-			jd = json.NewDecoder(bytes.NewReader(BitcoindResult))
-			jd.UseNumber()
-			e = jd.Decode(&respOK)
+	case "getblockhash":
+		GetBlockHash(RPCCmd, resp)
 
-			if respMy.Result.PreviousBlockHash != respOK.Result.PreviousBlockHash {
-				L.Debug("satoshi @", respOK.Result.PreviousBlockHash, respOK.Result.Height)
-				L.Debug("Duod  @", respMy.Result.PreviousBlockHash, respMy.Result.Height)
-			} else {
-				L.Debug(".", len(respMy.Result.Transactions), respMy.Result.Coinbasevalue)
-				if respMy.Result.Mintime != respOK.Result.Mintime {
-					L.Debug("\007Mintime:", respMy.Result.Mintime, respOK.Result.Mintime)
-				}
-				if respMy.Result.Bits != respOK.Result.Bits {
-					L.Debug("\007Bits:", respMy.Result.Bits, respOK.Result.Bits)
-				}
-				if respMy.Result.Target != respOK.Result.Target {
-					L.Debug("\007Target:", respMy.Result.Target, respOK.Result.Target)
-				}
-			}
+	case "getblock":
+		GetBlock(RPCCmd, resp)
+
+	case "getblockheader":
+		GetBlockHeader(RPCCmd, resp)
+
+	case "getchaintips":
+		GetChainTips(RPCCmd, resp)
+
+	case "getrawtransaction":
+		GetRawTransaction(RPCCmd, resp)
+
+	case "decoderawtransaction":
+		DecodeRawTransaction(RPCCmd, resp)
+
+	case "sendrawtransaction":
+		SendRawTransaction(RPCCmd, resp)
+
+	case "createrawtransaction":
+		CreateRawTransaction(RPCCmd, resp)
+
+	case "getpeerinfo":
+		GetPeerInfo(RPCCmd, resp)
+
+	case "getnetworkinfo":
+		GetNetworkInfo(RPCCmd, resp)
+
+	case "addnode":
+		AddNode(RPCCmd, resp)
+
+	case "disconnectnode":
+		DisconnectNode(RPCCmd, resp)
+
+	case "getmempoolinfo":
+		GetMempoolInfo(RPCCmd, resp)
+
+	case "getrawmempool":
+		GetRawMempool(RPCCmd, resp)
+
+	case "getmempoolentry":
+		GetMempoolEntry(RPCCmd, resp)
+
+	case "getmininginfo":
+		GetMiningInfo(RPCCmd, resp)
+
+	case "getminerstats":
+		GetMinerStats(RPCCmd, resp)
+
+	case "getnetworkhashps":
+		GetNetworkHashPS(RPCCmd, resp)
+
+	case "getdifficulty":
+		GetDifficulty(RPCCmd, resp)
+
+	case "prioritisetransaction":
+		PrioritiseTransaction(RPCCmd, resp)
+
+	case "createauxblock":
+		CreateAuxBlock(RPCCmd, resp)
+
+	case "submitauxblock":
+		SubmitAuxBlock(RPCCmd, resp)
+
+	case "setgenerate":
+		SetGenerate(RPCCmd, resp)
+
+	case "getgenerate":
+		GetGenerate(RPCCmd, resp)
+
+	default:
+		resp.Error = RPCError{Code: RPCErrMethodNotFound, Message: "Method not found"}
+	}
+}
+
+// methodTimeouts - methods that may need more time than CFG.RPC.TimeoutSec
+var methodTimeouts = map[string]time.Duration{
+	"submitblock":        2 * time.Minute,
+	"submitauxblock":     2 * time.Minute,
+	"sendrawtransaction": time.Minute,
+	"getblock":           time.Minute,
+	"getrawtransaction":  time.Minute,
+	"getminerstats":      time.Minute,
+}
+
+// workers - limits the number of commands being executed at the same time
+var workers chan bool
+
+// methodTimeout -
+func methodTimeout(method string) time.Duration {
+	if to, ok := methodTimeouts[method]; ok {
+		return to
+	}
+	return time.Duration(common.GetUint32(&common.CFG.RPC.TimeoutSec)) * time.Second
+}
+
+// runCommand - executes the command in a worker, giving up after the method's timeout
+// The returned response is never shared with the worker, so it is safe to use even after a timeout.
+// A command that has timed out cannot be stopped: it keeps running and keeps its worker slot
+// until it finishes, so new commands wait for a free worker (and may time out there) meanwhile.
+func runCommand(cmd *RPCCommand) (resp *RPCResponse) {
+	resp = &RPCResponse{ID: cmd.ID, JSONRPC: cmd.JSONRPC}
+	if cmd.Method == "getblocktemplate" {
+		// long polling waits before taking a worker, so it does not block other requests
+		if id, ok := templateRequest(cmd)["longpollid"].(string); ok && !waitLongPoll(cmd.context(), id) {
+			resp.Error = RPCError{Code: RPCErrMisc, Message: "Request cancelled"}
+			return
 		}
+	}
+	timeout := time.NewTimer(methodTimeout(cmd.Method))
+	defer timeout.Stop()
 
-		b, _ = json.Marshal(&respMy)
-		//ioutil.WriteFile("json/"+RPCCmd.Method+"_resp_my.json", b, 0777)
-		w.Write(append(b, 0x0a))
+	select {
+	case workers <- true:
+	case <-timeout.C:
+		resp.Error = RPCError{Code: RPCErrMisc, Message: "Request timed out while waiting for a free worker"}
 		return
+	}
 
-	case "validateaddress":
-		switch uu := RPCCmd.Params.(type) {
-		case []interface{}:
-			if len(uu) == 1 {
-				resp.Result = ValidateAddress(uu[0].(string))
+	done := make(chan *RPCResponse, 1)
+	go func() {
+		res := new(RPCResponse)
+		defer func() {
+			if r := recover(); r != nil {
+				L.Error("RPC ", cmd.Method, " panic: ", r)
+				res.Result = nil
+				res.Error = RPCError{Code: RPCErrInternal, Message: fmt.Sprint("Internal error: ", r)}
 			}
-		default:
-			L.Debug("unexpected type", uu)
+			<-workers
+			done <- res
+		}()
+		execCommand(cmd, res)
+	}()
+
+	select {
+	case res := <-done:
+		resp.Result, resp.Error = res.Result, res.Error
+		if res.Error == nil && refNodeCompared(cmd.Method) {
+			queueRefNodeCompare(cmd, res.Result)
 		}
+	case <-timeout.C:
+		L.Debug("RPC ", cmd.Method, " timed out")
+		resp.Error = RPCError{Code: RPCErrMisc, Message: "Request timed out"}
+	}
+	return
+}
 
-	case "submitblock":
-		//ioutil.WriteFile("submitblock.json", b, 0777)
-		SubmitBlock(&RPCCmd, &resp, b)
+// decodeCommand - decodes a single request (also used to check the user's method whitelist)
+func decodeCommand(b []byte) (*RPCCommand, error) {
+	cmd := &RPCCommand{raw: b}
+	jd := json.NewDecoder(bytes.NewReader(b))
+	jd.UseNumber()
+	return cmd, jd.Decode(cmd)
+}
+
+// handleOne - decodes and executes a single request of the user
+// Returns nil for JSON-RPC 2.0 notifications (requests without an id), which get no response.
+func handleOne(ctx context.Context, user string, b []byte) *RPCResponse {
+	cmd, e := decodeCommand(b)
+	cmd.ctx = ctx
+	if e != nil {
+		var v interface{}
+		if json.Unmarshal(b, &v) == nil {
+			// valid JSON, but not a valid request object
+			return &RPCResponse{JSONRPC: "2.0", Error: RPCError{Code: RPCErrInvalidRequest, Message: "Invalid Request object"}}
+		}
+		return &RPCResponse{JSONRPC: "2.0", Error: RPCError{Code: RPCErrParse, Message: "Parse error"}}
+	}
 
+	var idCheck struct {
+		ID json.RawMessage `json:"id"`
+	}
+	json.Unmarshal(b, &idCheck)
+	notification := cmd.JSONRPC == "2.0" && len(idCheck.ID) == 0
+
+	if cmd.Method == "" {
+		return &RPCResponse{ID: cmd.ID, JSONRPC: cmd.JSONRPC, Error: RPCError{Code: RPCErrInvalidRequest, Message: "Method must be a string"}}
+	}
+	if !methodAllowed(user, cmd.Method) {
+		return &RPCResponse{ID: cmd.ID, JSONRPC: cmd.JSONRPC, Error: RPCError{Code: RPCErrInvalidRequest, Message: "Method not allowed"}}
+	}
+	switch cmd.Params.(type) {
+	case nil, []interface{}, map[string]interface{}:
 	default:
-		L.Debug("Method:", RPCCmd.Method, len(b))
-		//w.Write(BitcoindResult)
-		resp.Error = RPCError{Code: -32601, Message: "Method not found"}
+		return &RPCResponse{ID: cmd.ID, JSONRPC: cmd.JSONRPC, Error: RPCError{Code: RPCErrInvalidRequest, Message: "Params must be an array or object"}}
 	}
 
-	b, e = json.Marshal(&resp)
-	if e != nil {
-		L.Debug("json.Marshal(&resp):", e.Error())
+	resp := runCommand(cmd)
+	if notification {
+		return nil
+	}
+	return resp
+}
+
+// httpStatus - the HTTP status code of a (non batch) response, as in bitcoind
+// JSON-RPC 2.0 requests always get 200, the legacy ones get an error status if there was an error.
+func httpStatus(resp *RPCResponse) int {
+	if resp.JSONRPC == "2.0" || resp.Error == nil {
+		return http.StatusOK
 	}
+	if er, ok := resp.Error.(RPCError); ok {
+		switch er.Code {
+		case RPCErrInvalidRequest:
+			return http.StatusBadRequest
+		case RPCErrMethodNotFound:
+			return http.StatusNotFound
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// checkAuth - returns false (after sending 401) if the request is not authorized
+func checkAuth(w http.ResponseWriter, r *http.Request) (user string, ok bool) {
+	user, pass, ok := r.BasicAuth()
+	if !ok || !checkUser(user, pass) {
+		if ok {
+			L.Error("RPC: incorrect username or password from ", r.RemoteAddr)
+			time.Sleep(250 * time.Millisecond) // slow down brute-force attacks
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="jsonrpc"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return "", false
+	}
+	return user, true
+}
 
-	//ioutil.WriteFile(RPCCmd.Method+"_resp.json", b, 0777)
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	b, e := json.Marshal(v)
+	if e != nil {
+		L.Debug("json.Marshal(resp):", e.Error())
+		b, _ = json.Marshal(&RPCResponse{Error: RPCError{Code: RPCErrInternal, Message: e.Error()}})
+		status = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 	w.Write(append(b, 0x0a))
 }
 
+func myHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := checkAuth(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "JSONRPC server handles only POST requests", http.StatusMethodNotAllowed)
+		return
+	}
+
+	b, e := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MaxRequestSize))
+	if e != nil {
+		http.Error(w, e.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	b = bytes.TrimSpace(b)
+
+	if m, forbidden := forbiddenMethod(user, b); forbidden {
+		L.Debug("RPC user ", user, " not allowed to call ", m)
+		http.Error(w, "User "+user+" is not allowed to call method "+m, http.StatusForbidden)
+		return
+	}
+
+	if len(b) == 0 || b[0] != '[' {
+		resp := handleOne(r.Context(), user, b)
+		if resp == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJSON(w, httpStatus(resp), resp)
+		return
+	}
+
+	// batch request
+	var batch []json.RawMessage
+	if e = json.Unmarshal(b, &batch); e != nil {
+		writeJSON(w, http.StatusOK, &RPCResponse{JSONRPC: "2.0", Error: RPCError{Code: RPCErrParse, Message: "Parse error"}})
+		return
+	}
+	if len(batch) == 0 {
+		writeJSON(w, http.StatusOK, &RPCResponse{JSONRPC: "2.0", Error: RPCError{Code: RPCErrInvalidRequest, Message: "Empty batch"}})
+		return
+	}
+	if len(batch) > MaxBatchSize {
+		writeJSON(w, http.StatusOK, &RPCResponse{JSONRPC: "2.0", Error: RPCError{Code: RPCErrInvalidRequest, Message: "Batch too big"}})
+		return
+	}
+
+	res := make([]*RPCResponse, len(batch))
+	var wg sync.WaitGroup
+	for i := range batch {
+		wg.Add(1)
+		go func(i int) {
+			res[i] = handleOne(r.Context(), user, batch[i])
+			wg.Done()
+		}(i)
+	}
+	wg.Wait()
+
+	out := make([]*RPCResponse, 0, len(res))
+	for _, r := range res {
+		if r != nil {
+			out = append(out, r)
+		}
+	}
+	if len(out) == 0 {
+		w.WriteHeader(http.StatusNoContent) // batch of notifications only
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
 // StartServer -
 func StartServer(port uint32) {
-	L.Debug("Starting RPC server at port ", port)
+	common.LockCfg()
+	addr := net.JoinHostPort(common.CFG.RPC.Interface, fmt.Sprint(port))
+	useTLS := common.CFG.RPC.TLS
+	certFile, keyFile, clientCA := common.CFG.RPC.TLSCert, common.CFG.RPC.TLSKey, common.CFG.RPC.ClientCA
+	common.UnlockCfg()
+
+	L.Debug("Starting RPC server at ", addr)
+	threads := common.GetUint32(&common.CFG.RPC.Threads)
+	if threads == 0 {
+		threads = 1
+	}
+	workers = make(chan bool, threads)
+	if common.GetBool(&common.CFG.RPC.Cookie) {
+		writeCookie()
+	}
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", myHandler)
-	http.ListenAndServe(fmt.Sprint("127.0.0.1:", port), mux)
+	srv := &http.Server{Addr: addr, Handler: mux}
+
+	if useTLS {
+		cfg, er := common.TLSConfig(addr, certFile, keyFile, clientCA, clientCA != "")
+		if er != nil {
+			L.Error("RPC TLS: ", er.Error())
+			return
+		}
+		srv.TLSConfig = cfg
+		L.Error("RPC server: ", srv.ListenAndServeTLS("", "").Error())
+		return
+	}
+	L.Error("RPC server: ", srv.ListenAndServe().Error())
 }
@@ -0,0 +1,48 @@
+package stratum
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/ParallelCoinTeam/duod/client/common"
+	"github.com/ParallelCoinTeam/duod/client/rpcapi"
+)
+
+// Extranonce1Size - bytes of the coinbase's input script that we assign to each connection
+const Extranonce1Size = 4
+
+// job - work given to the miners
+type job struct {
+	*rpcapi.Job
+	shares map[string]bool // already submitted (to reject duplicates)
+}
+
+// newJob - makes a new job from the current block template
+func newJob(id string, payouts []common.Payout, tag string, en2size int) (*job, error) {
+	j, er := rpcapi.NewJob(id, &rpcapi.CoinbaseParams{Payouts: payouts, Tag: tag,
+		ExtranonceSize: Extranonce1Size + en2size})
+	if er != nil {
+		return nil, er
+	}
+	return &job{Job: j, shares: make(map[string]bool)}, nil
+}
+
+// swap32 - reverses order of bytes in each 4 bytes word (for the previous block hash in mining.notify)
+func swap32(b []byte) []byte {
+	res := make([]byte, len(b))
+	for i := 0; i+4 <= len(b); i += 4 {
+		res[i], res[i+1], res[i+2], res[i+3] = b[i+3], b[i+2], b[i+1], b[i]
+	}
+	return res
+}
+
+// notifyParams - params of mining.notify for this job
+func (j *job) notifyParams(clean bool) []interface{} {
+	branch := make([]string, len(j.Branch))
+	for i, h := range j.Branch {
+		branch[i] = hex.EncodeToString(h[:])
+	}
+	return []interface{}{j.ID, hex.EncodeToString(swap32(j.PrevHash)), hex.EncodeToString(j.Coinb1),
+		hex.EncodeToString(j.Coinb2), branch, fmt.Sprintf("%08x", j.Version), fmt.Sprintf("%08x", j.Bits),
+		fmt.Sprintf("%08x", j.Curtime), clean}
+}
@@ -0,0 +1,421 @@
+// Package stratum - Stratum v1 mining server
+package stratum
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ParallelCoinTeam/duod/client/common"
+	"github.com/ParallelCoinTeam/duod/client/rpcapi"
+	"github.com/ParallelCoinTeam/duod/lib/btc"
+	"github.com/ParallelCoinTeam/duod/lib/L"
+)
+
+const (
+	// MaxJobs - how many recent jobs we accept shares for
+	MaxJobs = 16
+	// MaxLineLength - longest message that a miner can send
+	MaxLineLength = 16 << 10
+	// SendQueueLen - how many messages can wait for a miner before it gets disconnected
+	SendQueueLen = 64
+)
+
+// Stratum error codes
+const (
+	ErrOther         = 20
+	ErrJobNotFound   = 21
+	ErrDuplicate     = 22
+	ErrLowDifficulty = 23
+	ErrUnauthorized  = 24
+	ErrNotSubscribed = 25
+)
+
+// diff1 - target of difficulty 1 shares
+var diff1 = new(big.Int).Lsh(big.NewInt(0xffff), 208)
+
+var (
+	jobsMutex sync.Mutex
+	jobs      []*job // the last one is the current
+	jobCnt    uint64
+
+	clientsMutex sync.Mutex
+	clients      = make(map[*client]bool)
+
+	en1Cnt  uint32
+	tipChan = make(chan bool, 1)
+)
+
+type request struct {
+	ID     interface{}   `json:"id"`
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+type client struct {
+	conn       net.Conn
+	sendq      chan []byte // messages are written in the order they were queued
+	done       chan bool   // closed when the connection is over
+	en1        []byte
+	subscribed bool
+	authorized bool
+	worker     string
+}
+
+// send - queues one JSON message for the writer (it does not block)
+func (c *client) send(v interface{}) {
+	b, er := json.Marshal(v)
+	if er != nil {
+		L.Error("Stratum: ", er.Error())
+		return
+	}
+	select {
+	case c.sendq <- append(b, '\n'):
+	default:
+		common.CountSafe("StratumSendQueueFull")
+		c.conn.Close() // too slow - serve() will clean up
+	}
+}
+
+// writer - writes the queued messages to the miner
+func (c *client) writer() {
+	for {
+		select {
+		case b := <-c.sendq:
+			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if _, er := c.conn.Write(b); er != nil {
+				c.conn.Close()
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *client) reply(id, result interface{}, code int, msg string) {
+	var er interface{}
+	if code != 0 {
+		er = []interface{}{code, msg, nil}
+	}
+	c.send(map[string]interface{}{"id": id, "result": result, "error": er})
+}
+
+func (c *client) notify(method string, params []interface{}) {
+	c.send(map[string]interface{}{"id": nil, "method": method, "params": params})
+}
+
+// shareTarget - returns the target of shares for the configured difficulty
+func shareTarget() (*big.Int, float64) {
+	common.LockCfg()
+	diff := common.CFG.Stratum.Difficulty
+	common.UnlockCfg()
+	if diff <= 0 {
+		diff = 1
+	}
+	t, _ := new(big.Float).Quo(new(big.Float).SetInt(diff1), big.NewFloat(diff)).Int(nil)
+	return t, diff
+}
+
+// NewTip - call it when the chain's tip changes (it does not block)
+func NewTip() {
+	select {
+	case tipChan <- true:
+	default:
+	}
+}
+
+// currentJob -
+func currentJob() (j *job) {
+	jobsMutex.Lock()
+	if len(jobs) > 0 {
+		j = jobs[len(jobs)-1]
+	}
+	jobsMutex.Unlock()
+	return
+}
+
+// findJob -
+func findJob(id string) *job {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+	for _, j := range jobs {
+		if j.ID == id {
+			return j
+		}
+	}
+	return nil
+}
+
+// updateJob - makes a new job and sends it to all the miners
+// If clean is false, the job is only sent if the block's fees increased enough.
+func updateJob(payouts []common.Payout, clean bool) {
+	common.LockCfg()
+	tag := common.CFG.Stratum.CoinbaseTag
+	en2size := int(common.CFG.Stratum.Extranonce2Size)
+	gain := common.CFG.Stratum.MinFeeGainPerc
+	common.UnlockCfg()
+
+	j, er := newJob(fmt.Sprintf("%x", atomic.AddUint64(&jobCnt, 1)), payouts, tag, en2size)
+	if er != nil {
+		L.Error("Stratum: ", er.Error())
+		return
+	}
+
+	jobsMutex.Lock()
+	if len(jobs) > 0 {
+		last := jobs[len(jobs)-1]
+		if !bytes.Equal(last.PrevHash, j.PrevHash) {
+			clean = true
+		}
+		if !clean && (j.Fees <= last.Fees || float64(j.Fees-last.Fees) < float64(last.Fees)*gain/100) {
+			jobsMutex.Unlock()
+			return
+		}
+	}
+	if clean {
+		jobs = nil
+	}
+	jobs = append(jobs, j)
+	if len(jobs) > MaxJobs {
+		jobs = jobs[len(jobs)-MaxJobs:]
+	}
+	jobsMutex.Unlock()
+	common.CountSafe("StratumNewJob")
+
+	params := j.notifyParams(clean)
+	clientsMutex.Lock()
+	for c := range clients {
+		if c.authorized {
+			c.notify("mining.notify", params)
+		}
+	}
+	clientsMutex.Unlock()
+}
+
+// jobMaker - makes new jobs on every new tip and when the memory pool changes enough
+func jobMaker(payouts []common.Payout) {
+	updateJob(payouts, true)
+	for {
+		refresh := time.Duration(common.GetUint32(&common.CFG.Stratum.RefreshSec)) * time.Second
+		if refresh < time.Second {
+			refresh = time.Second
+		}
+		select {
+		case <-tipChan:
+			updateJob(payouts, true)
+		case <-time.After(refresh):
+			updateJob(payouts, false)
+		}
+	}
+}
+
+// submit - handles mining.submit ([worker, job_id, extranonce2, ntime, nonce])
+func (c *client) submit(req *request) (code int, msg string) {
+	if !c.authorized {
+		return ErrUnauthorized, "Unauthorized worker"
+	}
+	var p [5]string
+	for i := range p {
+		if i >= len(req.Params) {
+			return ErrOther, "Missing params"
+		}
+		p[i], _ = req.Params[i].(string)
+	}
+	j := findJob(p[1])
+	if j == nil {
+		common.CountSafe("StratumShareStale")
+		return ErrJobNotFound, "Job not found"
+	}
+	en2, er := hex.DecodeString(p[2])
+	if er != nil || len(en2) != int(common.GetUint32(&common.CFG.Stratum.Extranonce2Size)) {
+		return ErrOther, "Bad extranonce2"
+	}
+	var ntime, nonce uint32
+	if len(p[3]) != 8 || len(p[4]) != 8 {
+		return ErrOther, "Bad ntime or nonce"
+	}
+	if _, er = fmt.Sscanf(p[3]+p[4], "%08x%08x", &ntime, &nonce); er != nil {
+		return ErrOther, "Bad ntime or nonce"
+	}
+	if ntime < j.Mintime || int64(ntime) > time.Now().Unix()+7200 {
+		return ErrOther, "Time out of range"
+	}
+
+	key := p[2] + p[3] + p[4] + hex.EncodeToString(c.en1)
+	jobsMutex.Lock()
+	dup := j.shares[key]
+	j.shares[key] = true
+	jobsMutex.Unlock()
+	if dup {
+		common.CountSafe("StratumShareDup")
+		return ErrDuplicate, "Duplicate share"
+	}
+
+	cb := j.Coinbase(c.en1, en2)
+	hdr := j.Header(cb, ntime, nonce)
+	hash := btc.NewSha2Hash(hdr).BigInt()
+	target, _ := shareTarget()
+	if hash.Cmp(j.Target) <= 0 {
+		L.Info("Stratum: block ", j.Height, " found by ", c.worker)
+		res, er := rpcapi.SubmitRawBlock(j.Block(hdr, cb))
+		if er != nil {
+			res = er.Error()
+		}
+		if res != "" {
+			L.Error("Stratum: block rejected: ", res)
+			common.CountSafe("StratumBlockRejected")
+			return ErrOther, "Block rejected: " + res
+		}
+		common.CountSafe("StratumBlockOK")
+		NewTip()
+	} else if hash.Cmp(target) > 0 {
+		common.CountSafe("StratumShareLow")
+		return ErrLowDifficulty, "Low difficulty share"
+	}
+	common.CountSafe("StratumShareOK")
+	return
+}
+
+func (c *client) handle(req *request) {
+	switch req.Method {
+	case "mining.subscribe":
+		c.subscribed = true
+		id := hex.EncodeToString(c.en1)
+		c.reply(req.ID, []interface{}{
+			[][]string{{"mining.set_difficulty", id}, {"mining.notify", id}},
+			id, common.GetUint32(&common.CFG.Stratum.Extranonce2Size)}, 0, "")
+
+	case "mining.authorize":
+		if !c.subscribed {
+			c.reply(req.ID, nil, ErrNotSubscribed, "Not subscribed")
+			return
+		}
+		var user, pass string
+		if len(req.Params) > 0 {
+			user, _ = req.Params[0].(string)
+		}
+		if len(req.Params) > 1 {
+			pass, _ = req.Params[1].(string)
+		}
+		common.LockCfg()
+		ok := common.CFG.Stratum.Password == "" ||
+			subtle.ConstantTimeCompare([]byte(pass), []byte(common.CFG.Stratum.Password)) == 1
+		common.UnlockCfg()
+		if !ok {
+			L.Error("Stratum: incorrect password from ", c.conn.RemoteAddr().String())
+			c.reply(req.ID, false, ErrUnauthorized, "Unauthorized worker")
+			return
+		}
+		c.worker = user
+		c.reply(req.ID, true, 0, "")
+
+		_, diff := shareTarget()
+		// queue the current job with clientsMutex locked, so a newer one from updateJob() cannot go first
+		clientsMutex.Lock()
+		c.authorized = true
+		c.notify("mining.set_difficulty", []interface{}{diff})
+		if j := currentJob(); j != nil {
+			c.notify("mining.notify", j.notifyParams(true))
+		}
+		clientsMutex.Unlock()
+
+	case "mining.submit":
+		if code, msg := c.submit(req); code != 0 {
+			c.reply(req.ID, false, code, msg)
+		} else {
+			c.reply(req.ID, true, 0, "")
+		}
+
+	case "mining.extranonce.subscribe":
+		c.reply(req.ID, false, 0, "") // we never change the extranonce
+
+	default:
+		c.reply(req.ID, nil, ErrOther, "Method not found")
+	}
+}
+
+func serve(conn net.Conn) {
+	c := &client{conn: conn, sendq: make(chan []byte, SendQueueLen), done: make(chan bool),
+		en1: make([]byte, Extranonce1Size)}
+	binary.BigEndian.PutUint32(c.en1, atomic.AddUint32(&en1Cnt, 1))
+	clientsMutex.Lock()
+	clients[c] = true
+	clientsMutex.Unlock()
+	go c.writer()
+
+	sc := bufio.NewScanner(conn)
+	sc.Buffer(make([]byte, 1024), MaxLineLength)
+	for {
+		conn.SetReadDeadline(time.Now().Add(10 * time.Minute))
+		if !sc.Scan() {
+			break
+		}
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var req request
+		if er := json.Unmarshal([]byte(line), &req); er != nil {
+			break
+		}
+		c.handle(&req)
+	}
+
+	clientsMutex.Lock()
+	delete(clients, c)
+	clientsMutex.Unlock()
+	close(c.done)
+	conn.Close()
+}
+
+// StartServer - runs the Stratum server (it does not return unless the server fails)
+func StartServer() {
+	common.LockCfg()
+	iface := common.CFG.Stratum.Interface
+	addr := common.CFG.Stratum.PayoutAddress
+	common.UnlockCfg()
+
+	var payouts []common.Payout
+	var er error
+	if addr != "" {
+		payouts, er = common.ParsePayouts(addr, nil)
+	} else {
+		payouts, er = common.MiningPayouts()
+	}
+	if er != nil {
+		L.Error("Stratum: payout: ", er.Error())
+		return
+	}
+
+	var b [4]byte
+	rand.Read(b[:])
+	en1Cnt = binary.BigEndian.Uint32(b[:]) // so the extranonces differ after restart
+
+	lis, er := net.Listen("tcp", iface)
+	if er != nil {
+		L.Error("Stratum server: ", er.Error())
+		return
+	}
+	L.Debug("Starting Stratum server at ", iface)
+	go jobMaker(payouts)
+	for {
+		conn, er := lis.Accept()
+		if er != nil {
+			L.Error("Stratum server: ", er.Error())
+			return
+		}
+		go serve(conn)
+	}
+}
@@ -0,0 +1,334 @@
+package usif
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/gob"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ParallelCoinTeam/duod/client/common"
+	"github.com/ParallelCoinTeam/duod/lib/btc"
+	"github.com/ParallelCoinTeam/duod/lib/chain"
+	"github.com/ParallelCoinTeam/duod/lib/L"
+)
+
+const (
+	// MinersIdxFileName -
+	MinersIdxFileName = "minersidx.gob"
+)
+
+// BlockMinerRec - who mined the block (a record of the miners index)
+type BlockMinerRec struct {
+	Height   uint32
+	Time     uint32
+	Version  uint32
+	Bits     uint32
+	Size     uint32
+	Fees     uint64
+	Miner    string // name from miners.json or the payout address
+	Known    bool   // the name is from miners.json
+	ListID   uint32 // common.MinersListID() at the time the miner was identified
+	Orphaned bool   // disconnected from the chain
+}
+
+var (
+	// MinersIdxMutex -
+	MinersIdxMutex sync.Mutex
+	// MinersIdx - by the block's hash
+	MinersIdx = make(map[[32]byte]*BlockMinerRec)
+)
+
+// BlockAlgo - returns the name of the proof of work algorithm of the block's version
+func BlockAlgo(ver uint32) string {
+	if ver&btc.AuxPowVersion != 0 {
+		return "sha256d-aux" // merge-mined
+	}
+	return "sha256d"
+}
+
+// minersIdxStart - records older than this are not kept in the index
+func minersIdxStart() uint32 {
+	common.LockCfg()
+	days := common.CFG.Stat.MinersDays
+	common.UnlockCfg()
+	return uint32(time.Now().Unix()) - uint32(days)*24*3600
+}
+
+// newMinerRec - identifies the miner of the block
+func newMinerRec(bl *btc.Block, height uint32) (rec *BlockMinerRec) {
+	cbtx, _ := btc.NewTx(bl.Raw[bl.TxOffset:])
+	if cbtx == nil {
+		return
+	}
+	rec = &BlockMinerRec{Height: height, Time: bl.BlockTime(), Version: bl.Version(), Bits: bl.Bits(),
+		Size: uint32(len(bl.Raw)), ListID: common.MinersListID()}
+	var rew uint64
+	for _, o := range cbtx.TxOut {
+		rew += o.Value
+	}
+	if r := btc.GetBlockReward(height); rew > r {
+		rec.Fees = rew - r
+	}
+	var idx int
+	rec.Miner, idx = common.TxMiner(cbtx)
+	rec.Known = idx >= 0
+	return
+}
+
+// IndexBlockMiner - adds the connected block to the miners index
+func IndexBlockMiner(bl *btc.Block) {
+	if bl.BlockTime() < minersIdxStart() {
+		return // it will be read from the block if needed
+	}
+	if rec := newMinerRec(bl, bl.Height); rec != nil {
+		MinersIdxMutex.Lock()
+		MinersIdx[bl.Hash.Hash] = rec
+		MinersIdxMutex.Unlock()
+	}
+}
+
+// BlockMinerUndone - marks the block, disconnected from the chain, as orphaned
+func BlockMinerUndone(bl *btc.Block) {
+	MinersIdxMutex.Lock()
+	defer MinersIdxMutex.Unlock()
+	rec := MinersIdx[bl.Hash.Hash]
+	if rec == nil {
+		if rec = newMinerRec(bl, bl.Height); rec == nil {
+			return
+		}
+		MinersIdx[bl.Hash.Hash] = rec
+	}
+	rec.Orphaned = true
+}
+
+// GetBlockMiner - returns the index record of the block, reading the block if it is not there (nil on error)
+func GetBlockMiner(hash *btc.Uint256, height uint32) *BlockMinerRec {
+	MinersIdxMutex.Lock()
+	rec := MinersIdx[hash.Hash]
+	MinersIdxMutex.Unlock()
+	if rec != nil && rec.ListID == common.MinersListID() {
+		return rec
+	}
+
+	raw, _, er := common.BlockChain.Blocks.BlockGet(hash)
+	if er != nil {
+		return rec
+	}
+	bl, er := btc.NewBlock(raw)
+	if er != nil {
+		return rec
+	}
+	nrec := newMinerRec(bl, height)
+	if nrec == nil {
+		return rec
+	}
+	if rec != nil {
+		nrec.Orphaned = rec.Orphaned
+	}
+	if rec != nil || nrec.Time >= minersIdxStart() {
+		MinersIdxMutex.Lock()
+		MinersIdx[hash.Hash] = nrec
+		MinersIdxMutex.Unlock()
+	}
+	return nrec
+}
+
+// ExpireMinersIdx - removes old records from the miners index
+func ExpireMinersIdx() {
+	start := minersIdxStart()
+	MinersIdxMutex.Lock()
+	for k, rec := range MinersIdx {
+		if rec.Time < start {
+			delete(MinersIdx, k)
+		}
+	}
+	MinersIdxMutex.Unlock()
+}
+
+// SaveMinersIdx -
+func SaveMinersIdx() {
+	f, er := os.Create(common.DuodHomeDir + MinersIdxFileName)
+	if er != nil {
+		L.Error("SaveMinersIdx:", er.Error())
+		return
+	}
+
+	ExpireMinersIdx()
+	buf := bufio.NewWriter(f)
+	MinersIdxMutex.Lock()
+	er = gob.NewEncoder(buf).Encode(MinersIdx)
+	MinersIdxMutex.Unlock()
+	if er != nil {
+		L.Error("SaveMinersIdx:", er.Error())
+	}
+
+	buf.Flush()
+	f.Close()
+}
+
+// LoadMinersIdx -
+func LoadMinersIdx() {
+	f, er := os.Open(common.DuodHomeDir + MinersIdxFileName)
+	if er != nil {
+		println("LoadMinersIdx:", er.Error())
+		return
+	}
+
+	buf := bufio.NewReader(f)
+	MinersIdxMutex.Lock()
+	er = gob.NewDecoder(buf).Decode(&MinersIdx)
+	MinersIdxMutex.Unlock()
+	if er != nil {
+		println("LoadMinersIdx:", er.Error())
+	}
+
+	f.Close()
+}
+
+// MinerStat - one miner's blocks in a time window
+type MinerStat struct {
+	Name       string  `json:"name"`
+	Unknown    bool    `json:"unknown"`    // not in miners.json
+	Blocks     int     `json:"blocks"`     // in the chain
+	Share      float64 `json:"share"`      // of the chain's blocks
+	Orphans    int     `json:"orphans"`    // blocks disconnected from the chain
+	OrphanRate float64 `json:"orphanrate"` // orphans / all the blocks
+	Hashrate   float64 `json:"hashespersec"`
+	Fees       uint64  `json:"fees"`
+	Bytes      uint64  `json:"bytes"`
+}
+
+// AlgoStat - blocks of one proof of work algorithm in a time window
+type AlgoStat struct {
+	Algo          string  `json:"algo"`
+	Blocks        int     `json:"blocks"`
+	AvgDifficulty float64 `json:"avgdifficulty"`
+	Hashrate      float64 `json:"hashespersec"`
+}
+
+// MiningStats - statistics of the blocks mined between From and To
+type MiningStats struct {
+	From           uint32       `json:"from"`
+	To             uint32       `json:"to"`
+	Blocks         int          `json:"blocks"`
+	Orphans        int          `json:"orphans"`
+	FirstBlockTime uint32       `json:"firstblocktime"`
+	AvgDifficulty  float64      `json:"avgdifficulty"`
+	Hashrate       float64      `json:"hashespersec"`
+	Miners         []*MinerStat `json:"miners"`
+	Algos          []*AlgoStat  `json:"algos"`
+}
+
+// GetMiningStats - returns the statistics for the time window (zero to means now)
+// The window starts at most CFG.Stat.MinersDays ago, as older blocks are not in the miners index.
+// Orphans are only known if the node has seen them.
+func GetMiningStats(from, to uint32) (res *MiningStats) {
+	now := uint32(time.Now().Unix())
+	if to == 0 || to > now {
+		to = now
+	}
+	if start := minersIdxStart(); from < start {
+		from = start
+	}
+	res = &MiningStats{From: from, To: to}
+	secs := float64(to - from)
+	if to <= from {
+		secs = 1
+	}
+
+	miners := make(map[string]*MinerStat)
+	getMiner := func(rec *BlockMinerRec) *MinerStat {
+		name, unknown := "unknown", true
+		if rec != nil {
+			name, unknown = rec.Miner, !rec.Known
+		}
+		m := miners[name]
+		if m == nil {
+			m = &MinerStat{Name: name, Unknown: unknown}
+			miners[name] = m
+		}
+		return m
+	}
+	algos := make(map[string]*AlgoStat)
+	work := make(map[*MinerStat]float64)
+
+	common.Last.Mutex.Lock()
+	end := common.Last.Block
+	common.Last.Mutex.Unlock()
+
+	var nodes []*chain.BlockTreeNode
+	common.BlockChain.BlockIndexAccess.Lock()
+	for ; end != nil && end.Timestamp() >= from; end = end.Parent {
+		if end.Timestamp() <= to {
+			nodes = append(nodes, end)
+		}
+	}
+	common.BlockChain.BlockIndexAccess.Unlock()
+
+	var diff float64
+	for _, end := range nodes {
+		d := btc.GetDifficulty(end.Bits())
+		algo := BlockAlgo(binary.LittleEndian.Uint32(end.BlockHeader[0:4]))
+		a := algos[algo]
+		if a == nil {
+			a = &AlgoStat{Algo: algo}
+			algos[algo] = a
+		}
+		a.Blocks++
+		a.AvgDifficulty += d
+		diff += d
+		res.Blocks++
+		res.FirstBlockTime = end.Timestamp()
+
+		rec := GetBlockMiner(end.BlockHash, end.Height)
+		m := getMiner(rec)
+		m.Blocks++
+		work[m] += d
+		if rec != nil {
+			m.Fees += rec.Fees
+			m.Bytes += uint64(rec.Size)
+		}
+	}
+
+	orphans := make(map[[32]byte]uint32) // hash -> height
+	MinersIdxMutex.Lock()
+	for k, rec := range MinersIdx {
+		if rec.Orphaned && rec.Time >= from && rec.Time <= to {
+			orphans[k] = rec.Height
+		}
+	}
+	MinersIdxMutex.Unlock()
+	for k, height := range orphans {
+		getMiner(GetBlockMiner(btc.NewUint256(k[:]), height)).Orphans++
+		res.Orphans++
+	}
+
+	if res.Blocks > 0 {
+		res.AvgDifficulty = diff / float64(res.Blocks)
+	}
+	res.Hashrate = diff * btc.HashesPerDifficulty / secs
+	for _, m := range miners {
+		if res.Blocks > 0 {
+			m.Share = float64(m.Blocks) / float64(res.Blocks)
+		}
+		m.OrphanRate = float64(m.Orphans) / float64(m.Blocks+m.Orphans)
+		m.Hashrate = work[m] * btc.HashesPerDifficulty / secs
+		res.Miners = append(res.Miners, m)
+	}
+	sort.Slice(res.Miners, func(i, j int) bool {
+		if res.Miners[i].Blocks == res.Miners[j].Blocks {
+			return res.Miners[i].Name < res.Miners[j].Name
+		}
+		return res.Miners[i].Blocks > res.Miners[j].Blocks
+	})
+	for _, a := range algos {
+		a.Hashrate = a.AvgDifficulty * btc.HashesPerDifficulty / secs
+		a.AvgDifficulty /= float64(a.Blocks)
+		res.Algos = append(res.Algos, a)
+	}
+	sort.Slice(res.Algos, func(i, j int) bool { return res.Algos[i].Algo < res.Algos[j].Algo })
+	return
+}
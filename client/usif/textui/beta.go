@@ -2,13 +2,62 @@ package textui
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/ParallelCoinTeam/duod/client/common"
 	"github.com/ParallelCoinTeam/duod/client/network"
+	"github.com/ParallelCoinTeam/duod/client/rpcapi"
 	"github.com/ParallelCoinTeam/duod/lib/btc"
 )
 
 func newBlock(par string) {
+	var payouts []common.Payout
+	var er error
+	if par = strings.TrimSpace(par); par != "" {
+		payouts, er = common.ParsePayouts(par, nil)
+	} else {
+		payouts, er = common.MiningPayouts()
+	}
+	if er != nil {
+		fmt.Println("Cannot build the coinbase:", er.Error())
+		return
+	}
+	common.LockCfg()
+	cp := &rpcapi.CoinbaseParams{Payouts: payouts, Tag: common.CFG.Mining.CoinbaseTag,
+		ExtranonceSize: int(common.CFG.Mining.ExtranonceSize)}
+	common.UnlockCfg()
+
+	sta := time.Now()
+	j, er := rpcapi.NewJob("", cp)
+	if er != nil {
+		fmt.Println("Cannot build the block:", er.Error())
+		return
+	}
+	cb := j.Coinbase(make([]byte, cp.ExtranonceSize), nil)
+	raw := j.Block(j.Header(cb, j.Curtime, 0), cb)
+	fmt.Println("Block", j.Height, "on top of", btc.NewUint256(j.PrevHash).String(), "built in",
+		time.Now().Sub(sta).String())
+	fmt.Println(len(j.Txs)+1, "transactions,", len(raw), "bytes,", btc.UintToBtc(j.Fees), "BTC in fees")
+
+	cbtx, _ := btc.NewTx(j.CoinbaseTx(cb))
+	fmt.Println("Coinbase", btc.NewSha2Hash(cb).String(), "with input script", fmt.Sprintf("%x", cbtx.TxIn[0].ScriptSig))
+	for _, o := range cbtx.TxOut {
+		if ad := btc.NewAddrFromPkScript(o.PkScript, common.Testnet); ad != nil {
+			fmt.Println(" ", btc.UintToBtc(o.Value), "BTC to", ad.String())
+		} else {
+			fmt.Println(" ", btc.UintToBtc(o.Value), "BTC to", fmt.Sprintf("%x", o.PkScript))
+		}
+	}
+	miner, idx := common.TxMiner(cbtx)
+	if idx < 0 {
+		fmt.Println("The tag is not in miners.json - the block would be attributed to", miner)
+	} else {
+		fmt.Println("Recognized as mined by", miner)
+	}
+}
+
+func compareSorting(par string) {
 	sta := time.Now()
 	txs := network.GetSortedMempool()
 	println(len(txs), "txs got in", time.Now().Sub(sta).String())
@@ -73,6 +122,7 @@ func getTxChildren(par string) {
 }
 
 func init() {
-	newUI("newblock nb", true, newBlock, "build a new block")
+	newUI("newblock nb", true, newBlock, "build a new block with our coinbase (optionally specify the payout address)")
+	newUI("txsort", true, compareSorting, "compare the old and new sorting of the memory pool")
 	newUI("txchild ch", true, getTxChildren, "show all the children fo the given tx")
 }
@@ -2,6 +2,8 @@ package textui
 
 import (
 	"bufio"
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -16,10 +18,12 @@ import (
 	"github.com/ParallelCoinTeam/duod"
 	"github.com/ParallelCoinTeam/duod/client/common"
 	"github.com/ParallelCoinTeam/duod/client/network"
+	"github.com/ParallelCoinTeam/duod/client/rpcapi"
 	"github.com/ParallelCoinTeam/duod/client/usif"
 	"github.com/ParallelCoinTeam/duod/lib/btc"
 	"github.com/ParallelCoinTeam/duod/lib/others/peersdb"
 	"github.com/ParallelCoinTeam/duod/lib/others/qdb"
+	"github.com/ParallelCoinTeam/duod/lib/others/refnode"
 	"github.com/ParallelCoinTeam/duod/lib/others/sys"
 	"github.com/ParallelCoinTeam/duod/lib/utxo"
 )
@@ -554,6 +558,94 @@ func purgeUXTO(par string) {
 	common.BlockChain.Unspent.PurgeUnspendable(par == "all")
 }
 
+func rpcAuth(par string) {
+	ss := strings.Fields(par)
+	if len(ss) == 0 {
+		fmt.Println("Specify the username (and optionally the password)")
+		return
+	}
+	var pass string
+	if len(ss) > 1 {
+		pass = ss[1]
+	} else {
+		var rnd [24]byte
+		rand.Read(rnd[:])
+		pass = base64.URLEncoding.EncodeToString(rnd[:])
+	}
+	fmt.Println("Add this record to RPC.Auth in the config file:")
+	fmt.Println(" ", rpcapi.NewRPCAuth(ss[0], pass))
+	fmt.Println("Password for", ss[0]+":", pass)
+}
+
+func refNode(par string) {
+	cmp := rpcapi.RefNode()
+	if cmp == nil {
+		fmt.Println("Reference node not configured - set RPC.RefNode.URL")
+		return
+	}
+
+	ss := strings.SplitN(strings.TrimSpace(par), " ", 2)
+	switch ss[0] {
+	case "", "all":
+		st := cmp.Stats()
+		methods := make([]string, 0, len(st))
+		for m := range st {
+			methods = append(methods, m)
+		}
+		sort.Strings(methods)
+		fmt.Println("Comparing with", cmp.URL)
+		for _, m := range methods {
+			fmt.Printf(" %-20s compared:%-6d mismatched:%-6d errors:%d\n", m, st[m].Compared, st[m].Mismatched, st[m].Errors)
+		}
+		hist := cmp.History()
+		if ss[0] == "" && len(hist) > 10 {
+			hist = hist[len(hist)-10:]
+		}
+		for _, r := range hist {
+			printRefNodeResult(r)
+		}
+		if len(hist) == 0 {
+			fmt.Println("No mismatches recorded")
+		}
+
+	case "clear":
+		cmp.Reset()
+		fmt.Println("Reference node history cleared")
+
+	default:
+		var params []interface{}
+		if len(ss) > 1 {
+			jd := json.NewDecoder(strings.NewReader(ss[1]))
+			jd.UseNumber()
+			if er := jd.Decode(&params); er != nil {
+				fmt.Println("Params must be a JSON array:", er.Error())
+				return
+			}
+		}
+		r, er := rpcapi.CompareNow(ss[0], params)
+		if er != nil {
+			fmt.Println(er.Error())
+			return
+		}
+		if r.Error == "" && len(r.Diffs) == 0 {
+			fmt.Println("Results match")
+			return
+		}
+		printRefNodeResult(r)
+	}
+}
+
+func printRefNodeResult(r *refnode.Result) {
+	pars, _ := json.Marshal(r.Params)
+	fmt.Println(r.Time.Format("15:04:05"), r.Method, string(pars))
+	if r.Error != "" {
+		fmt.Println("   error:", r.Error)
+	}
+	for _, d := range r.Diffs {
+		fmt.Println("  ", d.String())
+	}
+}
+
 func init() {
 	newUI("bchain b", true, blockchainStats, "Display blockchain statistics")
 	newUI("bip9", true, analyzeBIP9, "Analyze current blockchain for BIP9 bits (add 'all' to see more)")
@@ -571,6 +663,8 @@ func init() {
 	newUI("pend", false, showPending, "Show pending blocks, to be fetched")
 	newUI("purge", true, purgeUXTO, "Purge unspendable outputs from UTXO database (add 'all' to purge everything)")
 	newUI("quit q", false, uiQuit, "Quit the node")
+	newUI("refnode ref", false, refNode, "Show differences with the reference node (all, clear or <method> [json_params] to compare now)")
+	newUI("rpcauth", false, rpcAuth, "Make RPC.Auth record for the given user (and password - random if not given)")
 	newUI("savebl", false, dumpBlock, "Saves a block with a given hash to a binary file")
 	newUI("saveutxo s", true, saveUXTO, "Save UTXO database now")
 	newUI("trust t", true, switchTrust, "Assume all donwloaded blocks trusted (1) or un-trusted (0)")
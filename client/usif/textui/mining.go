@@ -3,10 +3,13 @@ package textui
 import (
 	"fmt"
 	"regexp"
+	"runtime"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ParallelCoinTeam/duod/client/common"
+	"github.com/ParallelCoinTeam/duod/client/miner"
 	"github.com/ParallelCoinTeam/duod/lib/btc"
 )
 
@@ -94,6 +97,45 @@ func doMining(s string) {
 	}
 }
 
+func genBlocks(par string) {
+	ss := strings.Fields(par)
+	if len(ss) == 0 {
+		st := miner.GetStatus()
+		if !st.Generate {
+			fmt.Println("CPU miner is off. Blocks found:", st.Found)
+			return
+		}
+		fmt.Println("CPU miner is on with", st.Threads, "thread(s), paying to", st.Address)
+		fmt.Println("Hashrate:", common.HashrateToString(st.Hashrate), "  Blocks found:", st.Found,
+			"  Running for", time.Duration(st.Uptime)*time.Second)
+		return
+	}
+
+	switch ss[0] {
+	case "off":
+		miner.Stop()
+	case "on":
+		threads := runtime.NumCPU()
+		var addr string
+		var force bool
+		for _, s := range ss[1:] {
+			if n, er := strconv.ParseUint(s, 10, 32); er == nil {
+				threads = int(n)
+			} else if s == "force" {
+				force = true
+			} else {
+				addr = s
+			}
+		}
+		if er := miner.Start(threads, addr, force); er != nil {
+			fmt.Println("Cannot start CPU miner:", er.Error())
+		}
+	default:
+		fmt.Println("Specify on or off")
+	}
+}
+
 func init() {
+	newUI("gen", false, genBlocks, "CPU mining for development networks: on [threads] [address] [force] or off")
 	newUI("minerstat m", false, doMining, "Look for the miner ID in recent blocks (optionally specify number of hours)")
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ParallelCoinTeam/duod/client/common"
@@ -90,7 +91,17 @@ func nodeInfo(par string) {
 		fmt.Print("BlockInProgress:", r.BlocksInProgress, "  GetHeadersInProgress:", r.GetHeadersInProgress, "\n")
 		fmt.Println("GetBlocksDataNow:", r.GetBlocksDataNow)
 		fmt.Println("AllHeadersReceived:", r.AllHeadersReceived)
+		if r.HdrsPresync != "" {
+			fmt.Println("Headers pre-sync:", r.HdrsPresync, " at", r.HdrsPresyncHeight, " using", r.HdrsPresyncMem, "bytes")
+		}
 		fmt.Println("Total Received:", r.BytesReceived, " /  Sent:", r.BytesSent)
+		fmt.Println("Authorized:", r.Authorized, " /  Permissions:", r.PermissionList)
+		if r.BlockRelayOnly {
+			fmt.Println("Block-relay-only connection")
+		}
+		if r.CaptureFile != "" {
+			fmt.Println("Capturing to:", r.CaptureFile)
+		}
 		for k, v := range r.Counters {
 			fmt.Println(k, ":", v)
 		}
@@ -120,7 +131,8 @@ func netStats(par string) {
 	}
 
 	network.MutexNet.Lock()
-	fmt.Printf("%d active net connections, %d outgoing\n", len(network.OpenCons), network.OutConsActive)
+	fmt.Printf("%d active net connections, %d outgoing, %d block-relay-only\n", len(network.OpenCons),
+		network.OutConsActive, network.BlockRelayConsActive)
 	srt := make(SortedKeys, len(network.OpenCons))
 	cnt := 0
 	for k, v := range network.OpenCons {
@@ -136,6 +148,8 @@ func netStats(par string) {
 
 		if v.X.Incomming {
 			fmt.Print("<- ")
+		} else if v.X.BlockRelayOnly {
+			fmt.Print("B->")
 		} else {
 			fmt.Print(" ->")
 		}
@@ -174,12 +188,66 @@ func netStats(par string) {
 	fmt.Println()
 
 	fmt.Println("GetMPInProgress:", len(network.GetMPInProgressTicket) != 0)
+	if w := network.StaleTipWarning(); w != "" {
+		fmt.Println("WARNING:", w)
+	}
 
 	common.PrintBWStats()
 }
 
+func netCapture(par string) {
+	ss := strings.SplitN(strings.TrimSpace(par), " ", 2)
+	conid, e := strconv.ParseUint(ss[0], 10, 32)
+	if e != nil {
+		fmt.Println("Specify connection ID, optionally followed by a file name or \"off\"")
+		return
+	}
+	var fn string
+	if len(ss) > 1 {
+		fn = strings.TrimSpace(ss[1])
+	}
+	stop := fn == "off"
+	if stop {
+		fn = ""
+	}
+	fn, e = network.CaptureConn(uint32(conid), fn, stop)
+	if e != nil {
+		fmt.Println(e.Error())
+	} else if stop {
+		if fn != "" {
+			fmt.Println("Capture stopped:", fn)
+		} else {
+			fmt.Println("Connection", conid, "was not being captured")
+		}
+	} else {
+		fmt.Println("Capturing connection", conid, "to", fn)
+	}
+}
+
+func showWhitelist(par string) {
+	rules := common.WhitelistRules()
+	if len(rules) == 0 {
+		fmt.Println("No whitelist rules - use configset to set Net.Whitelist")
+	}
+	for i, r := range rules {
+		fmt.Printf("%3d) %s\n", i+1, r.String())
+	}
+
+	network.MutexNet.Lock()
+	for _, v := range network.OpenCons {
+		v.Mutex.Lock()
+		if v.X.Permissions != 0 {
+			fmt.Printf("%8d) %21s  %s\n", v.ConnID, v.PeerAddr.IP(), v.X.Permissions.String())
+		}
+		v.Mutex.Unlock()
+	}
+	network.MutexNet.Unlock()
+}
+
 func init() {
 	newUI("net n", false, netStats, "Show network statistics. Specify ID to see its details.")
+	newUI("whitelist wl", false, showWhitelist, "Show peer whitelist rules and the connections they apply to")
 	newUI("drop", false, netDrop, "Disconenct from node with a given IP")
+	newUI("capture cap", false, netCapture, "Capture messages of a connection to a file: <ID> [<filename>|off]")
 	newUI("conn", false, netConn, "Connect to the given node (specify IP and optionally a port)")
 }
@@ -0,0 +1,306 @@
+package webui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/ParallelCoinTeam/duod/client/common"
+	"github.com/ParallelCoinTeam/duod/client/network"
+	"github.com/ParallelCoinTeam/duod/lib/btc"
+	"github.com/ParallelCoinTeam/duod/lib/utxo"
+	"golang.org/x/net/websocket"
+)
+
+// EventsQueue - how many events can wait for a WebSocket client before it gets disconnected
+const EventsQueue = 10000
+
+// Event - one message pushed to the WebSocket clients
+// Types: "tip", "reorg", "txadd", "txdel" and "address".
+type Event struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// EvBlock - data of "tip" event (and elements of "reorg" lists)
+type EvBlock struct {
+	Hash   string `json:"hash"`
+	Height uint32 `json:"height"`
+	Time   uint32 `json:"time,omitempty"`
+}
+
+// EvReorg - data of "reorg" event
+type EvReorg struct {
+	Disconnected []EvBlock `json:"disconnected"`
+	Connected    []EvBlock `json:"connected"`
+}
+
+// EvTx - data of "txadd" and "txdel" events
+type EvTx struct {
+	TxID   string `json:"txid"`
+	Size   int    `json:"size,omitempty"`
+	Fee    uint64 `json:"fee,omitempty"`
+	Local  bool   `json:"local,omitempty"`
+	Reason string `json:"reason,omitempty"` // for "txdel" - empty if mined
+}
+
+// EvAddr - data of "address" event
+type EvAddr struct {
+	Address string `json:"address"`
+	TxID    string `json:"txid"`
+	Vout    uint32 `json:"vout"`
+	Value   uint64 `json:"value"`
+	Height  uint32 `json:"height,omitempty"` // zero for memory pool txs
+	Spent   bool   `json:"spent,omitempty"`
+}
+
+// wsRequest - what a client can send to us
+// "events" sets which event types to get (all if empty), "subscribe"/"unsubscribe" change the watched addresses.
+type wsRequest struct {
+	Events      []string `json:"events"`
+	Subscribe   []string `json:"subscribe"`
+	Unsubscribe []string `json:"unsubscribe"`
+}
+
+type wsClient struct {
+	out    chan *Event
+	events map[string]bool   // nil for all
+	addrs  map[string]string // output script -> address
+}
+
+func (c *wsClient) wants(typ string) bool {
+	return c.events == nil || c.events[typ]
+}
+
+var (
+	evMutex   sync.Mutex
+	evClients = make(map[*wsClient]bool)
+	evAddrCnt int // total number of watched addresses (to quickly ignore UTXO changes)
+
+	// collected by BlockConnected and BlockDisconnected until ChainUpdated is called
+	evConnected, evDisconnected []EvBlock
+)
+
+// push - call it with evMutex locked
+func push(c *wsClient, ev *Event) {
+	select {
+	case c.out <- ev:
+	default:
+		// too slow - disconnect it
+		delete(evClients, c)
+		evAddrCnt -= len(c.addrs)
+		close(c.out)
+		common.CountSafe("WebUIEvtTooSlow")
+	}
+}
+
+// broadcast - call it with evMutex locked
+func broadcast(ev *Event) {
+	for c := range evClients {
+		if c.wants(ev.Type) {
+			push(c, ev)
+		}
+	}
+}
+
+// addrEvent - call it with evMutex locked
+func addrEvent(pkscr []byte, ea EvAddr) {
+	for c := range evClients {
+		if a, ok := c.addrs[string(pkscr)]; ok && c.wants("address") {
+			ea.Address = a
+			push(c, &Event{Type: "address", Data: ea})
+		}
+	}
+}
+
+// BlockConnected - call it for each block connected to the chain (from the chain's thread)
+func BlockConnected(bl *btc.Block) {
+	evMutex.Lock()
+	if len(evClients) > 0 {
+		evConnected = append(evConnected, EvBlock{Hash: bl.Hash.String(), Height: bl.Height, Time: bl.BlockTime()})
+	}
+	evMutex.Unlock()
+}
+
+// BlockDisconnected - call it for each block removed from the chain (from the chain's thread)
+func BlockDisconnected(bl *btc.Block) {
+	evMutex.Lock()
+	if len(evClients) > 0 {
+		evDisconnected = append(evDisconnected, EvBlock{Hash: bl.Hash.String(), Height: bl.Height, Time: bl.BlockTime()})
+	}
+	evMutex.Unlock()
+}
+
+// ChainUpdated - sends "reorg" and "tip" events (call it after committing a block)
+func ChainUpdated() {
+	evMutex.Lock()
+	defer evMutex.Unlock()
+	if len(evDisconnected) > 0 {
+		broadcast(&Event{Type: "reorg", Data: &EvReorg{Disconnected: evDisconnected, Connected: evConnected}})
+	}
+	if len(evConnected) > 0 {
+		broadcast(&Event{Type: "tip", Data: evConnected[len(evConnected)-1]})
+	}
+	evConnected, evDisconnected = nil, nil
+}
+
+// TxAccepted - call it for each transaction accepted to the memory pool (with TxMutex locked)
+func TxAccepted(t2s *network.OneTxToSend) {
+	evMutex.Lock()
+	defer evMutex.Unlock()
+	if len(evClients) == 0 {
+		return
+	}
+	broadcast(&Event{Type: "txadd", Data: &EvTx{TxID: t2s.Hash.String(), Size: len(t2s.Raw), Fee: t2s.Fee, Local: t2s.Local}})
+	if evAddrCnt > 0 {
+		for i, out := range t2s.TxOut {
+			addrEvent(out.PkScript, EvAddr{TxID: t2s.Hash.String(), Vout: uint32(i), Value: out.Value})
+		}
+		for i, in := range t2s.TxIn {
+			if out := spentOutput(t2s, i); out != nil {
+				addrEvent(out.PkScript, EvAddr{TxID: btc.NewUint256(in.Input.Hash[:]).String(), Vout: in.Input.Vout,
+					Value: out.Value, Height: out.BlockHeight, Spent: true})
+			}
+		}
+	}
+}
+
+// spentOutput - returns the output spent by the given input of a memory pool tx (call it with TxMutex locked)
+func spentOutput(t2s *network.OneTxToSend, i int) *btc.TxOut {
+	inp := &t2s.TxIn[i].Input
+	if t2s.MemInputs != nil && t2s.MemInputs[i] {
+		if par, ok := network.TransactionsToSend[btc.BIdx(inp.Hash[:])]; ok && int(inp.Vout) < len(par.TxOut) {
+			return par.TxOut[inp.Vout]
+		}
+		return nil
+	}
+	return common.BlockChain.Unspent.UnspentGet(inp)
+}
+
+// TxRemoved - call it for each transaction removed from the memory pool (with TxMutex locked)
+func TxRemoved(t2s *network.OneTxToSend, reason byte) {
+	evMutex.Lock()
+	if len(evClients) > 0 {
+		broadcast(&Event{Type: "txdel", Data: &EvTx{TxID: t2s.Hash.String(), Reason: network.ReasonToString(reason)}})
+	}
+	evMutex.Unlock()
+}
+
+// UTXOAdded - the wallet's TxNotifyAdd callback
+func UTXOAdded(rec *utxo.Rec) {
+	evMutex.Lock()
+	if evAddrCnt > 0 {
+		txid := btc.NewUint256(rec.TxID[:]).String()
+		for i, out := range rec.Outs {
+			if out != nil {
+				addrEvent(out.PKScr, EvAddr{TxID: txid, Vout: uint32(i), Value: out.Value, Height: rec.InBlock})
+			}
+		}
+	}
+	evMutex.Unlock()
+}
+
+// UTXOSpent - the wallet's TxNotifyDel callback
+func UTXOSpent(rec *utxo.Rec, outs []bool) {
+	evMutex.Lock()
+	if evAddrCnt > 0 {
+		txid := btc.NewUint256(rec.TxID[:]).String()
+		for i, spent := range outs {
+			if spent && i < len(rec.Outs) && rec.Outs[i] != nil {
+				addrEvent(rec.Outs[i].PKScr, EvAddr{TxID: txid, Vout: uint32(i), Value: rec.Outs[i].Value,
+					Height: rec.InBlock, Spent: true})
+			}
+		}
+	}
+	evMutex.Unlock()
+}
+
+// handleRequest - applies a message received from the client
+func (c *wsClient) handleRequest(req *wsRequest) {
+	evMutex.Lock()
+	defer evMutex.Unlock()
+	if req.Events != nil {
+		c.events = nil
+		if len(req.Events) > 0 {
+			c.events = make(map[string]bool, len(req.Events))
+			for _, e := range req.Events {
+				c.events[e] = true
+			}
+		}
+	}
+	if _, ok := evClients[c]; !ok {
+		return // already disconnected
+	}
+	for _, a := range req.Subscribe {
+		if aa, er := btc.NewAddrFromString(a); er == nil {
+			scr := string(aa.OutScript())
+			if _, ok := c.addrs[scr]; !ok {
+				c.addrs[scr] = a
+				evAddrCnt++
+			}
+		}
+	}
+	for _, a := range req.Unsubscribe {
+		if aa, er := btc.NewAddrFromString(a); er == nil {
+			scr := string(aa.OutScript())
+			if _, ok := c.addrs[scr]; ok {
+				delete(c.addrs, scr)
+				evAddrCnt--
+			}
+		}
+	}
+}
+
+func wsEvents(ws *websocket.Conn) {
+	c := &wsClient{out: make(chan *Event, EventsQueue), addrs: make(map[string]string)}
+	evMutex.Lock()
+	evClients[c] = true
+	evMutex.Unlock()
+
+	go func() {
+		for {
+			var msg []byte
+			if er := websocket.Message.Receive(ws, &msg); er != nil {
+				break
+			}
+			var req wsRequest
+			if json.Unmarshal(msg, &req) == nil {
+				c.handleRequest(&req)
+			}
+		}
+		ws.Close()
+		evMutex.Lock()
+		if evClients[c] {
+			delete(evClients, c)
+			evAddrCnt -= len(c.addrs)
+			close(c.out)
+		}
+		evMutex.Unlock()
+	}()
+
+	for ev := range c.out {
+		if er := websocket.JSON.Send(ws, ev); er != nil {
+			break
+		}
+	}
+	ws.Close()
+}
+
+// wsHandshake - the client's IP has already been checked, but do not let other sites' pages in
+func wsHandshake(cfg *websocket.Config, r *http.Request) error {
+	if o := r.Header.Get("Origin"); o != "" {
+		u, er := url.Parse(o)
+		if er != nil || u.Host != r.Host {
+			return websocket.ErrBadWebSocketOrigin
+		}
+	}
+	return nil
+}
+
+func pEvents(w http.ResponseWriter, r *http.Request) {
+	if !ipchecker(r) {
+		return
+	}
+	websocket.Server{Handshake: wsHandshake, Handler: wsEvents}.ServeHTTP(w, r)
+}
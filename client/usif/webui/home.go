@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/ParallelCoinTeam/duod/client/common"
+	"github.com/ParallelCoinTeam/duod/client/miner"
 	"github.com/ParallelCoinTeam/duod/client/network"
 	"github.com/ParallelCoinTeam/duod/client/usif"
 	"github.com/ParallelCoinTeam/duod/lib/btc"
@@ -68,6 +69,7 @@ func jsonStatus(w http.ResponseWriter, r *http.Request) {
 		LastTrustedBlockHeight uint32
 		LastHeaderHeight       uint32
 		BlockChainSynchronized bool
+		StaleTipWarning        string
 	}
 	common.Last.Mutex.Lock()
 	out.Height = common.Last.Block.Height
@@ -86,6 +88,7 @@ func jsonStatus(w http.ResponseWriter, r *http.Request) {
 	out.LastHeaderHeight = network.LastCommitedHeader.Height
 	network.MutexRcv.Unlock()
 	out.BlockChainSynchronized = common.GetBool(&common.BlockChainSynchronized)
+	out.StaleTipWarning = network.StaleTipWarning()
 
 	bx, er := json.Marshal(out)
 	if er == nil {
@@ -117,6 +120,8 @@ func jsonSystem(w http.ResponseWriter, r *http.Request) {
 		LastHeaderHeight uint32
 		NetworkHashRate  float64
 		SavingUTXO       bool
+		MinerOn          bool
+		MinerHashRate    float64
 	}
 
 	out.BlocksCached = network.CachedBlocksLen.Get()
@@ -145,6 +150,8 @@ func jsonSystem(w http.ResponseWriter, r *http.Request) {
 	mutexHrate.Unlock()
 
 	out.SavingUTXO = common.BlockChain.Unspent.WritingInProgress.Get()
+	st := miner.GetStatus()
+	out.MinerOn, out.MinerHashRate = st.Generate, st.Hashrate
 
 	bx, er := json.Marshal(out)
 	if er == nil {
@@ -0,0 +1,269 @@
+package webui
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ParallelCoinTeam/duod/client/common"
+	"github.com/ParallelCoinTeam/duod/client/miner"
+	"github.com/ParallelCoinTeam/duod/client/network"
+	"github.com/ParallelCoinTeam/duod/lib/btc"
+	"github.com/ParallelCoinTeam/duod/lib/others/peersdb"
+	"github.com/ParallelCoinTeam/duod/lib/others/sys"
+)
+
+// Node's metrics in Prometheus text format (version 0.0.4)
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+type metricsBuf struct {
+	bytes.Buffer
+}
+
+// metric - writes HELP and TYPE lines of a new metric
+func (b *metricsBuf) metric(name, typ, help string) {
+	fmt.Fprintf(b, "# HELP duod_%s %s\n# TYPE duod_%s %s\n", name, help, name, typ)
+}
+
+// value - writes one sample (labels given as name, value pairs)
+func (b *metricsBuf) value(name string, val interface{}, labels ...string) {
+	b.WriteString("duod_" + name)
+	if len(labels) > 0 {
+		b.WriteString("{")
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			b.WriteString(labels[i] + "=\"" + labelEscaper.Replace(labels[i+1]) + "\"")
+		}
+		b.WriteString("}")
+	}
+	if f, ok := val.(float64); ok {
+		fmt.Fprintf(b, " %g\n", f)
+	} else {
+		fmt.Fprintf(b, " %d\n", val)
+	}
+}
+
+// single - writes a metric with only one sample, without labels
+func (b *metricsBuf) single(name, typ, help string, val interface{}) {
+	b.metric(name, typ, help)
+	b.value(name, val)
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func sortedKeys(m map[string]uint64) (res []string) {
+	for k := range m {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return
+}
+
+func pMetrics(w http.ResponseWriter, r *http.Request) {
+	if !ipchecker(r) {
+		return
+	}
+	common.LockCfg()
+	on := common.CFG.WebUI.Metrics
+	common.UnlockCfg()
+	if !on {
+		http.NotFound(w, r)
+		return
+	}
+
+	b := new(metricsBuf)
+	metricsChain(b)
+	metricsNet(b)
+	metricsMempool(b)
+	metricsCounters(b)
+	metricsSystem(b)
+
+	w.Header()["Content-Type"] = []string{"text/plain; version=0.0.4"}
+	w.Write(b.Bytes())
+}
+
+func metricsChain(b *metricsBuf) {
+	common.Last.Mutex.Lock()
+	height := common.Last.Block.Height
+	ts := common.Last.Block.Timestamp()
+	diff := btc.GetDifficulty(common.Last.Block.Bits())
+	received := common.Last.Time.Unix()
+	common.Last.Mutex.Unlock()
+	network.MutexRcv.Lock()
+	hdrHeight := network.LastCommitedHeader.Height
+	network.MutexRcv.Unlock()
+
+	b.single("block_height", "gauge", "Height of the chain's tip.", height)
+	b.single("header_height", "gauge", "Height of the best known header.", hdrHeight)
+	b.single("block_timestamp_seconds", "gauge", "Timestamp of the chain's tip.", ts)
+	b.single("block_received_timestamp_seconds", "gauge", "When the chain's tip has been received.", received)
+	b.single("difficulty", "gauge", "Difficulty of the chain's tip.", diff)
+	b.single("chain_synchronized", "gauge", "1 if the block chain is synchronized.",
+		boolToInt(common.GetBool(&common.BlockChainSynchronized)))
+	b.single("blocks_cached", "gauge", "Blocks waiting in memory to be connected.", network.CachedBlocksLen.Get())
+
+	common.BlockChain.Unspent.RWMutex.RLock()
+	utxoTxs := len(common.BlockChain.Unspent.HashMap)
+	common.BlockChain.Unspent.RWMutex.RUnlock()
+	b.single("utxo_transactions", "gauge", "Transactions with unspent outputs.", utxoTxs)
+	b.single("utxo_saving", "gauge", "1 if the UTXO set is being written to disk.",
+		boolToInt(common.BlockChain.Unspent.WritingInProgress.Get()))
+}
+
+func metricsNet(b *metricsBuf) {
+	common.LockBw()
+	common.TickRecv()
+	common.TickSent()
+	dlNow := common.GetAvgBW(common.DlBytesPrevSec[:], common.DlBytesPrevSecIdx, 5)
+	ulNow := common.GetAvgBW(common.UlBytesPrevSec[:], common.UlBytesPrevSecIdx, 5)
+	dlTotal, ulTotal := common.DlBytesTotal, common.UlBytesTotal
+	common.UnlockBw()
+
+	b.metric("network_bytes_total", "counter", "Bytes received and sent since the node started.")
+	b.value("network_bytes_total", dlTotal, "direction", "in")
+	b.value("network_bytes_total", ulTotal, "direction", "out")
+	b.metric("network_speed_bytes", "gauge", "Download and upload speed in the last 5 seconds (bytes per second).")
+	b.value("network_speed_bytes", dlNow, "direction", "in")
+	b.value("network_speed_bytes", ulNow, "direction", "out")
+	b.metric("network_limit_bytes", "gauge", "Download and upload speed limits (bytes per second, 0 for none).")
+	b.value("network_limit_bytes", common.DownloadLimit(), "direction", "in")
+	b.value("network_limit_bytes", common.UploadLimit(), "direction", "out")
+
+	peers := map[string]int{"inbound": 0, "outbound": 0, "block-relay-only": 0}
+	peerBytes := make(map[string][2]uint64)
+	network.MutexNet.Lock()
+	for _, v := range network.OpenCons {
+		v.Mutex.Lock()
+		dir := "outbound"
+		if v.X.Incomming {
+			dir = "inbound"
+		} else if v.X.BlockRelayOnly {
+			dir = "block-relay-only"
+		}
+		pb := peerBytes[dir]
+		pb[0] += v.X.BytesReceived
+		pb[1] += v.X.BytesSent
+		peerBytes[dir] = pb
+		v.Mutex.Unlock()
+		peers[dir]++
+	}
+	network.MutexNet.Unlock()
+
+	dirs := []string{"block-relay-only", "inbound", "outbound"}
+	b.metric("peers", "gauge", "Open connections by their direction.")
+	for _, dir := range dirs {
+		b.value("peers", peers[dir], "direction", dir)
+	}
+	b.metric("peers_bytes", "gauge", "Bytes received and sent by the open connections.")
+	for _, dir := range dirs {
+		b.value("peers_bytes", peerBytes[dir][0], "direction", dir, "flow", "received")
+		b.value("peers_bytes", peerBytes[dir][1], "direction", dir, "flow", "sent")
+	}
+	b.single("known_peers", "gauge", "Addresses in the peers database.", peersdb.PeerDB.Count())
+}
+
+func metricsMempool(b *metricsBuf) {
+	rejected := make(map[string]uint64)
+	network.TxMutex.Lock()
+	cnt, size, weight := len(network.TransactionsToSend), network.TransactionsToSendSize, network.TransactionsToSendWeight
+	rsize := network.TransactionsRejectedSize
+	pending, waiting := len(network.TransactionsPending), len(network.WaitingForInputs)
+	for _, v := range network.TransactionsRejected {
+		rejected[network.ReasonToString(v.Reason)]++
+	}
+	network.TxMutex.Unlock()
+
+	b.single("mempool_transactions", "gauge", "Transactions in the memory pool.", cnt)
+	b.single("mempool_bytes", "gauge", "Size of the transactions in the memory pool.", size)
+	b.single("mempool_weight", "gauge", "Weight of the transactions in the memory pool.", weight)
+	b.single("mempool_min_fee_per_kb", "gauge", "Minimum fee per kB for the memory pool (in satoshis).",
+		common.MinFeePerKB())
+	b.single("mempool_pending_transactions", "gauge", "Transactions waiting to be processed.", pending)
+	b.single("mempool_awaiting_inputs", "gauge", "Transactions waiting for their inputs.", waiting)
+	b.single("rejected_bytes", "gauge", "Size of the rejected transactions kept in memory.", rsize)
+	b.metric("rejected_transactions", "gauge", "Rejected transactions kept in memory by the reason.")
+	for _, k := range sortedKeys(rejected) {
+		b.value("rejected_transactions", rejected[k], "reason", k)
+	}
+}
+
+// msgCounters - prefixes of the network message counters (metric name and direction)
+var msgCounters = map[string][2]string{
+	"rcvd": {"messages_total", "in"},
+	"sent": {"messages_total", "out"},
+	"rbts": {"messages_bytes_total", "in"},
+	"sbts": {"messages_bytes_total", "out"},
+}
+
+// msgCommands - the protocol commands that get their own label (the rest go as "other")
+var msgCommands = map[string]bool{
+	"version": true, "verack": true, "addr": true, "addrv2": true, "sendaddrv2": true, "getaddr": true,
+	"inv": true, "getdata": true, "notfound": true, "getblocks": true, "getheaders": true, "headers": true,
+	"block": true, "tx": true, "ping": true, "pong": true, "reject": true, "mempool": true, "feefilter": true,
+	"sendheaders": true, "sendcmpct": true, "cmpctblock": true, "getblocktxn": true, "blocktxn": true,
+	"filterload": true, "filteradd": true, "filterclear": true, "merkleblock": true, "wtxidrelay": true,
+	"alert": true, "getmp": true, "getmpdone": true, "auth": true, "authack": true,
+}
+
+// metricsCounters - the counters from /counts page
+func metricsCounters(b *metricsBuf) {
+	msgs := make(map[string]uint64)
+	rejects := make(map[string]uint64)
+	other := make(map[string]uint64)
+	common.CounterMutex.Lock()
+	for k, v := range common.Counter {
+		if len(k) > 5 && k[4] == '_' && msgCounters[k[:4]][0] != "" {
+			if !msgCommands[k[5:]] {
+				k = k[:5] + "other" // whatever a peer sent us, do not let it into the labels
+			}
+			msgs[k] += v
+		} else if strings.HasPrefix(k, "TxRejected") {
+			rejects[k[len("TxRejected"):]] = v
+		} else {
+			other[k] = v
+		}
+	}
+	common.CounterMutex.Unlock()
+
+	b.metric("messages_total", "counter", "Network messages by the command.")
+	for _, k := range sortedKeys(msgs) {
+		if mc := msgCounters[k[:4]]; mc[0] == "messages_total" {
+			b.value(mc[0], msgs[k], "command", k[5:], "direction", mc[1])
+		}
+	}
+	b.metric("messages_bytes_total", "counter", "Bytes of the network messages by the command.")
+	for _, k := range sortedKeys(msgs) {
+		if mc := msgCounters[k[:4]]; mc[0] == "messages_bytes_total" {
+			b.value(mc[0], msgs[k], "command", k[5:], "direction", mc[1])
+		}
+	}
+	b.metric("tx_rejected_total", "counter", "Transactions rejected by the reason.")
+	for _, k := range sortedKeys(rejects) {
+		b.value("tx_rejected_total", rejects[k], "reason", k)
+	}
+	b.metric("events_total", "counter", "Other counters of the node (as on the Counters page).")
+	for _, k := range sortedKeys(other) {
+		b.value("events_total", other[k], "name", k)
+	}
+}
+
+func metricsSystem(b *metricsBuf) {
+	heap, sysmem := sys.MemUsed()
+	b.single("uptime_seconds", "gauge", "Time since the node started.", uint64(time.Now().Sub(common.StartTime).Seconds()))
+	b.metric("memory_bytes", "gauge", "Memory used by Go runtime.")
+	b.value("memory_bytes", heap, "type", "heap")
+	b.value("memory_bytes", sysmem, "type", "sys")
+	b.single("ecdsa_verify_total", "counter", "ECDSA signatures verified.", btc.EcdsaVerifyCnt())
+	b.single("miner_hashrate", "gauge", "Hashes per second of the CPU miner.", miner.Hashrate())
+}
@@ -2,7 +2,7 @@ package webui
 
 import (
 	"fmt"
-	"sort"
+	"strconv"
 	"time"
 	//	"bytes"
 	//	"regexp"
@@ -11,38 +11,9 @@ import (
 	"net/http"
 
 	"github.com/ParallelCoinTeam/duod/client/common"
-	"github.com/ParallelCoinTeam/duod/lib/btc"
+	"github.com/ParallelCoinTeam/duod/client/usif"
 )
 
-type omv struct {
-	unknownMiner bool
-	cnt          int
-	bts          uint64
-	fees         uint64
-	//ebad_cnt int
-	//nya_cnt int
-}
-
-type onemiernstat []struct {
-	name string
-	omv
-}
-
-func (x onemiernstat) Len() int {
-	return len(x)
-}
-
-func (x onemiernstat) Less(i, j int) bool {
-	if x[i].cnt == x[j].cnt {
-		return x[i].name < x[j].name // Same numbers: sort by name
-	}
-	return x[i].cnt > x[j].cnt
-}
-
-func (x onemiernstat) Swap(i, j int) {
-	x[i], x[j] = x[j], x[i]
-}
-
 func pMiners(w http.ResponseWriter, r *http.Request) {
 	if !ipchecker(r) {
 		return
@@ -89,121 +60,73 @@ func jsonMiners(w http.ResponseWriter, r *http.Request) {
 		Unknown               bool
 		Name                  string
 		Blocks                int
+		Orphans               int
+		OrphanRate            float64
+		Hashrate              float64
 		TotalFees, TotalBytes uint64
-		//BUcnt, NYAcnt int
 	}
 
 	type theMiningStats struct {
-		MiningStatHours  uint
+		MiningStatHours  float64
 		BlockCount       uint
+		OrphanCount      int
 		FirstBlockTime   int64
 		AvgBlocksPerHour float64
 		AvgDifficulty    float64
 		AvgHashrate      float64
 		NextDiffChange   uint32
 		Miners           []oneMinerRow
+		Algos            []*usif.AlgoStat
 	}
 
 	common.ReloadMiners()
 
-	m := make(map[string]omv, 20)
-	var om omv
-	cnt := uint(0)
-	common.Last.Mutex.Lock()
-	end := common.Last.Block
-	common.Last.Mutex.Unlock()
-	var lastts int64
-	var diff float64
+	// the window is given as "hrs" or as "from" and "to" unix times
 	now := time.Now().Unix()
-
-	nextDiffChange := 2016 - end.Height%2016
-
-	//ebAdX := regexp.MustCompile("/EB[0-9]+/AD[0-9]+/")
-
-	for ; end != nil; cnt++ {
-		if now-int64(end.Timestamp()) > int64(common.CFG.Stat.MiningHrs)*3600 {
-			break
-		}
-		lastts = int64(end.Timestamp())
-		bl, _, e := common.BlockChain.Blocks.BlockGet(end.BlockHash)
-		if e != nil {
-			break
-		}
-
-		block, e := btc.NewBlock(bl)
-		if e != nil {
-			break
-		}
-
-		cbasetx, _ := btc.NewTx(bl[block.TxOffset:])
-
-		diff += btc.GetDifficulty(end.Bits())
-		miner, mid := common.TxMiner(cbasetx)
-		om = m[miner]
-		om.cnt++
-		om.bts += uint64(len(bl))
-		om.unknownMiner = (mid == -1)
-
-		// Blocks reward
-		var rew uint64
-		for o := range cbasetx.TxOut {
-			rew += cbasetx.TxOut[o].Value
-		}
-		fees := rew - btc.GetBlockReward(end.Height)
-		if int64(fees) > 0 { // solution for a possibility of a miner not claiming the reward (see block #501726)
-			om.fees += fees
-		}
-
-		/*if ebAdX.Find(cbasetx.TxIn[0].ScriptSig) != nil {
-			om.ebad_cnt++
-		}
-
-		if bytes.Index(cbasetx.TxIn[0].ScriptSig, []byte("/NYA/")) != -1 {
-			om.nya_cnt++
-		}*/
-
-		m[miner] = om
-
-		end = end.Parent
+	from := now - int64(common.CFG.Stat.MiningHrs)*3600
+	var to int64
+	if v, er := strconv.ParseUint(r.URL.Query().Get("hrs"), 10, 32); er == nil && v > 0 {
+		from = now - int64(v)*3600
+	}
+	if v, er := strconv.ParseInt(r.URL.Query().Get("from"), 10, 64); er == nil && v > 0 {
+		from = v
+	}
+	if v, er := strconv.ParseInt(r.URL.Query().Get("to"), 10, 64); er == nil && v > from {
+		to = v
 	}
 
-	if cnt == 0 {
+	ms := usif.GetMiningStats(uint32(from), uint32(to))
+	hrs := (float64(ms.To) - float64(ms.From)) / 3600
+	if ms.Blocks == 0 || hrs <= 0 {
 		w.Write([]byte("{}"))
 		return
 	}
 
-	srt := make(onemiernstat, len(m))
-	i := 0
-	for k, v := range m {
-		srt[i].name = k
-		srt[i].omv = v
-		i++
-	}
-	sort.Sort(srt)
+	common.Last.Mutex.Lock()
+	nextDiffChange := 2016 - common.Last.Block.Height%2016
+	common.Last.Mutex.Unlock()
 
 	var stats theMiningStats
-
-	diff /= float64(cnt)
-	bph := float64(cnt) / float64(common.CFG.Stat.MiningHrs)
-	hrate := bph / 6 * diff * 7158278.826667
-
-	stats.MiningStatHours = common.CFG.Stat.MiningHrs
-	stats.BlockCount = cnt
-	stats.FirstBlockTime = lastts
-	stats.AvgBlocksPerHour = bph
-	stats.AvgDifficulty = diff
-	stats.AvgHashrate = hrate
+	stats.MiningStatHours = hrs
+	stats.BlockCount = uint(ms.Blocks)
+	stats.OrphanCount = ms.Orphans
+	stats.FirstBlockTime = int64(ms.FirstBlockTime)
+	stats.AvgBlocksPerHour = float64(ms.Blocks) / hrs
+	stats.AvgDifficulty = ms.AvgDifficulty
+	stats.AvgHashrate = ms.Hashrate
 	stats.NextDiffChange = nextDiffChange
-
-	stats.Miners = make([]oneMinerRow, len(srt))
-	for i := range srt {
-		stats.Miners[i].Unknown = srt[i].unknownMiner
-		stats.Miners[i].Name = srt[i].name
-		stats.Miners[i].Blocks = srt[i].cnt
-		stats.Miners[i].TotalFees = srt[i].fees
-		stats.Miners[i].TotalBytes = srt[i].bts
-		//stats.Miners[i].BUcnt = srt[i].ebad_cnt
-		//stats.Miners[i].NYAcnt = srt[i].nya_cnt
+	stats.Algos = ms.Algos
+
+	stats.Miners = make([]oneMinerRow, len(ms.Miners))
+	for i, m := range ms.Miners {
+		stats.Miners[i].Unknown = m.Unknown
+		stats.Miners[i].Name = m.Name
+		stats.Miners[i].Blocks = m.Blocks
+		stats.Miners[i].Orphans = m.Orphans
+		stats.Miners[i].OrphanRate = m.OrphanRate
+		stats.Miners[i].Hashrate = m.Hashrate
+		stats.Miners[i].TotalFees = m.Fees
+		stats.Miners[i].TotalBytes = m.Bytes
 	}
 
 	bx, er := json.Marshal(stats)
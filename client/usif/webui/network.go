@@ -124,6 +124,7 @@ func jsonBandwidth(w http.ResponseWriter, r *http.Request) {
 	var out struct {
 		OpenConnsTotal  int
 		OpenConnsOut    uint32
+		OpenConnsBlkRly uint32
 		OpenConnsIn     uint32
 		DLSpeedNow      uint64
 		DLSpeedMax      uint64
@@ -149,6 +150,7 @@ func jsonBandwidth(w http.ResponseWriter, r *http.Request) {
 	network.MutexNet.Lock()
 	out.OpenConnsTotal = len(network.OpenCons)
 	out.OpenConnsOut = network.OutConsActive
+	out.OpenConnsBlkRly = network.BlockRelayConsActive
 	out.OpenConnsIn = network.InConsActive
 	network.MutexNet.Unlock()
 
@@ -0,0 +1,398 @@
+package webui
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ParallelCoinTeam/duod/client/common"
+	"github.com/ParallelCoinTeam/duod/client/network"
+	"github.com/ParallelCoinTeam/duod/client/rpcapi"
+	"github.com/ParallelCoinTeam/duod/lib/btc"
+	"github.com/ParallelCoinTeam/duod/lib/chain"
+)
+
+// Read-only REST interface, compatible with the one of bitcoind.
+// It needs no authentication, but it is only served to AllowedIP and only when WebUI.REST is on.
+
+const (
+	restMaxHeaders    = 2000
+	restMaxOutpoints  = 15
+	restMempoolHeight = 0x7fffffff // height of the outputs from the memory pool
+)
+
+func pRest(w http.ResponseWriter, r *http.Request) {
+	if !ipchecker(r) {
+		return
+	}
+	common.LockCfg()
+	on := common.CFG.WebUI.REST
+	common.UnlockCfg()
+	if !on {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != "GET" {
+		http.Error(w, "Only GET requests are supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pth := strings.Split(r.URL.Path[len("/rest/"):], "/")
+	last := pth[len(pth)-1]
+	dot := strings.LastIndex(last, ".")
+	if dot == -1 {
+		http.Error(w, "Output format not found (available: bin, hex, json)", http.StatusNotFound)
+		return
+	}
+	ext := last[dot+1:]
+	pth[len(pth)-1] = last[:dot]
+	if ext != "bin" && ext != "hex" && ext != "json" {
+		http.Error(w, "Output format not found (available: bin, hex, json)", http.StatusNotFound)
+		return
+	}
+
+	switch pth[0] {
+	case "block":
+		restBlock(w, pth[1:], ext)
+	case "headers":
+		restHeaders(w, pth[1:], ext)
+	case "tx":
+		restTx(w, pth[1:], ext)
+	case "getutxos":
+		restGetUtxos(w, pth[1:], ext)
+	case "chaininfo":
+		if len(pth) != 1 || ext != "json" {
+			http.Error(w, "Output format not found (available: json)", http.StatusNotFound)
+			return
+		}
+		restRPC(w, rpcapi.GetBlockchainInfo)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// restWrite - writes raw data in the requested format (js is used for json)
+func restWrite(w http.ResponseWriter, ext string, raw []byte, js interface{}) {
+	switch ext {
+	case "bin":
+		w.Header()["Content-Type"] = []string{"application/octet-stream"}
+		w.Write(raw)
+	case "hex":
+		w.Header()["Content-Type"] = []string{"text/plain"}
+		w.Write([]byte(hex.EncodeToString(raw) + "\n"))
+	default:
+		bx, er := json.Marshal(js)
+		if er != nil {
+			http.Error(w, er.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header()["Content-Type"] = []string{"application/json"}
+		w.Write(bx)
+		w.Write([]byte("\n"))
+	}
+}
+
+// restCall - calls the RPC handler and returns its result or the error message
+func restCall(f func(*rpcapi.RPCCommand, *rpcapi.RPCResponse), params ...interface{}) (interface{}, string) {
+	resp := new(rpcapi.RPCResponse)
+	f(&rpcapi.RPCCommand{Params: params}, resp)
+	if resp.Error != nil {
+		if e, ok := resp.Error.(rpcapi.RPCError); ok {
+			return nil, e.Message
+		}
+		return nil, fmt.Sprint(resp.Error)
+	}
+	return resp.Result, ""
+}
+
+// restRPC - writes the result of the RPC handler as json
+func restRPC(w http.ResponseWriter, f func(*rpcapi.RPCCommand, *rpcapi.RPCResponse), params ...interface{}) {
+	res, e := restCall(f, params...)
+	if e != "" {
+		http.Error(w, e, http.StatusNotFound)
+		return
+	}
+	restWrite(w, "json", nil, res)
+}
+
+// restHash - parses the hash from the URL (writes the error if it is invalid)
+func restHash(w http.ResponseWriter, s string) *btc.Uint256 {
+	if len(s) != 64 {
+		http.Error(w, "Invalid hash: "+s, http.StatusBadRequest)
+		return nil
+	}
+	h := btc.NewUint256FromString(s)
+	if h == nil {
+		http.Error(w, "Invalid hash: "+s, http.StatusBadRequest)
+	}
+	return h
+}
+
+// /rest/block/<hash>.<ext> or /rest/block/notxdetails/<hash>.<ext>
+func restBlock(w http.ResponseWriter, pth []string, ext string) {
+	verbosity := json.Number("2")
+	if len(pth) == 2 && pth[0] == "notxdetails" {
+		verbosity = json.Number("1")
+		pth = pth[1:]
+	}
+	if len(pth) != 1 {
+		http.Error(w, "Invalid URI format. Expected /rest/block/<hash>.<ext>", http.StatusBadRequest)
+		return
+	}
+	hash := restHash(w, pth[0])
+	if hash == nil {
+		return
+	}
+
+	if ext == "json" {
+		restRPC(w, rpcapi.GetBlock, hash.String(), verbosity)
+		return
+	}
+
+	common.BlockChain.BlockIndexAccess.Lock()
+	n := common.BlockChain.BlockIndex[hash.BIdx()]
+	haveData := n != nil && n.BlockSize != 0
+	common.BlockChain.BlockIndexAccess.Unlock()
+	if !haveData {
+		http.Error(w, hash.String()+" not found", http.StatusNotFound)
+		return
+	}
+	raw, _, er := common.BlockChain.Blocks.BlockGet(hash)
+	if er != nil {
+		http.Error(w, hash.String()+" not available: "+er.Error(), http.StatusNotFound)
+		return
+	}
+	restWrite(w, ext, raw, nil)
+}
+
+// /rest/headers/<count>/<hash>.<ext> - the headers of the active chain, starting from the given one
+func restHeaders(w http.ResponseWriter, pth []string, ext string) {
+	if len(pth) != 2 {
+		http.Error(w, "Invalid URI format. Expected /rest/headers/<count>/<hash>.<ext>", http.StatusBadRequest)
+		return
+	}
+	cnt, er := strconv.ParseUint(pth[0], 10, 32)
+	if er != nil || cnt < 1 || cnt > restMaxHeaders {
+		http.Error(w, fmt.Sprint("Header count out of range: ", pth[0]), http.StatusBadRequest)
+		return
+	}
+	hash := restHash(w, pth[1])
+	if hash == nil {
+		return
+	}
+
+	var nodes []*chain.BlockTreeNode
+	common.BlockChain.BlockIndexAccess.Lock()
+	n := common.BlockChain.BlockIndex[hash.BIdx()]
+	if n != nil && common.BlockChain.OnActiveBranch(n) {
+		end := common.BlockChain.LastBlock()
+		for end.Height >= n.Height+uint32(cnt) {
+			end = end.Parent
+		}
+		nodes = make([]*chain.BlockTreeNode, end.Height-n.Height+1)
+		for i := len(nodes) - 1; i >= 0; i-- {
+			nodes[i] = end
+			end = end.Parent
+		}
+	}
+	common.BlockChain.BlockIndexAccess.Unlock()
+
+	if ext == "json" {
+		res := make([]interface{}, len(nodes))
+		for i, n := range nodes {
+			hdr, e := restCall(rpcapi.GetBlockHeader, n.BlockHash.String(), true)
+			if e != "" {
+				http.Error(w, e, http.StatusNotFound)
+				return
+			}
+			res[i] = hdr
+		}
+		restWrite(w, ext, nil, res)
+		return
+	}
+	raw := make([]byte, 0, 80*len(nodes))
+	for _, n := range nodes {
+		raw = append(raw, n.BlockHeader[:]...)
+	}
+	restWrite(w, ext, raw, nil)
+}
+
+// restFindTx - looks for the transaction in the memory pool and then in the UTXO set.
+// Without a transaction index, only the transactions with some unspent outputs can be found in the chain.
+func restFindTx(txid *btc.Uint256) (tx *btc.Tx, blockHash *btc.Uint256) {
+	network.TxMutex.Lock()
+	if t2s, ok := network.TransactionsToSend[txid.BIdx()]; ok {
+		tx = t2s.Tx
+	}
+	network.TxMutex.Unlock()
+	if tx != nil {
+		return
+	}
+
+	height, ok := common.BlockChain.Unspent.TxHeight(txid)
+	if !ok {
+		return
+	}
+	common.BlockChain.BlockIndexAccess.Lock()
+	n := common.BlockChain.LastBlock()
+	for n != nil && n.Height > height {
+		n = n.Parent
+	}
+	common.BlockChain.BlockIndexAccess.Unlock()
+	if n == nil || n.Height != height {
+		return
+	}
+
+	raw, _, er := common.BlockChain.Blocks.BlockGet(n.BlockHash)
+	if er != nil {
+		return
+	}
+	bl, er := btc.NewBlock(raw)
+	if er == nil {
+		er = bl.BuildTxList()
+	}
+	if er != nil {
+		return
+	}
+	for _, t := range bl.Txs {
+		if t.Hash.Equal(txid) {
+			return t, n.BlockHash
+		}
+	}
+	return
+}
+
+// /rest/tx/<txid>.<ext>
+func restTx(w http.ResponseWriter, pth []string, ext string) {
+	if len(pth) != 1 {
+		http.Error(w, "Invalid URI format. Expected /rest/tx/<txid>.<ext>", http.StatusBadRequest)
+		return
+	}
+	txid := restHash(w, pth[0])
+	if txid == nil {
+		return
+	}
+	tx, bh := restFindTx(txid)
+	if tx == nil {
+		http.Error(w, txid.String()+" not found", http.StatusNotFound)
+		return
+	}
+	if ext != "json" {
+		restWrite(w, ext, tx.Raw, nil)
+		return
+	}
+	res := rpcapi.TxToJSON(tx)
+	if bh != nil {
+		res["blockhash"] = bh.String()
+	}
+	restWrite(w, ext, nil, res)
+}
+
+// /rest/getutxos[/checkmempool]/<txid>-<n>/<txid>-<n>/...<ext>
+func restGetUtxos(w http.ResponseWriter, pth []string, ext string) {
+	var checkMempool bool
+	if len(pth) > 0 && pth[0] == "checkmempool" {
+		checkMempool = true
+		pth = pth[1:]
+	}
+	if len(pth) == 0 || len(pth) == 1 && pth[0] == "" {
+		http.Error(w, "Error: empty request", http.StatusBadRequest)
+		return
+	}
+	if len(pth) > restMaxOutpoints {
+		http.Error(w, fmt.Sprint("Error: max outpoints exceeded (max: ", restMaxOutpoints, ", tried: ", len(pth), ")"),
+			http.StatusBadRequest)
+		return
+	}
+
+	pos := make([]btc.TxPrevOut, len(pth))
+	for i, s := range pth {
+		ss := strings.Split(s, "-")
+		var txid *btc.Uint256
+		if len(ss) == 2 && len(ss[0]) == 64 {
+			txid = btc.NewUint256FromString(ss[0])
+		}
+		vout, er := strconv.ParseUint(ss[len(ss)-1], 10, 32)
+		if txid == nil || er != nil {
+			http.Error(w, "Parse error: "+s, http.StatusBadRequest)
+			return
+		}
+		pos[i] = btc.TxPrevOut{Hash: txid.Hash, Vout: uint32(vout)}
+	}
+
+	outs := make([]*btc.TxOut, len(pos))
+	if checkMempool {
+		network.TxMutex.Lock()
+		for i := range pos {
+			if _, spent := network.SpentOutputs[pos[i].UIdx()]; spent {
+				continue
+			}
+			if t2s, ok := network.TransactionsToSend[btc.NewUint256(pos[i].Hash[:]).BIdx()]; ok {
+				if int(pos[i].Vout) < len(t2s.TxOut) {
+					o := *t2s.TxOut[pos[i].Vout]
+					o.BlockHeight = restMempoolHeight
+					outs[i] = &o
+				}
+				continue
+			}
+			outs[i] = common.BlockChain.Unspent.UnspentGet(&pos[i])
+		}
+		network.TxMutex.Unlock()
+	} else {
+		for i := range pos {
+			outs[i] = common.BlockChain.Unspent.UnspentGet(&pos[i])
+		}
+	}
+
+	last := common.BlockChain.LastBlock()
+	bitmap := make([]byte, (len(outs)+7)/8)
+	var found []*btc.TxOut
+	var bits string
+	for i, o := range outs {
+		if o != nil {
+			bitmap[i/8] |= 1 << uint(i%8)
+			found = append(found, o)
+			bits += "1"
+		} else {
+			bits += "0"
+		}
+	}
+
+	if ext != "json" {
+		buf := new(bytes.Buffer)
+		binary.Write(buf, binary.LittleEndian, last.Height)
+		buf.Write(last.BlockHash.Hash[:])
+		btc.WriteVlen(buf, uint64(len(bitmap)))
+		buf.Write(bitmap)
+		btc.WriteVlen(buf, uint64(len(found)))
+		for _, o := range found {
+			binary.Write(buf, binary.LittleEndian, uint32(0)) // tx version (not used)
+			binary.Write(buf, binary.LittleEndian, o.BlockHeight)
+			binary.Write(buf, binary.LittleEndian, o.Value)
+			btc.WriteVlen(buf, uint64(len(o.PkScript)))
+			buf.Write(o.PkScript)
+		}
+		restWrite(w, ext, buf.Bytes(), nil)
+		return
+	}
+
+	utxos := make([]interface{}, len(found))
+	for i, o := range found {
+		utxos[i] = map[string]interface{}{
+			"height":       o.BlockHeight,
+			"value":        float64(o.Value) / 1e8,
+			"scriptPubKey": rpcapi.ScriptPubKeyJSON(o.PkScript),
+		}
+	}
+	restWrite(w, ext, nil, map[string]interface{}{
+		"chainHeight":  last.Height,
+		"chaintipHash": last.BlockHash.String(),
+		"bitmap":       bits,
+		"utxos":        utxos,
+	})
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/ParallelCoinTeam/duod"
 	"github.com/ParallelCoinTeam/duod/client/common"
 	"github.com/ParallelCoinTeam/duod/client/usif"
+	"github.com/ParallelCoinTeam/duod/lib/L"
 )
 
 var startTime time.Time
@@ -26,6 +27,11 @@ func ipchecker(r *http.Request) bool {
 	if n != 4 {
 		return false
 	}
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		// client certificate signed by WebUI.ClientCA
+		r.ParseForm()
+		return true
+	}
 	addr := (a << 24) | (b << 16) | (c << 8) | d
 	common.LockCfg()
 	for i := range common.WebUIAllowed {
@@ -167,6 +173,8 @@ func pWalletIsOff(w http.ResponseWriter, r *http.Request) {
 // ServerThread -
 func ServerThread(iface string) {
 	http.HandleFunc("/webui/", pWebUI)
+	http.HandleFunc("/rest/", pRest)
+	http.HandleFunc("/metrics", pMetrics)
 
 	http.HandleFunc("/wal", pWal)
 	http.HandleFunc("/snd", pSnd)
@@ -206,5 +214,18 @@ func ServerThread(iface string) {
 
 	http.HandleFunc("/mempool_fees.txt", txtMempoolFees)
 
+	http.HandleFunc("/events", pEvents) // WebSocket
+
+	if common.CFG.WebUI.TLS {
+		cfg, er := common.TLSConfig(iface, common.CFG.WebUI.TLSCert, common.CFG.WebUI.TLSKey,
+			common.CFG.WebUI.ClientCA, false)
+		if er != nil {
+			L.Error("WebUI TLS: ", er.Error())
+			return
+		}
+		srv := &http.Server{Addr: iface, TLSConfig: cfg}
+		L.Error("WebUI: ", srv.ListenAndServeTLS("", "").Error())
+		return
+	}
 	http.ListenAndServe(iface, nil)
 }
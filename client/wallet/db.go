@@ -214,13 +214,27 @@ func allDelUTXOs(tx *utxo.Rec, outs []bool) {
 	}
 }
 
+// Additional callbacks made from TxNotifyAdd and TxNotifyDel (e.g. for the WebUI events)
+var (
+	// TxNotifyAddCB -
+	TxNotifyAddCB func(*utxo.Rec)
+	// TxNotifyDelCB -
+	TxNotifyDelCB func(*utxo.Rec, []bool)
+)
+
 // TxNotifyAdd -This is called while accepting the block (from the chain's thread)
 func TxNotifyAdd(tx *utxo.Rec) {
 	NewUTXO(tx)
+	if TxNotifyAddCB != nil {
+		TxNotifyAddCB(tx)
+	}
 }
 
 // TxNotifyDel -This is called while accepting the block (from the chain's thread)
 func TxNotifyDel(tx *utxo.Rec, outs []bool) {
+	if TxNotifyDelCB != nil {
+		TxNotifyDelCB(tx, outs)
+	}
 	allDelUTXOs(tx, outs)
 }
 
@@ -0,0 +1,203 @@
+package btc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	// AuxPowVersion - block version flag of merge-mined blocks (followed by AuxPoW data after the header)
+	AuxPowVersion = 0x100
+	// AuxPowMaxChainBranch - maximum height of the chain merkle tree
+	AuxPowMaxChainBranch = 30
+)
+
+// MergedMiningHeader - marks the chain merkle root in the parent's coinbase
+var MergedMiningHeader = []byte{0xfa, 0xbe, 'm', 'm'}
+
+// AuxPow - proof that the block's hash has been committed to by a parent chain's block
+type AuxPow struct {
+	Coinbase       *Tx      // parent block's coinbase
+	ParentHash     [32]byte // not used (it is the hash of ParentHeader)
+	CoinbaseBranch [][32]byte
+	CoinbaseIndex  uint32 // must be zero
+	ChainBranch    [][32]byte
+	ChainIndex     uint32
+	ParentHeader   [80]byte
+
+	Size int // of the serialized data
+}
+
+// AuxPowChainID - returns the chain ID from the block version
+func AuxPowChainID(ver uint32) uint32 {
+	return ver >> 16
+}
+
+func readBranch(rd *bytes.Reader) (branch [][32]byte, index uint32, er error) {
+	var cnt uint64
+	if cnt, er = ReadVLen(rd); er != nil {
+		return
+	}
+	if cnt > 64 {
+		er = errors.New("AuxPoW merkle branch too long")
+		return
+	}
+	branch = make([][32]byte, cnt)
+	for i := range branch {
+		if _, er = io.ReadFull(rd, branch[i][:]); er != nil {
+			return
+		}
+	}
+	er = binary.Read(rd, binary.LittleEndian, &index)
+	return
+}
+
+// NewAuxPow - decodes AuxPoW data (as it follows the block header)
+func NewAuxPow(b []byte) (ap *AuxPow, er error) {
+	ap = new(AuxPow)
+	var n int
+	if ap.Coinbase, n = NewTx(b); ap.Coinbase == nil || n == 0 {
+		return nil, errors.New("AuxPoW coinbase corrupt")
+	}
+	ap.Coinbase.SetHash(b[:n])
+	rd := bytes.NewReader(b[n:])
+	if _, er = io.ReadFull(rd, ap.ParentHash[:]); er != nil {
+		return nil, er
+	}
+	if ap.CoinbaseBranch, ap.CoinbaseIndex, er = readBranch(rd); er != nil {
+		return nil, er
+	}
+	if ap.ChainBranch, ap.ChainIndex, er = readBranch(rd); er != nil {
+		return nil, er
+	}
+	if _, er = io.ReadFull(rd, ap.ParentHeader[:]); er != nil {
+		return nil, er
+	}
+	ap.Size = len(b) - rd.Len()
+	return
+}
+
+// AuxPowHeaderLen - length of the block header at the beginning of the data, with AuxPoW data (if any).
+// Returns zero if the data is too short or the AuxPoW is corrupt.
+func AuxPowHeaderLen(b []byte) int {
+	if len(b) < 80 {
+		return 0
+	}
+	if binary.LittleEndian.Uint32(b[0:4])&AuxPowVersion == 0 {
+		return 80
+	}
+	ap, er := NewAuxPow(b[80:])
+	if er != nil {
+		return 0
+	}
+	return 80 + ap.Size
+}
+
+func writeBranch(wr io.Writer, branch [][32]byte, index uint32) {
+	WriteVlen(wr, uint64(len(branch)))
+	for i := range branch {
+		wr.Write(branch[i][:])
+	}
+	binary.Write(wr, binary.LittleEndian, index)
+}
+
+// Serialize - returns the AuxPoW data, to be put after the block header
+func (ap *AuxPow) Serialize() []byte {
+	wr := new(bytes.Buffer)
+	ap.Coinbase.WriteSerialized(wr)
+	wr.Write(ap.ParentHash[:])
+	writeBranch(wr, ap.CoinbaseBranch, ap.CoinbaseIndex)
+	writeBranch(wr, ap.ChainBranch, ap.ChainIndex)
+	wr.Write(ap.ParentHeader[:])
+	return wr.Bytes()
+}
+
+// ParentBlockHash - the parent block's hash, which is checked against the target
+func (ap *AuxPow) ParentBlockHash() *Uint256 {
+	return NewSha2Hash(ap.ParentHeader[:])
+}
+
+// CheckMerkleBranch - returns the merkle root, calculated from the hash at the given index and the branch
+func CheckMerkleBranch(hash [32]byte, branch [][32]byte, index uint32) [32]byte {
+	var buf [64]byte
+	for i := range branch {
+		if index&1 != 0 {
+			copy(buf[:32], branch[i][:])
+			copy(buf[32:], hash[:])
+		} else {
+			copy(buf[:32], hash[:])
+			copy(buf[32:], branch[i][:])
+		}
+		hash = Sha2Sum(buf[:])
+		index >>= 1
+	}
+	return hash
+}
+
+// AuxPowExpectedIndex - where in the chain merkle tree our block must be (for the given nonce)
+func AuxPowExpectedIndex(nonce, chainID uint32, height int) uint32 {
+	rand := nonce
+	rand = rand*1103515245 + 12345
+	rand += chainID
+	rand = rand*1103515245 + 12345
+	return rand % (1 << uint(height))
+}
+
+// Check - verifies that the AuxPoW commits to the block with the given hash.
+// The parent block's proof of work is not checked here.
+func (ap *AuxPow) Check(hash *Uint256, chainID uint32) error {
+	if ap.CoinbaseIndex != 0 {
+		return errors.New("AuxPoW is not a generate")
+	}
+	if AuxPowChainID(binary.LittleEndian.Uint32(ap.ParentHeader[0:4])) == chainID {
+		return errors.New("AuxPoW parent has our chain ID")
+	}
+	if len(ap.ChainBranch) > AuxPowMaxChainBranch {
+		return errors.New("AuxPoW chain merkle branch too long")
+	}
+
+	root := CheckMerkleBranch(hash.Hash, ap.ChainBranch, ap.ChainIndex)
+	for i := 0; i < 16; i++ {
+		root[i], root[31-i] = root[31-i], root[i] // it is put into the coinbase in big endian
+	}
+
+	cbRoot := CheckMerkleBranch(ap.Coinbase.Hash.Hash, ap.CoinbaseBranch, ap.CoinbaseIndex)
+	if !bytes.Equal(cbRoot[:], ap.ParentHeader[36:68]) {
+		return errors.New("AuxPoW merkle root incorrect")
+	}
+
+	if len(ap.Coinbase.TxIn) == 0 {
+		return errors.New("AuxPoW coinbase has no inputs")
+	}
+	script := ap.Coinbase.TxIn[0].ScriptSig
+	pc := bytes.Index(script, root[:])
+	if pc == -1 {
+		return errors.New("AuxPoW missing chain merkle root in parent coinbase")
+	}
+	if head := bytes.Index(script, MergedMiningHeader); head != -1 {
+		if bytes.Contains(script[head+1:], MergedMiningHeader) {
+			return errors.New("Multiple merged mining headers in coinbase")
+		}
+		if head+len(MergedMiningHeader) != pc {
+			return errors.New("Merged mining header is not just before chain merkle root")
+		}
+	} else if pc > 20 {
+		// for backward compatibility
+		return errors.New("AuxPoW chain merkle root must start in the first 20 bytes of the parent coinbase")
+	}
+
+	pc += len(root)
+	if len(script)-pc < 8 {
+		return errors.New("AuxPoW missing chain merkle tree size and nonce in parent coinbase")
+	}
+	if binary.LittleEndian.Uint32(script[pc:pc+4]) != 1<<uint(len(ap.ChainBranch)) {
+		return errors.New("AuxPoW merkle branch size does not match parent coinbase")
+	}
+	nonce := binary.LittleEndian.Uint32(script[pc+4 : pc+8])
+	if ap.ChainIndex != AuxPowExpectedIndex(nonce, chainID, len(ap.ChainBranch)) {
+		return errors.New("AuxPoW wrong index")
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package btc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+const testChainID = 0x00d0
+
+// auxTest - how to build a synthetic parent block
+type auxTest struct {
+	nonce      uint32
+	height     int    // of the chain merkle tree
+	prefix     []byte // coinbase script before the merged mining header
+	noHeader   bool
+	twoHeaders bool
+	size       uint32 // in the coinbase (if zero - correct one)
+	parentVer  uint32
+}
+
+func (at *auxTest) build(hash *Uint256) *AuxPow {
+	ap := new(AuxPow)
+	ap.ChainIndex = AuxPowExpectedIndex(at.nonce, testChainID, at.height)
+	for i := 0; i < at.height; i++ {
+		ap.ChainBranch = append(ap.ChainBranch, Sha2Sum([]byte{byte(i)}))
+	}
+	root := CheckMerkleBranch(hash.Hash, ap.ChainBranch, ap.ChainIndex)
+	for i := 0; i < 16; i++ {
+		root[i], root[31-i] = root[31-i], root[i]
+	}
+
+	script := append([]byte{}, at.prefix...)
+	if !at.noHeader {
+		script = append(script, MergedMiningHeader...)
+	}
+	script = append(script, root[:]...)
+	size := at.size
+	if size == 0 {
+		size = 1 << uint(at.height)
+	}
+	var b [8]byte
+	binary.LittleEndian.PutUint32(b[0:4], size)
+	binary.LittleEndian.PutUint32(b[4:8], at.nonce)
+	script = append(script, b[:]...)
+	if at.twoHeaders {
+		script = append(script, MergedMiningHeader...)
+	}
+
+	ap.Coinbase = &Tx{Version: 1, TxIn: []*TxIn{{Input: TxPrevOut{Vout: 0xffffffff}, ScriptSig: script,
+		Sequence: 0xffffffff}}, TxOut: []*TxOut{{Value: 50e8, PkScript: []byte{0x51}}}}
+	ap.Coinbase.SetHash(ap.Coinbase.Serialize())
+
+	ap.CoinbaseBranch = [][32]byte{Sha2Sum([]byte("other tx"))}
+	parentRoot := CheckMerkleBranch(ap.Coinbase.Hash.Hash, ap.CoinbaseBranch, 0)
+	ver := at.parentVer
+	if ver == 0 {
+		ver = 0x20000000
+	}
+	binary.LittleEndian.PutUint32(ap.ParentHeader[0:4], ver)
+	copy(ap.ParentHeader[36:68], parentRoot[:])
+	return ap
+}
+
+func TestAuxPowCheck(t *testing.T) {
+	hash := NewSha2Hash([]byte("aux block"))
+	tests := []struct {
+		name string
+		at   auxTest
+		ok   bool
+	}{
+		{"valid", auxTest{nonce: 7, height: 3, prefix: []byte{3, 1, 2, 3}}, true},
+		{"valid single chain", auxTest{nonce: 1, prefix: []byte{3, 1, 2, 3}}, true},
+		{"valid legacy", auxTest{nonce: 5, height: 2, prefix: []byte{3, 1, 2, 3}, noHeader: true}, true},
+		{"legacy too far", auxTest{nonce: 5, height: 2, prefix: make([]byte, 21), noHeader: true}, false},
+		{"two headers", auxTest{nonce: 5, height: 2, twoHeaders: true}, false},
+		{"size mismatch", auxTest{nonce: 5, height: 2, size: 2}, false},
+		{"our chain ID", auxTest{nonce: 5, height: 2, parentVer: testChainID<<16 | AuxPowVersion}, false},
+	}
+	for _, tc := range tests {
+		ap := tc.at.build(hash)
+		if er := ap.Check(hash, testChainID); (er == nil) != tc.ok {
+			t.Error(tc.name, "- unexpected result:", er)
+		}
+	}
+
+	at := auxTest{nonce: 9, height: 4}
+	ap := at.build(hash)
+	if er := ap.Check(NewSha2Hash([]byte("other block")), testChainID); er == nil {
+		t.Error("AuxPoW accepted for other block")
+	}
+	ap.ChainIndex ^= 1
+	if er := ap.Check(hash, testChainID); er == nil {
+		t.Error("Wrong chain index accepted")
+	}
+
+	ap = at.build(hash)
+	ap.CoinbaseIndex = 1
+	if er := ap.Check(hash, testChainID); er == nil {
+		t.Error("Coinbase index other than zero accepted")
+	}
+
+	ap = at.build(hash)
+	ap.ParentHeader[40] ^= 1
+	if er := ap.Check(hash, testChainID); er == nil {
+		t.Error("Wrong parent merkle root accepted")
+	}
+
+	ap = at.build(hash)
+	ap.Coinbase.TxOut[0].Value++
+	ap.Coinbase.SetHash(ap.Coinbase.Serialize())
+	if er := ap.Check(hash, testChainID); er == nil {
+		t.Error("Coinbase modification not detected")
+	}
+}
+
+func TestAuxPowBlock(t *testing.T) {
+	cb := &Tx{Version: 1, TxIn: []*TxIn{{Input: TxPrevOut{Vout: 0xffffffff}, ScriptSig: []byte{1, 1, 0},
+		Sequence: 0xffffffff}}, TxOut: []*TxOut{{Value: 50e8, PkScript: []byte{0x51}}}}
+	cb.SetHash(cb.Serialize())
+
+	hdr := make([]byte, 80)
+	binary.LittleEndian.PutUint32(hdr[0:4], testChainID<<16|AuxPowVersion|4)
+	copy(hdr[36:68], cb.Hash.Hash[:])
+	hash := NewSha2Hash(hdr)
+
+	at := auxTest{nonce: 3, height: 2}
+	aux := at.build(hash).Serialize()
+	raw := append(append(append(hdr, aux...), 1), cb.Raw...)
+
+	bl, er := NewBlock(raw)
+	if er != nil {
+		t.Fatal(er.Error())
+	}
+	if bl.AuxPow == nil || bl.AuxPow.Size != len(aux) {
+		t.Fatal("AuxPoW not decoded")
+	}
+	if !bl.Hash.Equal(hash) {
+		t.Error("Block hash should not depend on AuxPoW")
+	}
+	if er = bl.AuxPow.Check(bl.Hash, testChainID); er != nil {
+		t.Error(er.Error())
+	}
+	if !bytes.Equal(bl.AuxPow.Serialize(), aux) {
+		t.Error("AuxPoW serialization mismatch")
+	}
+	if bl.TxCount != 1 || bl.TxOffset != 80+len(aux)+1 {
+		t.Error("Bad TxCount/TxOffset", bl.TxCount, bl.TxOffset)
+	}
+	if er = bl.BuildTxList(); er != nil {
+		t.Fatal(er.Error())
+	}
+	if !bl.MerkleRootMatch() {
+		t.Error("Merkle root mismatch")
+	}
+	if er = bl.BuildNoWitnessData(); er != nil || !bytes.Equal(bl.NoWitnessData, raw) {
+		t.Error("Bad non-segwit data")
+	}
+
+	// header with AuxPoW, as in "headers" message
+	if n := AuxPowHeaderLen(raw); n != 80+len(aux) {
+		t.Error("Bad AuxPowHeaderLen", n)
+	}
+	bl, er = NewBlock(append(append([]byte{}, raw[:80+len(aux)]...), 0))
+	if er != nil || bl.AuxPow == nil || bl.TxCount != 0 {
+		t.Error("Header only block", er)
+	}
+
+	// header without AuxPoW
+	bl, er = NewBlock(append(append([]byte{}, hdr...), 0))
+	if er != nil || bl.AuxPow != nil {
+		t.Error("Header without AuxPoW", er)
+	}
+
+	// truncated AuxPoW
+	if _, er = NewBlock(raw[:100]); er == nil {
+		t.Error("Truncated AuxPoW accepted")
+	}
+	if AuxPowHeaderLen(raw[:100]) != 0 || AuxPowHeaderLen(raw[:79]) != 0 {
+		t.Error("AuxPowHeaderLen of truncated data")
+	}
+	binary.LittleEndian.PutUint32(hdr[0:4], 4)
+	if AuxPowHeaderLen(hdr) != 80 {
+		t.Error("AuxPowHeaderLen without AuxPoW")
+	}
+}
@@ -15,6 +15,7 @@ type Block struct {
 	TxCount, TxOffset int  // Number of transactions and byte offset to the first one
 	Trusted           bool // if the block is trusted, we do not check signatures and some other things...
 	LastKnownHeight   uint32
+	AuxPow            *AuxPow // set if the version has AuxPowVersion flag and the data follows the header
 
 	BlockExtraInfo // If we cache block on disk (between downloading and comitting), this data has to be preserved
 
@@ -52,14 +53,31 @@ func (bl *Block) UpdateContent(data []byte) error {
 		return errors.New("Block too short")
 	}
 	bl.Raw = data
-	bl.TxCount, bl.TxOffset = VLen(data[80:])
+	bl.AuxPow = nil
+	if bl.Version()&AuxPowVersion != 0 && len(data) > 81 { // not just a header
+		ap, er := NewAuxPow(data[80:])
+		if er != nil {
+			return errors.New("Block's AuxPoW corrupt: " + er.Error() + " - RPC_Result:bad-auxpow")
+		}
+		bl.AuxPow = ap
+	}
+	hl := bl.HeaderLen()
+	bl.TxCount, bl.TxOffset = VLen(data[hl:])
 	if bl.TxOffset == 0 {
 		return errors.New("Block's txn_count field corrupt - RPC_Result:bad-blk-length")
 	}
-	bl.TxOffset += 80
+	bl.TxOffset += hl
 	return nil
 }
 
+// HeaderLen - length of the header, with AuxPoW data (if any)
+func (bl *Block) HeaderLen() int {
+	if bl.AuxPow != nil {
+		return 80 + bl.AuxPow.Size
+	}
+	return 80
+}
+
 // Version -
 func (bl *Block) Version() uint32 {
 	return binary.LittleEndian.Uint32(bl.Raw[0:4])
@@ -89,12 +107,12 @@ func (bl *Block) Bits() uint32 {
 // It would be more elegant to use bytes.Reader here, but this solution is ~20% faster.
 func (bl *Block) BuildTxList() (e error) {
 	if bl.TxCount == 0 {
-		bl.TxCount, bl.TxOffset = VLen(bl.Raw[80:])
+		bl.TxCount, bl.TxOffset = VLen(bl.Raw[bl.HeaderLen():])
 		if bl.TxCount == 0 || bl.TxOffset == 0 {
 			e = errors.New("Block's txn_count field corrupt - RPC_Result:bad-blk-length")
 			return
 		}
-		bl.TxOffset += 80
+		bl.TxOffset += bl.HeaderLen()
 	}
 	bl.Txs = make([]*Tx, bl.TxCount)
 
@@ -103,7 +121,7 @@ func (bl *Block) BuildTxList() (e error) {
 	var wg sync.WaitGroup
 	var data2hash, witness2hash []byte
 
-	bl.NoWitnessSize = 80 + VLenSize(uint64(bl.TxCount))
+	bl.NoWitnessSize = bl.HeaderLen() + VLenSize(uint64(bl.TxCount))
 	bl.BlockWeight = 4 * uint(bl.NoWitnessSize)
 
 	for i := 0; i < bl.TxCount; i++ {
@@ -161,7 +179,7 @@ func (bl *Block) BuildNoWitnessData() (e error) {
 		}
 	}
 	oldFormatBlock := new(bytes.Buffer)
-	oldFormatBlock.Write(bl.Raw[:80])
+	oldFormatBlock.Write(bl.Raw[:bl.HeaderLen()])
 	WriteVlen(oldFormatBlock, uint64(bl.TxCount))
 	for _, tx := range bl.Txs {
 		tx.WriteSerialized(oldFormatBlock)
@@ -138,6 +138,42 @@ func CalcMerkle(mtr [][32]byte) (res []byte, mutated bool) {
 	return
 }
 
+// MerkleTree - returns the levels of the merkle tree, from the given hashes up to the root
+// (each level with an odd number of hashes has the last one duplicated)
+func MerkleTree(mtr [][32]byte) (levels [][][32]byte) {
+	level := make([][32]byte, len(mtr))
+	copy(level, mtr)
+	var buf [64]byte
+	for len(level) > 1 {
+		if len(level)&1 != 0 {
+			level = append(level, level[len(level)-1])
+		}
+		levels = append(levels, level)
+		next := make([][32]byte, len(level)/2)
+		for i := range next {
+			copy(buf[:32], level[2*i][:])
+			copy(buf[32:], level[2*i+1][:])
+			next[i] = Sha2Sum(buf[:])
+		}
+		level = next
+	}
+	return append(levels, level)
+}
+
+// MerkleTreeBranch - returns the merkle branch of the hash at the given index of the tree's first level
+func MerkleTreeBranch(levels [][][32]byte, index int) (branch [][32]byte) {
+	for _, level := range levels[:len(levels)-1] {
+		branch = append(branch, level[index^1])
+		index >>= 1
+	}
+	return
+}
+
+// MerkleBranch - returns the merkle branch of the hash at the given index (see CheckMerkleBranch)
+func MerkleBranch(mtr [][32]byte, index int) (branch [][32]byte) {
+	return MerkleTreeBranch(MerkleTree(mtr), index)
+}
+
 // GetWitnessMerkle -
 func GetWitnessMerkle(txs []*Tx) (res []byte, mutated bool) {
 	mtr := make([][32]byte, len(txs), 3*len(txs)) // make the buffer 3 times longer as we use append() inside CalcMerkle
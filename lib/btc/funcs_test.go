@@ -1,6 +1,7 @@
 package btc
 
 import (
+	"bytes"
 	"testing"
 )
 
@@ -59,3 +60,22 @@ func TestParseAmount(t *testing.T) {
 		}
 	}
 }
+
+func TestMerkleBranch(t *testing.T) {
+	for cnt := 1; cnt <= 9; cnt++ {
+		mtr := make([][32]byte, cnt)
+		for i := range mtr {
+			mtr[i] = Sha2Sum([]byte{byte(i)})
+		}
+		root, _ := CalcMerkle(append([][32]byte{}, mtr...))
+		for i := range mtr {
+			res := CheckMerkleBranch(mtr[i], MerkleBranch(mtr, i), uint32(i))
+			if !bytes.Equal(res[:], root) {
+				t.Error("Bad merkle branch for", i, "of", cnt)
+			}
+		}
+		if levels := MerkleTree(mtr); !bytes.Equal(levels[len(levels)-1][0][:], root) {
+			t.Error("Bad merkle tree root of", cnt)
+		}
+	}
+}
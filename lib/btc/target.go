@@ -22,6 +22,9 @@ func SetCompact(nCompact uint32) (res *big.Int) {
 	return res
 }
 
+// HashesPerDifficulty - expected number of hashes for a block of difficulty 1 (2^256 / (0xffff<<208))
+const HashesPerDifficulty = 4295032833.000015
+
 // GetDifficulty -
 func GetDifficulty(bits uint32) (diff float64) {
 	shift := int(bits>>24) & 0xff
@@ -0,0 +1,126 @@
+package chain
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/ParallelCoinTeam/duod/lib/btc"
+)
+
+// newTestAuxPow - makes a parent block (with a single chain merkle tree) committing to the hash
+func newTestAuxPow(hash *btc.Uint256) []byte {
+	ap := new(btc.AuxPow)
+	root := hash.Hash
+	for i := 0; i < 16; i++ {
+		root[i], root[31-i] = root[31-i], root[i]
+	}
+	script := append(append([]byte{}, btc.MergedMiningHeader...), root[:]...)
+	script = append(script, 1, 0, 0, 0, 0, 0, 0, 0) // tree size and nonce
+	ap.Coinbase = newTestTx([]*btc.TxIn{{Input: btc.TxPrevOut{Vout: 0xffffffff}, ScriptSig: script,
+		Sequence: 0xffffffff}}, 50e8)
+	binary.LittleEndian.PutUint32(ap.ParentHeader[0:4], 0x20000000)
+	copy(ap.ParentHeader[36:68], ap.Coinbase.Hash.Hash[:])
+	return ap.Serialize()
+}
+
+// newTestAuxBlock - like newTestBlock, but with AuxPoW
+func newTestAuxBlock(t *testing.T, ver uint32, prev []byte, ts, bits uint32, cb *btc.Tx) *btc.Block {
+	bl := newTestBlock(t, prev, ts, bits, cb)
+	binary.LittleEndian.PutUint32(bl.Raw[0:4], ver)
+	hash := btc.NewSha2Hash(bl.Raw[:80])
+	raw := append(append(append([]byte{}, bl.Raw[:80]...), newTestAuxPow(hash)...), bl.Raw[80:]...)
+	bl, er := btc.NewBlock(raw)
+	if er != nil {
+		t.Fatal(er.Error())
+	}
+	return bl
+}
+
+func TestAuxPowProposal(t *testing.T) {
+	ch := NewChainExt(t.TempDir()+"/", btc.NewUint256FromString(testGenesis), false, nil, &BlockDBOpts{})
+	defer ch.Close()
+
+	prev := ch.LastBlock().BlockHash.Hash[:]
+	ts := ch.LastBlock().Timestamp() + 600
+	bits := ch.GetNextWorkRequired(ch.LastBlock(), ts)
+	cb := newTestCoinbase(1, btc.GetBlockReward(1))
+	ver := uint32(AuxPowChainID<<16 | btc.AuxPowVersion | 4)
+
+	if res := ch.CheckBlockProposal(newTestAuxBlock(t, ver, prev, ts, bits, cb)); res != "bad-auxpow-height" {
+		t.Error("before activation - got", res)
+	}
+
+	ch.Consensus.AuxPowHeight = 1
+	if res := ch.CheckBlockProposal(newTestAuxBlock(t, ver, prev, ts, bits, cb)); res != "" {
+		t.Error("valid AuxPoW block rejected:", res)
+	}
+	if res := ch.CheckBlockProposal(newTestAuxBlock(t, ver+1<<16, prev, ts, bits, cb)); res != "bad-auxpow-chainid" {
+		t.Error("wrong chain ID - got", res)
+	}
+
+	// AuxPoW made for other block
+	bl := newTestAuxBlock(t, ver, prev, ts, bits, cb)
+	binary.LittleEndian.PutUint32(bl.Raw[68:72], ts+1)
+	bl, _ = btc.NewBlock(bl.Raw)
+	if res := ch.CheckBlockProposal(bl); res != "bad-auxpow" {
+		t.Error("AuxPoW of other block - got", res)
+	}
+
+	// only the header
+	bl = newTestBlock(t, prev, ts, bits, cb)
+	binary.LittleEndian.PutUint32(bl.Raw[0:4], ver)
+	bl, _ = btc.NewBlock(bl.Raw)
+	if res := ch.CheckBlockProposal(bl); res != "bad-auxpow" {
+		t.Error("missing AuxPoW - got", res)
+	}
+}
+
+func TestAuxPowAlwaysChecked(t *testing.T) {
+	ch := NewChainExt(t.TempDir()+"/", btc.NewUint256FromString(testGenesis), false, nil, &BlockDBOpts{})
+	defer ch.Close()
+	ch.Consensus.AuxPowHeight = 1
+
+	prev := ch.LastBlock().BlockHash.Hash[:]
+	ts := ch.LastBlock().Timestamp() + 600
+	bits := ch.GetNextWorkRequired(ch.LastBlock(), ts)
+	cb := newTestCoinbase(1, btc.GetBlockReward(1))
+	ver := uint32(AuxPowChainID<<16 | btc.AuxPowVersion | 4)
+
+	preCheck := func(bl *btc.Block) error {
+		ch.BlockIndexAccess.Lock()
+		defer ch.BlockIndexAccess.Unlock()
+		_, _, er := ch.PreCheckBlock(bl)
+		return er
+	}
+
+	// header without AuxPoW
+	bl := newTestBlock(t, prev, ts, bits, cb)
+	binary.LittleEndian.PutUint32(bl.Raw[0:4], ver)
+	hdr, _ := btc.NewBlock(append(append([]byte{}, bl.Raw[:80]...), 0))
+	if er := preCheck(hdr); er == nil || !strings.Contains(er.Error(), "bad-auxpow") {
+		t.Error("header without AuxPoW - got", er)
+	}
+
+	// header with AuxPoW, but the parent block not mined
+	bl = newTestAuxBlock(t, ver, prev, ts, bits, cb)
+	hdr, _ = btc.NewBlock(append(append([]byte{}, bl.Raw[:bl.HeaderLen()]...), 0))
+	if hdr.AuxPow == nil {
+		t.Fatal("AuxPoW not decoded from the header")
+	}
+	if er := preCheck(hdr); er == nil || !strings.Contains(er.Error(), "high-hash") {
+		t.Error("parent block's PoW - got", er)
+	}
+	if er := ch.CheckProofOfWork(hdr); er == nil {
+		t.Error("parent block's PoW not checked")
+	}
+
+	// trusted block with AuxPoW of other block
+	bl = newTestAuxBlock(t, ver, prev, ts, bits, cb)
+	binary.LittleEndian.PutUint32(bl.Raw[68:72], ts+1)
+	bl, _ = btc.NewBlock(bl.Raw)
+	bl.Trusted = true
+	if er := ch.PostCheckBlock(bl); er == nil || !strings.Contains(er.Error(), "bad-auxpow") {
+		t.Error("trusted block's AuxPoW - got", er)
+	}
+}
@@ -14,6 +14,11 @@ import (
 // PreCheckBlock -
 // Make sure to call this function with ch.BlockIndexAccess locked
 func (ch *Chain) PreCheckBlock(bl *btc.Block) (dos bool, maybelater bool, err error) {
+	return ch.preCheckBlock(bl, true)
+}
+
+// preCheckBlock - block proposals are checked without the proof of work
+func (ch *Chain) preCheckBlock(bl *btc.Block, checkPOW bool) (dos bool, maybelater bool, err error) {
 	// Size limits
 	if len(bl.Raw) < 81 {
 		err = errors.New("CheckBlock() : size limits failed - RPC_Result:bad-blk-length")
@@ -28,9 +33,8 @@ func (ch *Chain) PreCheckBlock(bl *btc.Block) (dos bool, maybelater bool, err er
 		return
 	}
 
-	// Check proof-of-work
-	if !btc.CheckProofOfWork(bl.Hash, bl.Bits()) {
-		err = errors.New("CheckBlock() : proof of work failed - RPC_Result:high-hash")
+	// Check proof-of-work (headers of merge-mined blocks must come with AuxPoW)
+	if err = ch.checkProofOfWork(bl, checkPOW); err != nil {
 		dos = true
 		return
 	}
@@ -61,6 +65,12 @@ func (ch *Chain) PreCheckBlock(bl *btc.Block) (dos bool, maybelater bool, err er
 
 	bl.Height = prevblk.Height + 1
 
+	if ver&btc.AuxPowVersion != 0 && (ch.Consensus.AuxPowHeight == 0 || bl.Height < ch.Consensus.AuxPowHeight) {
+		err = errors.New("CheckBlock() : AuxPoW not active yet - RPC_Result:bad-auxpow-height")
+		dos = true
+		return
+	}
+
 	// Reject the block if it reaches into the chain deeper than our unwind buffer
 	lstNow := ch.LastBlock()
 	if prevblk != lstNow && int(lstNow.Height)-int(bl.Height) >= MovingCheckopintDepth {
@@ -107,6 +117,33 @@ func (ch *Chain) PreCheckBlock(bl *btc.Block) (dos bool, maybelater bool, err er
 	return
 }
 
+// CheckProofOfWork - checks the block's hash or AuxPoW against the target (needs no context)
+func (ch *Chain) CheckProofOfWork(bl *btc.Block) error {
+	return ch.checkProofOfWork(bl, true)
+}
+
+// checkProofOfWork - checks the block's hash or AuxPoW against the target
+// With checkPOW false only the AuxPoW commitment is checked.
+func (ch *Chain) checkProofOfWork(bl *btc.Block, checkPOW bool) error {
+	hash := bl.Hash
+	if bl.Version()&btc.AuxPowVersion != 0 {
+		if bl.AuxPow == nil {
+			return errors.New("CheckBlock() : AuxPoW data missing - RPC_Result:bad-auxpow")
+		}
+		if btc.AuxPowChainID(bl.Version()) != ch.Consensus.AuxPowChainID {
+			return errors.New("CheckBlock() : AuxPoW with wrong chain ID - RPC_Result:bad-auxpow-chainid")
+		}
+		if er := bl.AuxPow.Check(bl.Hash, ch.Consensus.AuxPowChainID); er != nil {
+			return errors.New("CheckBlock() : " + er.Error() + " - RPC_Result:bad-auxpow")
+		}
+		hash = bl.AuxPow.ParentBlockHash()
+	}
+	if checkPOW && !btc.CheckProofOfWork(hash, bl.Bits()) {
+		return errors.New("CheckBlock() : proof of work failed - RPC_Result:high-hash")
+	}
+	return nil
+}
+
 // ApplyBlockFlags -
 func (ch *Chain) ApplyBlockFlags(bl *btc.Block) {
 	if bl.BlockTime() >= BIP16SwitchTime {
@@ -135,12 +172,24 @@ func (ch *Chain) ApplyBlockFlags(bl *btc.Block) {
 
 // PostCheckBlock -
 func (ch *Chain) PostCheckBlock(bl *btc.Block) (err error) {
+	return ch.postCheckBlock(bl, true)
+}
+
+// postCheckBlock - block proposals are checked without the proof of work
+func (ch *Chain) postCheckBlock(bl *btc.Block, checkPOW bool) (err error) {
 	// Size limits
 	if len(bl.Raw) < 81 {
 		err = errors.New("CheckBlock() : size limits failed low - RPC_Result:bad-blk-length")
 		return
 	}
 
+	// The block's AuxPoW does not have to be the one that came with its header
+	if bl.Version()&btc.AuxPowVersion != 0 {
+		if err = ch.checkProofOfWork(bl, checkPOW); err != nil {
+			return
+		}
+	}
+
 	if bl.Txs == nil {
 		err = bl.BuildTxList()
 		if err != nil {
@@ -25,6 +25,7 @@ type Chain struct {
 
 	BlockIndexAccess sync.Mutex
 	BlockIndex       map[[btc.Uint256IdxLen]byte]*BlockTreeNode
+	activeByHeight   []*BlockTreeNode // nodes of the active branch (see NodeAtHeight), protected by BlockIndexAccess
 
 	CB NewChanOpts // callbacks used by Unspent database
 
@@ -41,6 +42,8 @@ type Chain struct {
 		BIP66Height                         uint32
 		BIP91Height                         uint32
 		S2XHeight                           uint32
+		AuxPowHeight                        uint32 // if non zero merge-mined blocks are accepted from this block onwards
+		AuxPowChainID                       uint32 // our chain ID in the version of merge-mined blocks
 	}
 }
 
@@ -50,6 +53,7 @@ type NewChanOpts struct {
 	UndoBlocks       uint // undo this many blocks when opening the chain
 	UTXOCallbacks    utxo.CallbackFunctions
 	BlockMinedCB     func(*btc.Block) // used to remove mined txs from memory pool
+	BlockUndoneCB    func(*btc.Block) // called for each block disconnected from the chain (during a reorg)
 }
 
 // NewChainExt - This is the very first function one should call in order to use this package
@@ -66,6 +70,7 @@ func NewChainExt(dbrootdir string, genesis *btc.Uint256, rescan bool, opts *NewC
 	ch.Consensus.GensisTimestamp = 1405742300 // 1231006505
 	ch.Consensus.MaxPOWBits = 0x1e0fffff // 0x1d00ffff
 	ch.Consensus.MaxPOWValue, _ = new(big.Int).SetString("00000000FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF", 16)
+	ch.Consensus.AuxPowChainID = AuxPowChainID
 	if ch.testnet() {
 		ch.Consensus.BIP34Height = 1000000 // 21111
 		ch.Consensus.BIP65Height = 1000000 // 581885
@@ -73,6 +78,7 @@ func NewChainExt(dbrootdir string, genesis *btc.Uint256, rescan bool, opts *NewC
 		ch.Consensus.EnforceCSV = 1000000 // 770112
 		ch.Consensus.EnforceSegwit = 1000000 // 834624
 		ch.Consensus.BIP9Threshold = 1000000 // 1512
+		ch.Consensus.AuxPowHeight = 1000000
 	} else {
 		ch.Consensus.BIP34Height = 1000000 // 227931
 		ch.Consensus.BIP65Height = 1000000 // 388381
@@ -81,6 +87,7 @@ func NewChainExt(dbrootdir string, genesis *btc.Uint256, rescan bool, opts *NewC
 		ch.Consensus.EnforceSegwit = 1000000 // 481824 // https://www.reddit.com/r/Bitcoin/comments/6okd1n/bip91_lock_in_is_guaranteed_as_of_block_476768/
 		ch.Consensus.BIP91Height = 1000000 // 477120
 		ch.Consensus.BIP9Threshold = 1000000 // 1916
+		ch.Consensus.AuxPowHeight = 1000000
 	}
 
 	ch.Blocks = NewBlockDBExt(dbrootdir, bdbopts)
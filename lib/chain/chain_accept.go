@@ -135,13 +135,13 @@ func (ch *Chain) commitTxs(bl *btc.Block, changes *utxo.BlockChanges) (sigopscos
 				if wasSpent {
 					if int(inp.Vout) >= len(spentMap) {
 						println("txin", inp.String(), "did not have vout", inp.Vout)
-						e = errors.New("Tx VOut too big")
+						e = errors.New("Tx VOut too big - RPC_Result:bad-txns-inputs-missingorspent")
 						return
 					}
 
 					if spentMap[inp.Vout] {
 						println("txin", inp.String(), "already spent in this block")
-						e = errors.New("Double spend inside the block")
+						e = errors.New("Double spend inside the block - RPC_Result:bad-txns-inputs-missingorspent")
 						return
 					}
 				}
@@ -149,24 +149,25 @@ func (ch *Chain) commitTxs(bl *btc.Block, changes *utxo.BlockChanges) (sigopscos
 				if tout == nil {
 					t, ok := blUnsp[inp.Hash]
 					if !ok {
-						e = errors.New("Unknown input TxID: " + btc.NewUint256(inp.Hash[:]).String())
+						e = errors.New("Unknown input TxID: " + btc.NewUint256(inp.Hash[:]).String() + " - RPC_Result:bad-txns-inputs-missingorspent")
 						return
 					}
 
 					if inp.Vout >= uint32(len(t)) {
 						println("Vout too big", len(t), inp.String())
-						e = errors.New("Vout too big")
+						e = errors.New("Vout too big - RPC_Result:bad-txns-inputs-missingorspent")
 						return
 					}
 
 					if t[inp.Vout] == nil {
 						println("Vout already spent", inp.String())
-						e = errors.New("Vout already spent")
+						e = errors.New("Vout already spent - RPC_Result:bad-txns-inputs-missingorspent")
 						return
 					}
 
 					if t[inp.Vout].WasCoinbase {
-						e = errors.New("Cannot spend block's own coinbase in TxID: " + btc.NewUint256(inp.Hash[:]).String())
+						e = errors.New("Cannot spend block's own coinbase in TxID: " + btc.NewUint256(inp.Hash[:]).String() +
+							" - RPC_Result:bad-txns-premature-spend-of-coinbase")
 						return
 					}
 
@@ -174,7 +175,8 @@ func (ch *Chain) commitTxs(bl *btc.Block, changes *utxo.BlockChanges) (sigopscos
 					t[inp.Vout] = nil // and now mark it as spent:
 				} else {
 					if tout.WasCoinbase && changes.Height-tout.BlockHeight < CoinbaseMaturity {
-						e = errors.New("Trying to spend prematured coinbase: " + btc.NewUint256(inp.Hash[:]).String())
+						e = errors.New("Trying to spend prematured coinbase: " + btc.NewUint256(inp.Hash[:]).String() +
+							" - RPC_Result:bad-txns-premature-spend-of-coinbase")
 						return
 					}
 					// it is confirmed already so delete it later
@@ -226,7 +228,7 @@ func (ch *Chain) commitTxs(bl *btc.Block, changes *utxo.BlockChanges) (sigopscos
 				wg.Wait()
 				if verErrCount > 0 {
 					println("VerifyScript failed", verErrCount, "time (s)")
-					e = errors.New(fmt.Sprint("VerifyScripts failed ", verErrCount, "time (s)"))
+					e = errors.New(fmt.Sprint("VerifyScripts failed ", verErrCount, "time (s) - RPC_Result:blk-bad-inputs"))
 					return
 				}
 			}
@@ -234,7 +236,7 @@ func (ch *Chain) commitTxs(bl *btc.Block, changes *utxo.BlockChanges) (sigopscos
 			// For coinbase tx we need to check (like satoshi) whether the script size is between 2 and 100 bytes
 			// (Previously we made sure in CheckBlock() that this was a coinbase type tx)
 			if len(bl.Txs[0].TxIn[0].ScriptSig) < 2 || len(bl.Txs[0].TxIn[0].ScriptSig) > 100 {
-				e = errors.New(fmt.Sprint("Coinbase script has a wrong length ", len(bl.Txs[0].TxIn[0].ScriptSig)))
+				e = errors.New(fmt.Sprint("Coinbase script has a wrong length ", len(bl.Txs[0].TxIn[0].ScriptSig), " - RPC_Result:bad-cb-length"))
 				return
 			}
 		}
@@ -251,7 +253,7 @@ func (ch *Chain) commitTxs(bl *btc.Block, changes *utxo.BlockChanges) (sigopscos
 		if i > 0 {
 			bl.Txs[i].Fee = txinsum - txoutsum
 			if txoutsum > txinsum {
-				e = errors.New(fmt.Sprintf("More spent (%.8f) than at the input (%.8f) in TX %s",
+				e = errors.New(fmt.Sprintf("More spent (%.8f) than at the input (%.8f) in TX %s - RPC_Result:bad-txns-in-belowout",
 					float64(txoutsum)/1e8, float64(txinsum)/1e8, bl.Txs[i].Hash.String()))
 				return
 			}
@@ -264,7 +266,7 @@ func (ch *Chain) commitTxs(bl *btc.Block, changes *utxo.BlockChanges) (sigopscos
 	}
 
 	if sumblockin < sumblockout {
-		e = errors.New(fmt.Sprintf("Out:%d > In:%d", sumblockout, sumblockin))
+		e = errors.New(fmt.Sprintf("Out:%d > In:%d - RPC_Result:bad-cb-amount", sumblockout, sumblockin))
 		return
 	}
 
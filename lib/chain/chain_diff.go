@@ -86,3 +86,25 @@ func (b1 *BlockTreeNode) MorePOW(b2 *BlockTreeNode) bool {
 	}
 	return b1sum > b2sum
 }
+
+// ChainWork - Returns the sum of difficulties of all the blocks up to (and including) this one
+// Make sure to call this function with ch.BlockIndexAccess locked
+func (n *BlockTreeNode) ChainWork() float64 {
+	if n.chainWork != 0 {
+		return n.chainWork
+	}
+	var path []*BlockTreeNode
+	for n != nil && n.chainWork == 0 {
+		path = append(path, n)
+		n = n.Parent
+	}
+	var sum float64
+	if n != nil {
+		sum = n.chainWork
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		sum += btc.GetDifficulty(path[i].Bits())
+		path[i].chainWork = sum
+	}
+	return sum
+}
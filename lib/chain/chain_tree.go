@@ -23,6 +23,8 @@ type BlockTreeNode struct {
 	BlockHeader [80]byte
 
 	Trusted bool
+
+	chainWork float64 // cached by ChainWork()
 }
 
 // ParseUntilBlock -
@@ -210,6 +212,25 @@ func (ch *Chain) HasAllParents(dst *BlockTreeNode) bool {
 	}
 }
 
+// NodeAtHeight - returns the node of the active branch at the given height, or nil.
+// Make sure to call this function with ch.BlockIndexAccess locked
+func (ch *Chain) NodeAtHeight(height uint32) *BlockTreeNode {
+	last := ch.LastBlock()
+	if height > last.Height {
+		return nil
+	}
+	if int(last.Height) < len(ch.activeByHeight) {
+		ch.activeByHeight = ch.activeByHeight[:last.Height+1]
+	} else {
+		ch.activeByHeight = append(ch.activeByHeight, make([]*BlockTreeNode, int(last.Height)+1-len(ch.activeByHeight))...)
+	}
+	// only the part that has changed since the last call needs to be updated
+	for n := last; n != nil && ch.activeByHeight[n.Height] != n; n = n.Parent {
+		ch.activeByHeight[n.Height] = n
+	}
+	return ch.activeByHeight[height]
+}
+
 // OnActiveBranch returns true if the given node is on the active branch
 func (ch *Chain) OnActiveBranch(dst *BlockTreeNode) bool {
 	top := ch.LastBlock()
@@ -275,6 +296,11 @@ func (ch *Chain) UndoLastBlock() {
 
 	ch.Unspent.UndoBlockTxs(bl, last.Parent.BlockHash.Hash[:])
 	ch.SetLast(last.Parent)
+
+	if ch.CB.BlockUndoneCB != nil {
+		bl.Height = last.Height
+		ch.CB.BlockUndoneCB(bl)
+	}
 }
 
 // delAllChildren make sure ch.BlockIndexAccess is locked before calling it
@@ -0,0 +1,45 @@
+package chain
+
+import (
+	"testing"
+
+	"github.com/ParallelCoinTeam/duod/lib/btc"
+)
+
+// testBranch - appends count nodes to the given one
+func testBranch(parent *BlockTreeNode, count int, id byte) (res []*BlockTreeNode) {
+	for i := 0; i < count; i++ {
+		n := &BlockTreeNode{Parent: parent, Height: parent.Height + 1,
+			BlockHash: btc.NewUint256([]byte{id, byte(i), 31: 0})}
+		res = append(res, n)
+		parent = n
+	}
+	return
+}
+
+func TestNodeAtHeight(t *testing.T) {
+	ch := new(Chain)
+	ch.BlockTreeRoot = new(BlockTreeNode)
+	active := append([]*BlockTreeNode{ch.BlockTreeRoot}, testBranch(ch.BlockTreeRoot, 10, 1)...)
+
+	check := func(branch []*BlockTreeNode) {
+		ch.SetLast(branch[len(branch)-1])
+		for h, n := range branch {
+			if ch.NodeAtHeight(uint32(h)) != n {
+				t.Fatal("wrong node at height", h)
+			}
+		}
+		if ch.NodeAtHeight(uint32(len(branch))) != nil {
+			t.Error("node above the tip")
+		}
+	}
+	check(active)
+
+	// reorg to a longer fork
+	fork := append(active[:6:6], testBranch(active[5], 8, 2)...)
+	check(fork)
+
+	// back to a shorter branch
+	check(active[:4])
+	check(active)
+}
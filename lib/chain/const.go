@@ -11,4 +11,6 @@ const (
 	CoinbaseMaturity = 23
 	// MedianTimeSpan -
 	MedianTimeSpan = 11
+	// AuxPowChainID - put in the version of merge-mined blocks, so one parent block cannot be used twice
+	AuxPowChainID = 0x00d0
 )
@@ -0,0 +1,41 @@
+package chain
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/ParallelCoinTeam/duod/lib/btc"
+)
+
+// RejectReason - returns BIP22 reason from an error of the block checking functions ("inconclusive" if not known)
+func RejectReason(e error) string {
+	if idx := strings.Index(e.Error(), "- RPC_Result:"); idx != -1 {
+		return e.Error()[idx+13:]
+	}
+	return "inconclusive"
+}
+
+// CheckBlockProposal - checks if the block (BIP23 proposal) could be mined on top of the current chain.
+// The proof of work is not checked and nothing gets committed.
+// Returns an empty string if the block is valid, otherwise BIP22 reject reason.
+// Call it from the chain's thread, as it reads the unspent database.
+func (ch *Chain) CheckBlockProposal(bl *btc.Block) string {
+	ch.BlockIndexAccess.Lock()
+	if !bytes.Equal(bl.ParentHash(), ch.LastBlock().BlockHash.Hash[:]) {
+		ch.BlockIndexAccess.Unlock()
+		return "inconclusive-not-best-prevblk"
+	}
+	_, _, er := ch.preCheckBlock(bl, false)
+	ch.BlockIndexAccess.Unlock()
+
+	if er == nil {
+		er = ch.postCheckBlock(bl, false)
+	}
+	if er == nil {
+		_, _, er = ch.ProcessBlockTransactions(bl, bl.Height, bl.Height)
+	}
+	if er != nil {
+		return RejectReason(er)
+	}
+	return ""
+}
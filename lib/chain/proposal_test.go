@@ -0,0 +1,95 @@
+package chain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/ParallelCoinTeam/duod/lib/btc"
+)
+
+const testGenesis = "000009f0fcbad3aac904d3660cfdcf238bf298cfe73adf1d39d14fc5c740ccc7"
+
+func newTestTx(ins []*btc.TxIn, value uint64) *btc.Tx {
+	tx := &btc.Tx{Version: 1, TxIn: ins, TxOut: []*btc.TxOut{{Value: value, PkScript: []byte{0x51}}}}
+	tx.Raw = tx.Serialize()
+	tx.SetHash(tx.Raw)
+	return tx
+}
+
+func newTestCoinbase(height byte, value uint64) *btc.Tx {
+	return newTestTx([]*btc.TxIn{{Input: btc.TxPrevOut{Vout: 0xffffffff}, ScriptSig: []byte{1, height, 0},
+		Sequence: 0xffffffff}}, value)
+}
+
+// newTestBlock - builds a block (with no valid proof of work) from the transactions
+func newTestBlock(t *testing.T, prev []byte, ts, bits uint32, txs ...*btc.Tx) *btc.Block {
+	mtr := make([][32]byte, len(txs))
+	for i, tx := range txs {
+		mtr[i] = tx.Hash.Hash
+	}
+	merkle, _ := btc.CalcMerkle(mtr)
+
+	b := new(bytes.Buffer)
+	binary.Write(b, binary.LittleEndian, uint32(4))
+	b.Write(prev)
+	b.Write(merkle)
+	binary.Write(b, binary.LittleEndian, ts)
+	binary.Write(b, binary.LittleEndian, bits)
+	binary.Write(b, binary.LittleEndian, uint32(0))
+	btc.WriteVlen(b, uint64(len(txs)))
+	for _, tx := range txs {
+		b.Write(tx.Raw)
+	}
+	bl, er := btc.NewBlock(b.Bytes())
+	if er != nil {
+		t.Fatal(er.Error())
+	}
+	return bl
+}
+
+func TestCheckBlockProposal(t *testing.T) {
+	ch := NewChainExt(t.TempDir()+"/", btc.NewUint256FromString(testGenesis), false, nil, &BlockDBOpts{})
+	defer ch.Close()
+
+	prev := ch.LastBlock().BlockHash.Hash[:]
+	ts := ch.LastBlock().Timestamp() + 600
+	bits := ch.GetNextWorkRequired(ch.LastBlock(), ts)
+	reward := btc.GetBlockReward(1)
+	cb := newTestCoinbase(1, reward)
+
+	if res := ch.CheckBlockProposal(newTestBlock(t, prev, ts, bits, cb)); res != "" {
+		t.Fatal("Valid proposal rejected:", res)
+	}
+
+	unknown := newTestTx([]*btc.TxIn{{Input: btc.TxPrevOut{Hash: [32]byte{1}}, ScriptSig: []byte{0x51},
+		Sequence: 0xffffffff}}, 1000)
+	badMerkle := newTestBlock(t, prev, ts, bits, cb)
+	badMerkle.Raw[36] ^= 1
+
+	tests := []struct {
+		name string
+		bl   *btc.Block
+		exp  string
+	}{
+		{"not on tip", newTestBlock(t, make([]byte, 32), ts, bits, cb), "inconclusive-not-best-prevblk"},
+		{"bad bits", newTestBlock(t, prev, ts, bits-1, cb), "bad-diffbits"},
+		{"too old", newTestBlock(t, prev, ch.LastBlock().Timestamp(), bits, cb), "time-too-old"},
+		{"no coinbase", newTestBlock(t, prev, ts, bits, unknown), "bad-cb-missing"},
+		{"two coinbases", newTestBlock(t, prev, ts, bits, cb, newTestCoinbase(2, 1)), "bad-cb-multiple"},
+		{"bad merkle", badMerkle, "bad-txnmrklroot"},
+		{"duplicate tx", newTestBlock(t, prev, ts, bits, cb, unknown, unknown, unknown), "bad-txns-duplicate"},
+		{"missing input", newTestBlock(t, prev, ts, bits, cb, unknown), "bad-txns-inputs-missingorspent"},
+		{"coinbase too big", newTestBlock(t, prev, ts, bits, newTestCoinbase(1, reward+1)), "bad-cb-amount"},
+	}
+	for _, tc := range tests {
+		if res := ch.CheckBlockProposal(tc.bl); res != tc.exp {
+			t.Error(tc.name, "- expected", tc.exp, "got", res)
+		}
+	}
+
+	ch.Consensus.BIP34Height = 1
+	if res := ch.CheckBlockProposal(newTestBlock(t, prev, ts, bits, newTestCoinbase(2, reward))); res != "bad-cb-height" {
+		t.Error("wrong height - got", res)
+	}
+}
@@ -0,0 +1,106 @@
+// Package netcap - reads and writes captured P2P network messages
+//
+// A capture file starts with "DUODCAP1" followed by the 4 bytes of the network magic.
+// Then there are records, each of them:
+//  [8] - time in unix nanoseconds (LE)
+//  [1] - direction: 0 for received, 1 for sent
+//  [12] - command (zero padded)
+//  [4] - payload length (LE)
+//  [...] - payload
+package netcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	// FileHeader -
+	FileHeader = "DUODCAP1"
+	// MaxPayload - refuse to read records bigger than this
+	MaxPayload = 32 << 20
+)
+
+// Record - one captured message
+type Record struct {
+	Time    time.Time
+	Out     bool // true if the message was sent by us
+	Cmd     string
+	Payload []byte
+}
+
+// Writer -
+type Writer struct {
+	w io.Writer
+}
+
+// NewWriter - writes the file header and returns a new capture writer
+func NewWriter(w io.Writer, magic [4]byte) (*Writer, error) {
+	if _, er := w.Write(append([]byte(FileHeader), magic[:]...)); er != nil {
+		return nil, er
+	}
+	return &Writer{w: w}, nil
+}
+
+// Write - stores one record (with a single call to the underlying writer)
+func (w *Writer) Write(r *Record) error {
+	buf := make([]byte, 25+len(r.Payload))
+	binary.LittleEndian.PutUint64(buf[0:8], uint64(r.Time.UnixNano()))
+	if r.Out {
+		buf[8] = 1
+	}
+	copy(buf[9:21], r.Cmd)
+	binary.LittleEndian.PutUint32(buf[21:25], uint32(len(r.Payload)))
+	copy(buf[25:], r.Payload)
+	_, er := w.w.Write(buf)
+	return er
+}
+
+// Reader -
+type Reader struct {
+	r     io.Reader
+	Magic [4]byte
+}
+
+// NewReader - checks the file header and returns a new capture reader
+func NewReader(r io.Reader) (*Reader, error) {
+	var hdr [len(FileHeader) + 4]byte
+	if _, er := io.ReadFull(r, hdr[:]); er != nil {
+		return nil, er
+	}
+	if !bytes.Equal(hdr[:len(FileHeader)], []byte(FileHeader)) {
+		return nil, errors.New("netcap: not a capture file")
+	}
+	res := &Reader{r: r}
+	copy(res.Magic[:], hdr[len(FileHeader):])
+	return res, nil
+}
+
+// Read - returns the next record or io.EOF at the end of the file
+func (r *Reader) Read() (*Record, error) {
+	var hdr [25]byte
+	if _, er := io.ReadFull(r.r, hdr[:]); er != nil {
+		if er == io.ErrUnexpectedEOF {
+			er = errors.New("netcap: truncated record header")
+		}
+		return nil, er
+	}
+	le := binary.LittleEndian.Uint32(hdr[21:25])
+	if le > MaxPayload {
+		return nil, errors.New("netcap: payload too big")
+	}
+	rec := &Record{
+		Time:    time.Unix(0, int64(binary.LittleEndian.Uint64(hdr[0:8]))),
+		Out:     hdr[8] != 0,
+		Cmd:     strings.TrimRight(string(hdr[9:21]), "\000"),
+		Payload: make([]byte, le),
+	}
+	if _, er := io.ReadFull(r.r, rec.Payload); er != nil {
+		return nil, errors.New("netcap: truncated payload")
+	}
+	return rec, nil
+}
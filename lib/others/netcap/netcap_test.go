@@ -0,0 +1,62 @@
+package netcap
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestWriteRead(t *testing.T) {
+	recs := []*Record{
+		{Time: time.Unix(1500000000, 123), Out: true, Cmd: "version", Payload: []byte{1, 2, 3}},
+		{Time: time.Unix(1500000001, 0), Cmd: "verack"},
+		{Time: time.Unix(1500000002, 999), Cmd: "sendheaders", Payload: bytes.Repeat([]byte{0xab}, 1000)},
+	}
+	magic := [4]byte{0xf9, 0xbe, 0xb4, 0xd9}
+
+	buf := new(bytes.Buffer)
+	w, er := NewWriter(buf, magic)
+	if er != nil {
+		t.Fatal(er)
+	}
+	for _, r := range recs {
+		if er = w.Write(r); er != nil {
+			t.Fatal(er)
+		}
+	}
+
+	rd, er := NewReader(bytes.NewReader(buf.Bytes()))
+	if er != nil {
+		t.Fatal(er)
+	}
+	if rd.Magic != magic {
+		t.Error("Magic mismatch", rd.Magic)
+	}
+	for i, exp := range recs {
+		r, er := rd.Read()
+		if er != nil {
+			t.Fatal(i, er)
+		}
+		if !r.Time.Equal(exp.Time) || r.Out != exp.Out || r.Cmd != exp.Cmd || !bytes.Equal(r.Payload, exp.Payload) {
+			t.Error("Record", i, "mismatch", r.Cmd, r.Time, r.Out, len(r.Payload))
+		}
+	}
+	if _, er = rd.Read(); er != io.EOF {
+		t.Error("io.EOF expected, got", er)
+	}
+}
+
+func TestBadFile(t *testing.T) {
+	if _, er := NewReader(bytes.NewReader([]byte("NOTACAPTUREFILE!"))); er == nil {
+		t.Error("Bad header not detected")
+	}
+
+	buf := new(bytes.Buffer)
+	w, _ := NewWriter(buf, [4]byte{})
+	w.Write(&Record{Time: time.Now(), Cmd: "ping", Payload: make([]byte, 8)})
+	rd, _ := NewReader(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	if _, er := rd.Read(); er == nil || er == io.EOF {
+		t.Error("Truncated payload not detected")
+	}
+}
@@ -0,0 +1,298 @@
+// Package refnode - compares results of JSON-RPC calls with a reference node
+package refnode
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// MaxDiffs - stop comparing after this many differences
+	MaxDiffs = 50
+	// Missing - value of a field that does not exist on one side
+	Missing = "<missing>"
+)
+
+// ErrDisabled -
+var ErrDisabled = errors.New("refnode: reference node not configured")
+
+// Client - simple JSON-RPC client
+type Client struct {
+	id         uint64 // first, to be 64-bit aligned for atomic operations
+	URL        string
+	User, Pass string
+	HTTP       *http.Client
+}
+
+// NewClient -
+func NewClient(url, user, pass string) *Client {
+	return &Client{URL: url, User: user, Pass: pass, HTTP: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Call - executes the method on the remote node and returns its decoded result
+// Numbers are returned as json.Number.
+func (c *Client) Call(method string, params interface{}) (interface{}, error) {
+	if params == nil {
+		params = []interface{}{}
+	}
+	req, er := json.Marshal(map[string]interface{}{"jsonrpc": "1.0", "id": atomic.AddUint64(&c.id, 1),
+		"method": method, "params": params})
+	if er != nil {
+		return nil, er
+	}
+	hr, er := http.NewRequest("POST", c.URL, bytes.NewReader(req))
+	if er != nil {
+		return nil, er
+	}
+	hr.Header.Set("Content-Type", "application/json")
+	if c.User != "" || c.Pass != "" {
+		hr.SetBasicAuth(c.User, c.Pass)
+	}
+	res, er := c.HTTP.Do(hr)
+	if er != nil {
+		return nil, er
+	}
+	defer res.Body.Close()
+	body, er := ioutil.ReadAll(res.Body)
+	if er != nil {
+		return nil, er
+	}
+
+	var resp struct {
+		Result interface{} `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	jd := json.NewDecoder(bytes.NewReader(body))
+	jd.UseNumber()
+	if er = jd.Decode(&resp); er != nil {
+		return nil, fmt.Errorf("HTTP %d: %s", res.StatusCode, bytes.TrimSpace(body))
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("RPC error %d: %s", resp.Error.Code, resp.Error.Message)
+	}
+	return resp.Result, nil
+}
+
+// Normalize - converts any value into the form returned by Client.Call (maps, slices, json.Number...)
+func Normalize(v interface{}) (interface{}, error) {
+	b, er := json.Marshal(v)
+	if er != nil {
+		return nil, er
+	}
+	var res interface{}
+	jd := json.NewDecoder(bytes.NewReader(b))
+	jd.UseNumber()
+	er = jd.Decode(&res)
+	return res, er
+}
+
+// Difference - one value that differs
+type Difference struct {
+	Path   string `json:"path"`
+	Ours   string `json:"ours"`
+	Theirs string `json:"theirs"`
+}
+
+func (d Difference) String() string {
+	return d.Path + ": " + d.Ours + " != " + d.Theirs
+}
+
+func toString(v interface{}) string {
+	b, _ := json.Marshal(v)
+	if len(b) > 80 {
+		return string(b[:77]) + "..."
+	}
+	return string(b)
+}
+
+func numbersEqual(a, b json.Number) bool {
+	if a == b {
+		return true
+	}
+	fa, e1 := strconv.ParseFloat(string(a), 64)
+	fb, e2 := strconv.ParseFloat(string(b), 64)
+	return e1 == nil && e2 == nil && fa == fb
+}
+
+// Diff - compares two normalized values, returning up to MaxDiffs differences
+func Diff(ours, theirs interface{}) (res []Difference) {
+	diff(ours, theirs, "", &res)
+	return
+}
+
+func diff(ours, theirs interface{}, path string, res *[]Difference) {
+	if len(*res) >= MaxDiffs {
+		return
+	}
+	switch o := ours.(type) {
+	case map[string]interface{}:
+		t, ok := theirs.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(o)+len(t))
+		for k := range o {
+			keys = append(keys, k)
+		}
+		for k := range t {
+			if _, ok := o[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			kp := k
+			if path != "" {
+				kp = path + "." + k
+			}
+			ov, ook := o[k]
+			tv, tok := t[k]
+			switch {
+			case !ook:
+				*res = append(*res, Difference{Path: kp, Ours: Missing, Theirs: toString(tv)})
+			case !tok:
+				*res = append(*res, Difference{Path: kp, Ours: toString(ov), Theirs: Missing})
+			default:
+				diff(ov, tv, kp, res)
+			}
+			if len(*res) >= MaxDiffs {
+				return
+			}
+		}
+		return
+
+	case []interface{}:
+		t, ok := theirs.([]interface{})
+		if !ok {
+			break
+		}
+		if len(o) != len(t) {
+			*res = append(*res, Difference{Path: path + "#length", Ours: fmt.Sprint(len(o)), Theirs: fmt.Sprint(len(t))})
+		}
+		for i := 0; i < len(o) && i < len(t); i++ {
+			diff(o[i], t[i], fmt.Sprint(path, "[", i, "]"), res)
+		}
+		return
+
+	case json.Number:
+		if t, ok := theirs.(json.Number); ok && numbersEqual(o, t) {
+			return
+		}
+
+	default:
+		if ours == theirs {
+			return
+		}
+	}
+	if path == "" {
+		path = "."
+	}
+	*res = append(*res, Difference{Path: path, Ours: toString(ours), Theirs: toString(theirs)})
+}
+
+// Result - outcome of one comparison
+type Result struct {
+	Time   time.Time    `json:"time"`
+	Method string       `json:"method"`
+	Params interface{}  `json:"params"`
+	Diffs  []Difference `json:"diffs,omitempty"`
+	Error  string       `json:"error,omitempty"`
+}
+
+// MethodStats -
+type MethodStats struct {
+	Compared, Mismatched, Errors uint64
+}
+
+// Comparator - calls the reference node and keeps the results that did not match
+type Comparator struct {
+	*Client
+	// Filter - if set, both results are passed through it before comparing (e.g. to drop volatile fields)
+	Filter     func(method string, v interface{}) interface{}
+	MaxHistory int
+
+	mutex   sync.Mutex
+	history []*Result
+	stats   map[string]*MethodStats
+}
+
+// NewComparator -
+func NewComparator(cl *Client) *Comparator {
+	return &Comparator{Client: cl, MaxHistory: 100, stats: make(map[string]*MethodStats)}
+}
+
+// Compare - calls the method on the reference node and compares its result with ours
+func (c *Comparator) Compare(method string, params interface{}, ours interface{}) *Result {
+	res := &Result{Time: time.Now(), Method: method, Params: params}
+	theirs, er := c.Call(method, params)
+	if er == nil {
+		if ours, er = Normalize(ours); er == nil {
+			if c.Filter != nil {
+				ours, theirs = c.Filter(method, ours), c.Filter(method, theirs)
+			}
+			res.Diffs = Diff(ours, theirs)
+		}
+	}
+	if er != nil {
+		res.Error = er.Error()
+	}
+
+	c.mutex.Lock()
+	st := c.stats[method]
+	if st == nil {
+		st = new(MethodStats)
+		c.stats[method] = st
+	}
+	st.Compared++
+	if res.Error != "" {
+		st.Errors++
+	} else if len(res.Diffs) > 0 {
+		st.Mismatched++
+	}
+	if res.Error != "" || len(res.Diffs) > 0 {
+		c.history = append(c.history, res)
+		if len(c.history) > c.MaxHistory {
+			c.history = c.history[len(c.history)-c.MaxHistory:]
+		}
+	}
+	c.mutex.Unlock()
+	return res
+}
+
+// History - returns the recent comparisons that failed or did not match (the oldest first)
+func (c *Comparator) History() []*Result {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]*Result{}, c.history...)
+}
+
+// Stats - returns the counters for each compared method
+func (c *Comparator) Stats() map[string]MethodStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	res := make(map[string]MethodStats, len(c.stats))
+	for k, v := range c.stats {
+		res[k] = *v
+	}
+	return res
+}
+
+// Reset - clears the history and the counters
+func (c *Comparator) Reset() {
+	c.mutex.Lock()
+	c.history = nil
+	c.stats = make(map[string]*MethodStats)
+	c.mutex.Unlock()
+}
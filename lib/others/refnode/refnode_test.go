@@ -0,0 +1,108 @@
+package refnode
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// stub - reference node returning fixed results for each method
+func stub(t *testing.T, results map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, _ := r.BasicAuth(); u != "user" || p != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req struct {
+			Method string      `json:"method"`
+			ID     interface{} `json:"id"`
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		if er := json.Unmarshal(b, &req); er != nil {
+			t.Error(er)
+		}
+		res, ok := results[req.Method]
+		id, _ := json.Marshal(req.ID)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"result":null,"error":{"code":-32601,"message":"Method not found"},"id":` + string(id) + `}`))
+			return
+		}
+		w.Write([]byte(`{"result":` + res + `,"error":null,"id":` + string(id) + `}`))
+	}))
+}
+
+func TestDiff(t *testing.T) {
+	var a, b interface{}
+	a, _ = Normalize(map[string]interface{}{"x": 1, "y": []int{1, 2}, "z": "s", "only": true})
+	json.Unmarshal([]byte(`{"x":1.0,"y":[1,3,4],"z":"s","their":null}`), &b)
+	b, _ = Normalize(b)
+
+	exp := []string{
+		`only: true != <missing>`,
+		`their: <missing> != null`,
+		`y#length: 2 != 3`,
+		`y[1]: 2 != 3`,
+	}
+	d := Diff(a, b)
+	if len(d) != len(exp) {
+		t.Fatal("Unexpected diffs", d)
+	}
+	for i := range exp {
+		if d[i].String() != exp[i] {
+			t.Error(i, d[i].String(), "!=", exp[i])
+		}
+	}
+	if len(Diff(a, a)) != 0 {
+		t.Error("Same values differ")
+	}
+}
+
+func TestComparator(t *testing.T) {
+	srv := stub(t, map[string]string{
+		"getblockheader": `{"hash":"00ab","height":100,"confirmations":5}`,
+		"getrawmempool":  `["aa","bb"]`,
+	})
+	defer srv.Close()
+
+	c := NewComparator(NewClient(srv.URL, "user", "pass"))
+	c.Filter = func(method string, v interface{}) interface{} {
+		if m, ok := v.(map[string]interface{}); ok {
+			delete(m, "confirmations")
+		}
+		return v
+	}
+
+	r := c.Compare("getblockheader", []interface{}{"00ab"}, map[string]interface{}{"hash": "00ab", "height": 100, "confirmations": 1})
+	if r.Error != "" || len(r.Diffs) != 0 {
+		t.Error("Unexpected mismatch", r.Error, r.Diffs)
+	}
+	r = c.Compare("getrawmempool", nil, []string{"aa", "cc"})
+	if len(r.Diffs) != 1 || r.Diffs[0].Path != "[1]" {
+		t.Error("Mempool difference not found", r.Diffs)
+	}
+	r = c.Compare("unknown", nil, 1)
+	if !strings.Contains(r.Error, "Method not found") {
+		t.Error("Error expected", r.Error)
+	}
+
+	st := c.Stats()
+	if st["getblockheader"].Compared != 1 || st["getrawmempool"].Mismatched != 1 || st["unknown"].Errors != 1 {
+		t.Error("Bad stats", st)
+	}
+	if h := c.History(); len(h) != 2 || h[0].Method != "getrawmempool" || h[1].Method != "unknown" {
+		t.Error("Bad history", h)
+	}
+	c.Reset()
+	if len(c.History()) != 0 || len(c.Stats()) != 0 {
+		t.Error("Not reset")
+	}
+
+	bad := NewComparator(NewClient(srv.URL, "user", "wrong"))
+	if r = bad.Compare("getrawmempool", nil, nil); r.Error == "" {
+		t.Error("Auth error expected")
+	}
+}
@@ -0,0 +1,123 @@
+// Package tlscert - TLS certificates for the node's HTTP listeners
+package tlscert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// ValidFor - how long the generated certificates are valid
+const ValidFor = 10 * 365 * 24 * time.Hour
+
+var mutex sync.Mutex // so two listeners do not generate the same files at once
+
+// Generate - makes a new self-signed certificate for the given hosts (names or IPs)
+// Returns the PEM encoded certificate and private key.
+func Generate(hosts []string) (certPEM, keyPEM []byte, er error) {
+	priv, er := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if er != nil {
+		return
+	}
+	serial, er := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if er != nil {
+		return
+	}
+
+	tmpl := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Duod"}, CommonName: "Duod self-signed"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(ValidFor),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else if h != "" {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+
+	der, er := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &priv.PublicKey, priv)
+	if er != nil {
+		return
+	}
+	kb, er := x509.MarshalECPrivateKey(priv)
+	if er != nil {
+		return
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb})
+	return
+}
+
+// LoadOrCreate - loads the certificate and key from the files
+// If none of the files exists, a new self-signed certificate is generated and stored in them.
+func LoadOrCreate(certFile, keyFile string, hosts []string) (cert tls.Certificate, created bool, er error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	_, e1 := os.Stat(certFile)
+	_, e2 := os.Stat(keyFile)
+	if os.IsNotExist(e1) && os.IsNotExist(e2) {
+		var cp, kp []byte
+		if cp, kp, er = Generate(hosts); er != nil {
+			return
+		}
+		if er = ioutil.WriteFile(keyFile, kp, 0600); er != nil {
+			return
+		}
+		if er = ioutil.WriteFile(certFile, cp, 0644); er != nil {
+			os.Remove(keyFile)
+			return
+		}
+		created = true
+	}
+	cert, er = tls.LoadX509KeyPair(certFile, keyFile)
+	return
+}
+
+// ServerConfig - returns TLS config for a server with the given certificate
+// If clientCAFile is not empty, the client certificates signed by it are verified.
+// With requireClientCert set, the connections without a valid client certificate are refused.
+func ServerConfig(cert tls.Certificate, clientCAFile string, requireClientCert bool) (*tls.Config, error) {
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCAFile == "" {
+		if requireClientCert {
+			return nil, errors.New("tlscert: client CA file needed to require client certificates")
+		}
+		return cfg, nil
+	}
+	pemData, er := ioutil.ReadFile(clientCAFile)
+	if er != nil {
+		return nil, er
+	}
+	cfg.ClientCAs = x509.NewCertPool()
+	if !cfg.ClientCAs.AppendCertsFromPEM(pemData) {
+		return nil, errors.New("tlscert: no certificates found in " + clientCAFile)
+	}
+	if requireClientCert {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	} else {
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return cfg, nil
+}
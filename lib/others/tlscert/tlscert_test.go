@@ -0,0 +1,85 @@
+package tlscert
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadOrCreate(t *testing.T) {
+	dir, er := ioutil.TempDir("", "tlscert")
+	if er != nil {
+		t.Fatal(er)
+	}
+	defer os.RemoveAll(dir)
+	cf, kf := filepath.Join(dir, "test.crt"), filepath.Join(dir, "test.key")
+
+	cert, created, er := LoadOrCreate(cf, kf, []string{"localhost", "127.0.0.1"})
+	if er != nil {
+		t.Fatal(er)
+	}
+	if !created {
+		t.Error("Certificate not created")
+	}
+	x, er := x509.ParseCertificate(cert.Certificate[0])
+	if er != nil {
+		t.Fatal(er)
+	}
+	if er = x.VerifyHostname("127.0.0.1"); er != nil {
+		t.Error(er)
+	}
+	if er = x.VerifyHostname("localhost"); er != nil {
+		t.Error(er)
+	}
+	if x.VerifyHostname("example.com") == nil {
+		t.Error("Unexpected host accepted")
+	}
+
+	cert2, created, er := LoadOrCreate(cf, kf, nil)
+	if er != nil {
+		t.Fatal(er)
+	}
+	if created || !bytes.Equal(cert.Certificate[0], cert2.Certificate[0]) {
+		t.Error("Existing certificate not loaded")
+	}
+}
+
+func TestServerConfig(t *testing.T) {
+	cp, kp, er := Generate([]string{"localhost"})
+	if er != nil {
+		t.Fatal(er)
+	}
+	cert, er := tls.X509KeyPair(cp, kp)
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	cfg, er := ServerConfig(cert, "", false)
+	if er != nil || cfg.ClientAuth != tls.NoClientCert {
+		t.Error("Bad config without client CA", er)
+	}
+	if _, er = ServerConfig(cert, "", true); er == nil {
+		t.Error("Client certs required without CA")
+	}
+
+	f, er := ioutil.TempFile("", "tlsca")
+	if er != nil {
+		t.Fatal(er)
+	}
+	defer os.Remove(f.Name())
+	f.Write(cp)
+	f.Close()
+
+	cfg, er = ServerConfig(cert, f.Name(), false)
+	if er != nil || cfg.ClientAuth != tls.VerifyClientCertIfGiven || cfg.ClientCAs == nil {
+		t.Error("Bad config with client CA", er)
+	}
+	cfg, er = ServerConfig(cert, f.Name(), true)
+	if er != nil || cfg.ClientAuth != tls.RequireAndVerifyClientCert {
+		t.Error("Bad config with required client certs", er)
+	}
+}
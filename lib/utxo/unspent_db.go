@@ -487,6 +487,20 @@ func (db *UnspentDB) TxPresent(id *btc.Uint256) (res bool) {
 	return
 }
 
+// TxHeight - Returns height of the block with the given TXID, if it still has unspent outputs
+func (db *UnspentDB) TxHeight(id *btc.Uint256) (height uint32, ok bool) {
+	var ind KeyType
+	copy(ind[:], id.Hash[:])
+	db.RWMutex.RLock()
+	v := db.HashMap[ind]
+	db.RWMutex.RUnlock()
+	if v == nil || !bytes.Equal(v[:32-UtxoIdxLen], id.Hash[UtxoIdxLen:]) {
+		return
+	}
+	u64, _ := btc.VULe(v[32-UtxoIdxLen:])
+	return uint32(u64), true
+}
+
 func (db *UnspentDB) del(hash []byte, outs []bool) {
 	var ind KeyType
 	copy(ind[:], hash)
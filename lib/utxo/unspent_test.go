@@ -3,6 +3,8 @@ package utxo
 import (
 	"encoding/hex"
 	"testing"
+
+	"github.com/ParallelCoinTeam/duod/lib/btc"
 )
 
 const (
@@ -46,6 +48,24 @@ func BenchmarkNewUtxoRecStatic(b *testing.B) {
 	}
 }
 
+func TestTxHeight(t *testing.T) {
+	raw, _ := hex.DecodeString(UtxoRecord)
+	var key KeyType
+	copy(key[:], raw[:])
+	db := &UnspentDB{HashMap: map[KeyType][]byte{key: raw[UtxoIdxLen:]}}
+
+	height, ok := db.TxHeight(btc.NewUint256(raw[:32]))
+	if !ok || height != FullUtxoRec(raw).InBlock {
+		t.Error("TxHeight", height, ok)
+	}
+
+	other := btc.NewUint256(raw[:32])
+	other.Hash[31]++ // same key, different TXID
+	if _, ok = db.TxHeight(other); ok {
+		t.Error("TxHeight found other TXID")
+	}
+}
+
 func TestMembinds(t *testing.T) {
 	MembindInit()
 	ptr := malloc(0x100000)
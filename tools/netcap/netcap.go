@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ParallelCoinTeam/duod"
+	"github.com/ParallelCoinTeam/duod/lib/btc"
+	"github.com/ParallelCoinTeam/duod/lib/others/netcap"
+)
+
+type oneMsg struct {
+	Time    string      `json:"time"`
+	Dir     string      `json:"dir"`
+	Cmd     string      `json:"cmd"`
+	Size    int         `json:"size"`
+	Decoded interface{} `json:"decoded,omitempty"`
+	Payload string      `json:"payload,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+type oneInv struct {
+	Type uint32 `json:"type"`
+	Hash string `json:"hash"`
+}
+
+func usage() {
+	fmt.Println("Duod NetCap version", Duod.Version)
+	fmt.Println("Usage:")
+	fmt.Println(" netcap decode <file.cap> [-hex]         - print the capture as JSON (-hex to include all payloads)")
+	fmt.Println(" netcap replay <file.cap> <host:port> [-fast] - send the messages received from the peer to a node")
+	os.Exit(1)
+}
+
+func main() {
+	if len(os.Args) < 3 {
+		usage()
+	}
+
+	f, er := os.Open(os.Args[2])
+	if er != nil {
+		println(er.Error())
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	rd, er := netcap.NewReader(f)
+	if er != nil {
+		println(er.Error())
+		os.Exit(1)
+	}
+
+	switch os.Args[1] {
+	case "decode":
+		decode(rd, len(os.Args) > 3 && os.Args[3] == "-hex")
+	case "replay":
+		if len(os.Args) < 4 {
+			usage()
+		}
+		replay(rd, os.Args[3], len(os.Args) > 4 && os.Args[4] == "-fast")
+	default:
+		usage()
+	}
+}
+
+func decode(rd *netcap.Reader, withHex bool) {
+	var res []*oneMsg
+	for {
+		rec, er := rd.Read()
+		if er == io.EOF {
+			break
+		}
+		if er != nil {
+			println(er.Error())
+			break
+		}
+		m := &oneMsg{Time: rec.Time.Format("2006-01-02 15:04:05.000"), Cmd: rec.Cmd, Size: len(rec.Payload)}
+		if rec.Out {
+			m.Dir = "out"
+		} else {
+			m.Dir = "in"
+		}
+		m.Decoded, er = decodePayload(rec.Cmd, rec.Payload)
+		if er != nil {
+			m.Error = er.Error()
+		}
+		if withHex || m.Decoded == nil && len(rec.Payload) > 0 {
+			m.Payload = hex.EncodeToString(rec.Payload)
+		}
+		res = append(res, m)
+	}
+	out, _ := json.MarshalIndent(res, "", "  ")
+	os.Stdout.Write(out)
+	fmt.Println()
+}
+
+func decodePayload(cmd string, pl []byte) (res interface{}, er error) {
+	defer func() {
+		if r := recover(); r != nil {
+			res = nil
+			er = fmt.Errorf("payload corrupt: %v", r)
+		}
+	}()
+
+	switch cmd {
+	case "version":
+		return decodeVersion(pl), nil
+
+	case "ping", "pong":
+		return map[string]string{"nonce": hex.EncodeToString(pl)}, nil
+
+	case "inv", "getdata", "notfound":
+		cnt, of := btc.VLen(pl)
+		invs := make([]oneInv, cnt)
+		for i := range invs {
+			invs[i].Type = binary.LittleEndian.Uint32(pl[of : of+4])
+			invs[i].Hash = btc.NewUint256(pl[of+4 : of+36]).String()
+			of += 36
+		}
+		return invs, nil
+
+	case "getheaders", "getblocks":
+		cnt, of := btc.VLen(pl[4:])
+		of += 4
+		var locs []string
+		for i := 0; i < cnt; i++ {
+			locs = append(locs, btc.NewUint256(pl[of:of+32]).String())
+			of += 32
+		}
+		return map[string]interface{}{"version": binary.LittleEndian.Uint32(pl[:4]), "locators": locs,
+			"stop": btc.NewUint256(pl[of : of+32]).String()}, nil
+
+	case "headers":
+		cnt, of := btc.VLen(pl)
+		hdrs := make([]interface{}, cnt)
+		for i := range hdrs {
+			hdrs[i] = decodeHeader(pl[of : of+80])
+			of += 81
+		}
+		return hdrs, nil
+
+	case "block":
+		bl, er := btc.NewBlock(pl)
+		if er != nil {
+			return nil, er
+		}
+		res := decodeHeader(pl[:80])
+		if er = bl.BuildTxList(); er != nil {
+			return res, er
+		}
+		var txs []string
+		for _, tx := range bl.Txs {
+			txs = append(txs, tx.Hash.String())
+		}
+		res["txs"] = txs
+		return res, nil
+
+	case "cmpctblock":
+		res := decodeHeader(pl[:80])
+		res["nonce"] = hex.EncodeToString(pl[80:88])
+		return res, nil
+
+	case "tx":
+		tx, n := btc.NewTx(pl)
+		if tx == nil || n != len(pl) {
+			return nil, fmt.Errorf("transaction corrupt")
+		}
+		tx.SetHash(pl)
+		return decodeTx(tx), nil
+
+	case "addr":
+		cnt, of := btc.VLen(pl)
+		var addrs []map[string]interface{}
+		for i := 0; i < cnt; i++ {
+			a := pl[of : of+30]
+			addrs = append(addrs, map[string]interface{}{
+				"time":     binary.LittleEndian.Uint32(a[0:4]),
+				"services": fmt.Sprintf("0x%x", binary.LittleEndian.Uint64(a[4:12])),
+				"addr":     fmt.Sprintf("%d.%d.%d.%d:%d", a[24], a[25], a[26], a[27], binary.BigEndian.Uint16(a[28:30])),
+			})
+			of += 30
+		}
+		return addrs, nil
+
+	case "feefilter":
+		return map[string]uint64{"feerate": binary.LittleEndian.Uint64(pl[:8])}, nil
+
+	case "sendcmpct":
+		return map[string]interface{}{"announce": pl[0] != 0, "version": binary.LittleEndian.Uint64(pl[1:9])}, nil
+	}
+
+	return nil, nil // no payload or unknown command
+}
+
+func decodeVersion(pl []byte) map[string]interface{} {
+	res := map[string]interface{}{
+		"version":   binary.LittleEndian.Uint32(pl[0:4]),
+		"services":  fmt.Sprintf("0x%x", binary.LittleEndian.Uint64(pl[4:12])),
+		"timestamp": binary.LittleEndian.Uint64(pl[12:20]),
+		"addr_recv": fmt.Sprintf("%d.%d.%d.%d:%d", pl[40], pl[41], pl[42], pl[43], binary.BigEndian.Uint16(pl[44:46])),
+		"addr_from": fmt.Sprintf("%d.%d.%d.%d:%d", pl[66], pl[67], pl[68], pl[69], binary.BigEndian.Uint16(pl[70:72])),
+		"nonce":     hex.EncodeToString(pl[72:80]),
+	}
+	if len(pl) > 80 {
+		le, of := btc.VLen(pl[80:])
+		of += 80
+		res["user_agent"] = string(pl[of : of+le])
+		of += le
+		if len(pl) >= of+4 {
+			res["height"] = binary.LittleEndian.Uint32(pl[of : of+4])
+			of += 4
+			res["relay"] = len(pl) <= of || pl[of] != 0
+		}
+	}
+	return res
+}
+
+func decodeHeader(hdr []byte) map[string]interface{} {
+	return map[string]interface{}{
+		"hash":       btc.NewSha2Hash(hdr[:80]).String(),
+		"version":    binary.LittleEndian.Uint32(hdr[0:4]),
+		"prev":       btc.NewUint256(hdr[4:36]).String(),
+		"merkleroot": btc.NewUint256(hdr[36:68]).String(),
+		"time":       binary.LittleEndian.Uint32(hdr[68:72]),
+		"bits":       fmt.Sprintf("%08x", binary.LittleEndian.Uint32(hdr[72:76])),
+		"nonce":      binary.LittleEndian.Uint32(hdr[76:80]),
+	}
+}
+
+func decodeTx(tx *btc.Tx) map[string]interface{} {
+	var ins, outs []map[string]interface{}
+	for _, in := range tx.TxIn {
+		ins = append(ins, map[string]interface{}{
+			"prevout":   in.Input.String(),
+			"scriptsig": hex.EncodeToString(in.ScriptSig),
+			"sequence":  in.Sequence,
+		})
+	}
+	for _, out := range tx.TxOut {
+		outs = append(outs, map[string]interface{}{
+			"value":    out.Value,
+			"pkscript": hex.EncodeToString(out.PkScript),
+		})
+	}
+	return map[string]interface{}{
+		"txid":     tx.Hash.String(),
+		"version":  tx.Version,
+		"locktime": tx.LockTime,
+		"segwit":   tx.SegWit != nil,
+		"vin":      ins,
+		"vout":     outs,
+	}
+}
+
+// replay - send all the messages that we had received from the peer, keeping the original timing
+func replay(rd *netcap.Reader, addr string, fast bool) {
+	conn, er := net.Dial("tcp4", addr)
+	if er != nil {
+		println(er.Error())
+		os.Exit(1)
+	}
+	defer conn.Close()
+
+	go func() {
+		// print whatever the node sends back
+		var hdr [24]byte
+		for {
+			if _, er := io.ReadFull(conn, hdr[:]); er != nil {
+				fmt.Println("Connection closed:", er.Error())
+				os.Exit(0)
+			}
+			le := binary.LittleEndian.Uint32(hdr[16:20])
+			if _, er := io.CopyN(ioutil.Discard, conn, int64(le)); er != nil {
+				fmt.Println("Connection closed:", er.Error())
+				os.Exit(0)
+			}
+			fmt.Println(" <-", strings.TrimRight(string(hdr[4:16]), "\000"), le)
+		}
+	}()
+
+	var last time.Time
+	var cnt int
+	for {
+		rec, er := rd.Read()
+		if er == io.EOF {
+			break
+		}
+		if er != nil {
+			println(er.Error())
+			break
+		}
+		if rec.Out {
+			continue
+		}
+		if !fast && !last.IsZero() {
+			time.Sleep(rec.Time.Sub(last))
+		}
+		last = rec.Time
+
+		msg := new(bytes.Buffer)
+		msg.Write(rd.Magic[:])
+		var cmd [12]byte
+		copy(cmd[:], rec.Cmd)
+		msg.Write(cmd[:])
+		binary.Write(msg, binary.LittleEndian, uint32(len(rec.Payload)))
+		sh := btc.Sha2Sum(rec.Payload)
+		msg.Write(sh[:4])
+		msg.Write(rec.Payload)
+		if _, er = conn.Write(msg.Bytes()); er != nil {
+			println(er.Error())
+			break
+		}
+		fmt.Println("->", rec.Cmd, len(rec.Payload))
+		cnt++
+	}
+	fmt.Println(cnt, "messages replayed")
+	time.Sleep(time.Second) // give the node a chance to respond
+}